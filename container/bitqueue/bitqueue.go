@@ -0,0 +1,137 @@
+/*
+Package bitqueue implements a bit-packed FIFO queue of unsigned values with a fixed bit width.
+
+Each value occupies exactly the configured number of bits, so that e.g. a queue of 4-bit values stores 16 values per
+64-bit word. The values are kept in a ring buffer that grows as needed.
+
+Pop panics if the queue is empty. For an error-returning variant use TryPop, which returns ErrEmpty instead.
+*/
+package bitqueue
+
+import (
+	"errors"
+)
+
+// ErrEmpty is returned when trying to pop from an empty queue.
+var ErrEmpty = errors.New("empty queue")
+
+// Queue represents a FIFO queue of fixed-width values.
+// It is not safe for concurrent use.
+type Queue struct {
+	width uint
+	mask  uint64
+	words []uint64
+	head  int // position of the first value
+	len   int
+}
+
+// New creates a new empty Queue for values with the given bit width, which must be between 1 and 64.
+func New(width uint) *Queue {
+	if width < 1 || width > 64 {
+		panic("invalid width")
+	}
+	return &Queue{
+		width: width,
+		mask:  ^uint64(0) >> (64 - width),
+	}
+}
+
+// Width returns the bit width of the values.
+func (q *Queue) Width() uint {
+	return q.width
+}
+
+// Len returns the number of values in the queue.
+func (q *Queue) Len() int {
+	return q.len
+}
+
+// Push appends the value to the end of the queue.
+// It panics if the value does not fit into the bit width.
+func (q *Queue) Push(v uint64) {
+	if v&^q.mask != 0 {
+		panic("value exceeds width")
+	}
+	if q.len == q.cap() {
+		q.grow()
+	}
+	q.set((q.head+q.len)%q.cap(), v)
+	q.len++
+}
+
+// Pop removes and returns the first value of the queue.
+// It panics with ErrEmpty if the queue is empty.
+func (q *Queue) Pop() uint64 {
+	v, err := q.TryPop()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// TryPop removes and returns the first value of the queue.
+// It returns ErrEmpty if the queue is empty.
+func (q *Queue) TryPop() (uint64, error) {
+	if q.len == 0 {
+		return 0, ErrEmpty
+	}
+	v := q.get(q.head)
+	q.head = (q.head + 1) % q.cap()
+	q.len--
+	return v, nil
+}
+
+// Index returns the i-th value of the queue, counting from the first.
+// It panics if i is out of range.
+func (q *Queue) Index(i int) uint64 {
+	if i < 0 || i >= q.len {
+		panic("index out of range")
+	}
+	return q.get((q.head + i) % q.cap())
+}
+
+// Bytes returns the number of bytes allocated for the values.
+func (q *Queue) Bytes() int {
+	return len(q.words) * 8
+}
+
+// cap returns the number of values that fit into the allocated words.
+func (q *Queue) cap() int {
+	return len(q.words) * 64 / int(q.width)
+}
+
+// grow doubles the allocated words, moving the values to the start.
+func (q *Queue) grow() {
+	n := 2 * len(q.words)
+	if n == 0 {
+		n = 1
+	}
+	old := *q
+	q.words = make([]uint64, n)
+	for i := 0; i < q.len; i++ {
+		q.set(i, old.Index(i))
+	}
+	q.head = 0
+}
+
+// get returns the value at the given position.
+func (q *Queue) get(pos int) uint64 {
+	bit := uint(pos) * q.width
+	w, off := bit/64, bit%64
+	v := q.words[w] >> off
+	if off+q.width > 64 {
+		v |= q.words[w+1] << (64 - off)
+	}
+	return v & q.mask
+}
+
+// set stores the value at the given position.
+func (q *Queue) set(pos int, v uint64) {
+	bit := uint(pos) * q.width
+	w, off := bit/64, bit%64
+	q.words[w] = q.words[w]&^(q.mask<<off) | v<<off
+	if off+q.width > 64 {
+		rem := off + q.width - 64
+		q.words[w+1] = q.words[w+1]&^(1<<rem-1) | v>>(64-off)
+	}
+}
@@ -0,0 +1,51 @@
+package bitqueue_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/bitqueue"
+)
+
+func TestQueue(t *testing.T) {
+	for _, width := range []uint{1, 4, 7, 13, 64} {
+		q := New(width)
+		var expected []uint64
+		r := rand.New(rand.NewSource(int64(width)))
+		for i := 0; i < 1000; i++ {
+			// interleave pushes and pops to wrap around the ring buffer
+			if r.Intn(3) == 0 && len(expected) > 0 {
+				assert.Equal(t, expected[0], q.Pop())
+				expected = expected[1:]
+				continue
+			}
+			v := r.Uint64() >> (64 - width)
+			q.Push(v)
+			expected = append(expected, v)
+		}
+		require.Equal(t, len(expected), q.Len())
+		for i, v := range expected {
+			assert.Equal(t, v, q.Index(i))
+		}
+	}
+}
+
+func TestQueue_Pop(t *testing.T) {
+	q := New(4)
+	assert.Panics(t, func() { q.Pop() })
+	_, err := q.TryPop()
+	assert.Equal(t, ErrEmpty, err)
+	assert.Panics(t, func() { q.Push(16) })
+	assert.Panics(t, func() { q.Index(0) })
+}
+
+func TestQueue_Bytes(t *testing.T) {
+	const n = 1 << 20
+	q := New(4)
+	for i := 0; i < n; i++ {
+		q.Push(uint64(i % 16))
+	}
+	assert.Equal(t, n/2, q.Bytes())
+}
@@ -0,0 +1,172 @@
+/*
+Package bloomring implements a probabilistic set of the keys seen within a sliding time window.
+
+A Ring consists of several Bloom filters, each covering an equal slice of the window. New keys are always added to
+the filter of the current slice, while a membership test checks all filters. Once a slice has passed, the filter of
+the oldest slice is cleared and reused for the next one, so that keys are forgotten automatically and the memory
+usage only depends on the number of keys expected per slice.
+
+Like a Bloom filter, the Ring has no false negatives within the window but a configurable rate of false positives.
+As the filters are rotated slice by slice, a key is remembered for at least window*(slices-1)/slices and at most
+window.
+*/
+package bloomring
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+// Ring is a rotating set of Bloom filters.
+// It is safe for concurrent use by multiple goroutines.
+type Ring struct {
+	interval time.Duration // duration of one slice
+	bits     uint64        // number of bits per filter
+	hashes   int           // number of hash functions
+	opts     options
+
+	mu      sync.Mutex // protects the following fields
+	filters [][]uint64
+	current int       // index of the filter of the current slice
+	start   time.Time // start of the current slice
+}
+
+// New creates a new Ring remembering keys for the given window divided into the given number of slices.
+// The filters are sized such that at most expected keys per slice result in the given false positive rate.
+func New(window time.Duration, slices int, expected int, fpRate float64, opts ...Option) *Ring {
+	if window <= 0 {
+		panic("non-positive window")
+	}
+	if slices < 1 {
+		panic("invalid number of slices")
+	}
+	if expected < 1 {
+		panic("invalid number of expected keys")
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		panic("invalid false positive rate")
+	}
+	// the false positive rate of the ring is bounded by the sum of the individual rates
+	p := fpRate / float64(slices)
+	m := math.Ceil(-float64(expected) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := int(math.Max(1, math.Round(m/float64(expected)*math.Ln2)))
+
+	r := &Ring{
+		interval: window / time.Duration(slices),
+		bits:     uint64(m),
+		hashes:   k,
+		opts:     options{now: time.Now},
+		filters:  make([][]uint64, slices),
+	}
+	for _, opt := range opts {
+		opt.apply(&r.opts)
+	}
+	for i := range r.filters {
+		r.filters[i] = make([]uint64, (r.bits+63)/64)
+	}
+	r.start = r.opts.now()
+	return r
+}
+
+// Add adds the given key to the ring.
+func (r *Ring) Add(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rotate()
+	r.add(hash(key))
+}
+
+// Contains returns whether the given key has been added within the window.
+// It may return true for keys that have never been added with the configured false positive rate.
+func (r *Ring) Contains(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rotate()
+	return r.contains(hash(key))
+}
+
+// Seen adds the given key to the ring and returns whether it has already been added within the window.
+// This allows for deduplicating keys with a single call.
+func (r *Ring) Seen(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rotate()
+	h := hash(key)
+	if r.contains(h) {
+		return true
+	}
+	r.add(h)
+	return false
+}
+
+// Reset removes all keys from the ring.
+func (r *Ring) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.filters {
+		zero(f)
+	}
+	r.start = r.opts.now()
+}
+
+// rotate clears the filters of all slices that have passed since the last call.
+// It must be called while holding the lock.
+func (r *Ring) rotate() {
+	passed := r.opts.now().Sub(r.start) / r.interval
+	if passed <= 0 {
+		return
+	}
+	r.start = r.start.Add(passed * r.interval)
+	if passed > time.Duration(len(r.filters)) {
+		passed = time.Duration(len(r.filters))
+	}
+	for ; passed > 0; passed-- {
+		r.current = (r.current + 1) % len(r.filters)
+		zero(r.filters[r.current])
+	}
+}
+
+// add sets the bits of the given hash in the current filter.
+func (r *Ring) add(h uint64) {
+	f := r.filters[r.current]
+	h1, h2 := h&math.MaxUint32, h>>32|1
+	for i := 0; i < r.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % r.bits
+		f[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// contains returns whether any filter has all bits of the given hash set.
+func (r *Ring) contains(h uint64) bool {
+	h1, h2 := h&math.MaxUint32, h>>32|1
+	for _, f := range r.filters {
+		i := 0
+		for ; i < r.hashes; i++ {
+			bit := (h1 + uint64(i)*h2) % r.bits
+			if f[bit/64]&(1<<(bit%64)) == 0 {
+				break
+			}
+		}
+		if i == r.hashes {
+			return true
+		}
+	}
+	return false
+}
+
+// hash returns the 64-bit FNV-1a hash of the given key.
+// Both halves are used to derive the positions of the hash functions using double hashing.
+func hash(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// zero sets all words of the given filter to zero.
+func zero(f []uint64) {
+	for i := range f {
+		f[i] = 0
+	}
+}
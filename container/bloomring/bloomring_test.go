@@ -0,0 +1,72 @@
+package bloomring_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/bloomring"
+)
+
+const (
+	testWindow = time.Minute
+	testSlices = 4
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestRing_Seen(t *testing.T) {
+	r := New(testWindow, testSlices, 100, 0.01)
+	assert.False(t, r.Contains("a"))
+	assert.False(t, r.Seen("a"))
+	assert.True(t, r.Seen("a"))
+	assert.True(t, r.Contains("a"))
+	assert.False(t, r.Contains("b"))
+
+	r.Reset()
+	assert.False(t, r.Contains("a"))
+}
+
+func TestRing_Rotate(t *testing.T) {
+	c := &clock{time.Unix(0, 0)}
+	r := New(testWindow, testSlices, 100, 0.01, WithClock(c.now))
+	r.Add("a")
+
+	// the key is remembered until its slice is rotated out
+	c.t = c.t.Add(testWindow - time.Nanosecond)
+	r.Add("b")
+	assert.True(t, r.Contains("a"))
+	c.t = c.t.Add(time.Nanosecond)
+	assert.False(t, r.Contains("a"))
+	assert.True(t, r.Contains("b"))
+
+	// skipping more than the window clears all filters
+	c.t = c.t.Add(10 * testWindow)
+	assert.False(t, r.Contains("b"))
+}
+
+func TestRing_FalsePositiveRate(t *testing.T) {
+	const (
+		expected = 1000
+		fpRate   = 0.01
+	)
+	r := New(testWindow, testSlices, expected, fpRate)
+	for i := 0; i < expected; i++ {
+		r.Add(fmt.Sprint(i))
+	}
+	for i := 0; i < expected; i++ {
+		assert.True(t, r.Contains(fmt.Sprint(i)))
+	}
+
+	var positives int
+	const n = 100000
+	for i := expected; i < expected+n; i++ {
+		if r.Contains(fmt.Sprint(i)) {
+			positives++
+		}
+	}
+	assert.Less(t, float64(positives)/n, fpRate)
+}
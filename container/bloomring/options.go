@@ -0,0 +1,29 @@
+package bloomring
+
+import (
+	"time"
+)
+
+// An Option configures a Ring.
+type Option interface {
+	apply(*options)
+}
+
+// optionFunc wraps a func so it satisfies the Option interface.
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+// options holds the configuration of a Ring.
+type options struct {
+	now func() time.Time
+}
+
+// WithClock configures the function used to query the current time.
+func WithClock(now func() time.Time) Option {
+	return optionFunc(func(o *options) {
+		o.now = now
+	})
+}
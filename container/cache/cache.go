@@ -0,0 +1,23 @@
+// Package cache defines the interface shared by the caches of this module.
+//
+// All caches hold a limited number of entries and differ only in which entry gets evicted when the cache is full.
+// Application code using the Cache interface can switch between the different eviction strategies without changes.
+package cache
+
+// Cache is a key-value store with a limited number of entries.
+type Cache[K comparable, V any] interface {
+	// Get returns the value stored for the given key.
+	// The second return value is false, when no such key exists.
+	Get(key K) (V, bool)
+
+	// Set stores the value for the given key, replacing any existing value.
+	// If the cache is full, another entry gets evicted.
+	Set(key K, value V)
+
+	// Delete removes the given key.
+	// It returns true, if an entry was removed or false when no such key exists.
+	Delete(key K) bool
+
+	// Len returns the number of entries contained in the cache.
+	Len() int
+}
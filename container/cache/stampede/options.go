@@ -0,0 +1,49 @@
+package stampede
+
+import (
+	"time"
+)
+
+// An Option configures a Cache.
+type Option interface {
+	apply(*options)
+}
+
+// optionFunc wraps a func so it satisfies the Option interface.
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+// options holds the configuration of a Cache.
+type options struct {
+	beta float64
+	now  func() time.Time
+	seed int64
+}
+
+// WithBeta configures the eagerness of the early expiration. Values larger than 1 favor earlier refreshes, values
+// smaller than 1 later ones; a value of 0 disables the early expiration. The default is 1.
+func WithBeta(beta float64) Option {
+	if beta < 0 {
+		panic("negative beta")
+	}
+	return optionFunc(func(o *options) {
+		o.beta = beta
+	})
+}
+
+// WithClock configures the function used to query the current time.
+func WithClock(now func() time.Time) Option {
+	return optionFunc(func(o *options) {
+		o.now = now
+	})
+}
+
+// WithSeed configures the seed of the random source used for the early expiration.
+func WithSeed(seed int64) Option {
+	return optionFunc(func(o *options) {
+		o.seed = seed
+	})
+}
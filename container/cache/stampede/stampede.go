@@ -0,0 +1,168 @@
+/*
+Package stampede implements a read-through cache that protects the backend from cache stampedes.
+
+A cache stampede (or thundering herd) happens when a frequently requested entry expires and many concurrent
+requests try to reload it from the backend at the same time. The cache prevents this in two ways:
+Concurrent loads of the same key are deduplicated, so that only one request calls the loader while the others wait
+for its result. Additionally, entries are refreshed probabilistically before they expire using the XFetch
+algorithm: The closer an entry gets to its expiry and the longer it took to load, the more likely a request
+refreshes it early, while all other requests are still served from the cache.
+
+See: Vattani, A., Chierichetti, F., & Lowenstein, K. (2015). Optimal probabilistic cache stampede prevention.
+*/
+package stampede
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/wollac/pkg/container/cache"
+)
+
+// ErrLoaderPanic is returned to the requests waiting for a load, when the loader panicked.
+var ErrLoaderPanic = errors.New("loader panicked")
+
+// Loader loads the value of the given key from the backend.
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// Item is a cached value together with the information required for the early expiration.
+type Item[V any] struct {
+	Value  V
+	Expiry time.Time     // time when the value expires
+	Delta  time.Duration // time it took to load the value
+}
+
+// Cache is a read-through cache with stampede protection.
+// It is safe for concurrent use by multiple goroutines.
+type Cache[K comparable, V any] struct {
+	load Loader[K, V]
+	ttl  time.Duration
+	opts options
+
+	mu      sync.Mutex // protects the following fields
+	backend cache.Cache[K, Item[V]]
+	rand    *rand.Rand
+	calls   map[K]*call[V]
+}
+
+// call represents an in-flight or completed load.
+type call[V any] struct {
+	done  chan struct{} // closed when the load has completed
+	value V
+	err   error
+}
+
+// New creates a new Cache storing the loaded values in backend for the duration ttl.
+// The backend must not be accessed directly while it is used by the cache.
+func New[K comparable, V any](backend cache.Cache[K, Item[V]], load Loader[K, V], ttl time.Duration, opts ...Option) *Cache[K, V] {
+	c := &Cache[K, V]{
+		load:    load,
+		ttl:     ttl,
+		opts:    options{beta: 1, now: time.Now, seed: time.Now().UnixNano()},
+		backend: backend,
+		calls:   make(map[K]*call[V]),
+	}
+	for _, opt := range opts {
+		opt.apply(&c.opts)
+	}
+	c.rand = rand.New(rand.NewSource(c.opts.seed))
+	return c
+}
+
+// Get returns the value of the given key, loading it when it is not cached or about to expire.
+// Only the first of several concurrent requests for the same key calls the loader with its context, the other
+// requests wait for the result or until their context is done.
+// If an early refresh fails, the still valid cached value is returned instead of the error.
+// If the loader panics, the panic is propagated to the request that called it, while the waiting requests receive
+// ErrLoaderPanic.
+func (c *Cache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	c.mu.Lock()
+	it, ok := c.backend.Get(key)
+	now := c.opts.now()
+	if ok && !c.refreshEarly(it, now) {
+		c.mu.Unlock()
+		return it.Value, nil
+	}
+	cl, loading := c.calls[key]
+	if !loading {
+		cl = &call[V]{done: make(chan struct{})}
+		c.calls[key] = cl
+	}
+	c.mu.Unlock()
+
+	if !loading {
+		c.do(ctx, key, cl)
+	}
+	select {
+	case <-cl.done:
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+	if cl.err != nil && ok && now.Before(it.Expiry) {
+		return it.Value, nil
+	}
+	return cl.value, cl.err
+}
+
+// Delete removes the given key from the cache.
+// It returns true, if an entry was removed or false when no such key exists.
+func (c *Cache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.backend.Delete(key)
+}
+
+// Len returns the number of entries contained in the cache.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.backend.Len()
+}
+
+// refreshEarly decides whether the given item should be reloaded using the XFetch algorithm.
+// It must be called while holding the lock.
+func (c *Cache[K, V]) refreshEarly(it Item[V], now time.Time) bool {
+	// -log(rand) is exponentially distributed, so the probability increases towards the expiry
+	gap := time.Duration(float64(it.Delta) * c.opts.beta * -math.Log(1-c.rand.Float64()))
+	return !now.Add(gap).Before(it.Expiry)
+}
+
+// do loads the value of the given key and stores it in the backend.
+// If the loader panics, the waiting requests receive ErrLoaderPanic and the panic is propagated to the caller.
+func (c *Cache[K, V]) do(ctx context.Context, key K, cl *call[V]) {
+	defer close(cl.done)
+	loaded := false
+	defer func() {
+		if loaded {
+			return
+		}
+		// the loader panicked or exited the goroutine, so the next request must load the key again
+		r := recover()
+		c.mu.Lock()
+		delete(c.calls, key)
+		c.mu.Unlock()
+		cl.err = ErrLoaderPanic
+		if r != nil {
+			cl.err = fmt.Errorf("%w: %v", ErrLoaderPanic, r)
+			panic(r)
+		}
+	}()
+
+	start := c.opts.now()
+	cl.value, cl.err = c.load(ctx, key)
+	end := c.opts.now()
+	loaded = true
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.calls, key)
+	if cl.err == nil {
+		c.backend.Set(key, Item[V]{Value: cl.value, Expiry: end.Add(c.ttl), Delta: end.Sub(start)})
+	}
+}
@@ -0,0 +1,197 @@
+package stampede_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/cache/stampede"
+	"github.com/wollac/pkg/container/lru"
+)
+
+const testCapacity = 10
+
+var errTest = errors.New("test")
+
+// clock is a manually advanced clock.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newCache(load Loader[string, int], ttl time.Duration, opts ...Option) *Cache[string, int] {
+	return New[string, int](lru.New[string, Item[int]](testCapacity), load, ttl, opts...)
+}
+
+func TestCache_Get(t *testing.T) {
+	var loads int32
+	load := func(_ context.Context, key string) (int, error) {
+		atomic.AddInt32(&loads, 1)
+		return len(key), nil
+	}
+	clk := &clock{now: time.Now()}
+	c := newCache(load, time.Minute, WithBeta(0), WithClock(clk.Now))
+
+	for i := 0; i < 3; i++ {
+		value, err := c.Get(context.Background(), "key")
+		require.NoError(t, err)
+		assert.Equal(t, 3, value)
+	}
+	assert.EqualValues(t, 1, loads)
+	assert.Equal(t, 1, c.Len())
+
+	// expired values are reloaded
+	clk.Advance(time.Minute)
+	_, err := c.Get(context.Background(), "key")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, loads)
+
+	assert.True(t, c.Delete("key"))
+	assert.False(t, c.Delete("key"))
+}
+
+func TestCache_GetError(t *testing.T) {
+	c := newCache(func(context.Context, string) (int, error) { return 0, errTest }, time.Minute)
+	_, err := c.Get(context.Background(), "key")
+	assert.Equal(t, errTest, err)
+	assert.Zero(t, c.Len())
+}
+
+func TestCache_GetPanic(t *testing.T) {
+	var loads int32
+	release := make(chan struct{})
+	load := func(context.Context, string) (int, error) {
+		if atomic.AddInt32(&loads, 1) == 1 {
+			<-release
+			panic("test")
+		}
+		return 1, nil
+	}
+	c := newCache(load, time.Minute)
+
+	// the panic is propagated to the caller of the loader and reported to the waiting requests
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.PanicsWithValue(t, "test", func() { _, _ = c.Get(context.Background(), "key") })
+	}()
+	time.Sleep(10 * time.Millisecond)
+	errc := make(chan error)
+	go func() {
+		_, err := c.Get(context.Background(), "key")
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	<-done
+	assert.True(t, errors.Is(<-errc, ErrLoaderPanic))
+
+	// the next request loads the key again
+	value, err := c.Get(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.EqualValues(t, 2, loads)
+}
+
+func TestCache_GetConcurrent(t *testing.T) {
+	const parallelism = 10
+
+	var loads int32
+	release := make(chan struct{})
+	load := func(_ context.Context, key string) (int, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return 1, nil
+	}
+	c := newCache(load, time.Minute)
+
+	var wg sync.WaitGroup
+	wg.Add(parallelism)
+	for i := 0; i < parallelism; i++ {
+		go func() {
+			defer wg.Done()
+			value, err := c.Get(context.Background(), "key")
+			assert.NoError(t, err)
+			assert.Equal(t, 1, value)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.EqualValues(t, 1, loads)
+}
+
+func TestCache_GetCanceled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	c := newCache(func(context.Context, string) (int, error) { <-release; return 1, nil }, time.Minute)
+
+	go func() { _, _ = c.Get(context.Background(), "key") }()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.Get(ctx, "key")
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestCache_EarlyRefresh(t *testing.T) {
+	clk := &clock{now: time.Now()}
+	var loads int32
+	load := func(context.Context, string) (int, error) {
+		atomic.AddInt32(&loads, 1)
+		clk.Advance(time.Second) // each load takes one second
+		return int(atomic.LoadInt32(&loads)), nil
+	}
+	c := newCache(load, time.Minute, WithClock(clk.Now), WithSeed(0))
+
+	_, err := c.Get(context.Background(), "key")
+	require.NoError(t, err)
+
+	// shortly before the expiry, the value is refreshed early with high probability
+	clk.Advance(time.Minute - time.Second)
+	for i := 0; i < 100 && atomic.LoadInt32(&loads) == 1; i++ {
+		_, err = c.Get(context.Background(), "key")
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, 2, loads)
+}
+
+func TestCache_EarlyRefreshError(t *testing.T) {
+	clk := &clock{now: time.Now()}
+	var fail bool
+	load := func(context.Context, string) (int, error) {
+		clk.Advance(time.Second)
+		if fail {
+			return 0, errTest
+		}
+		return 1, nil
+	}
+	c := newCache(load, time.Minute, WithClock(clk.Now), WithBeta(100))
+
+	_, err := c.Get(context.Background(), "key")
+	require.NoError(t, err)
+
+	// the still valid value is returned, when the early refresh fails
+	fail = true
+	value, err := c.Get(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+}
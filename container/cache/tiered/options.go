@@ -0,0 +1,29 @@
+package tiered
+
+// An Option configures a Cache.
+type Option interface {
+	apply(*options)
+}
+
+// optionFunc wraps a func so it satisfies the Option interface.
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+// options holds the configuration of a Cache.
+type options struct {
+	maxDirty int
+}
+
+// WithWriteBack configures the cache to only write to the first tier and to buffer the writes to the second tier.
+// The pending writes are flushed once maxPending writes have accumulated or when Flush is called.
+func WithWriteBack(maxPending int) Option {
+	if maxPending < 1 {
+		panic("non-positive number of pending writes")
+	}
+	return optionFunc(func(o *options) {
+		o.maxDirty = maxPending
+	})
+}
@@ -0,0 +1,120 @@
+/*
+Package tiered implements a cache composed of two cache tiers, e.g. a small but fast cache in front of a large but
+slow one.
+
+Lookups are first served by the first tier and fall back to the second tier, in which case the entry is promoted
+into the first tier. Writes are applied to both tiers either immediately (write-through) or the second tier is only
+updated when the pending writes are flushed (write-back).
+*/
+package tiered
+
+import (
+	"github.com/wollac/pkg/container/cache"
+)
+
+// Stats contains statistics about a tiered Cache.
+type Stats struct {
+	Hits1   uint64 // number of lookups served by the first tier
+	Hits2   uint64 // number of lookups served by the second tier
+	Misses  uint64 // number of lookups not found in any tier
+	Writes  uint64 // number of entries written to the second tier
+	Pending int    // number of pending writes in write-back mode
+}
+
+// Cache is a cache composed of two tiers.
+type Cache[K comparable, V any] struct {
+	l1, l2   cache.Cache[K, V]
+	maxDirty int // maximum number of pending writes, 0 means write-through
+
+	dirty map[K]V // pending writes to the second tier
+	stats Stats
+}
+
+var _ cache.Cache[string, int] = (*Cache[string, int])(nil)
+
+// New creates a new Cache with l1 as the first and l2 as the second tier.
+// By default, writes are applied to both tiers immediately.
+// The tiers must not be accessed directly while they are used by the cache.
+func New[K comparable, V any](l1, l2 cache.Cache[K, V], opts ...Option) *Cache[K, V] {
+	var o options
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	return &Cache[K, V]{
+		l1:       l1,
+		l2:       l2,
+		maxDirty: o.maxDirty,
+		dirty:    make(map[K]V, o.maxDirty),
+	}
+}
+
+// Get returns the value of the given key from the first tier containing it.
+// Values found in the second tier are promoted into the first tier.
+// The second return value is false, when no such key exists.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	if value, ok := c.l1.Get(key); ok {
+		c.stats.Hits1++
+		return value, true
+	}
+	// pending writes might have been evicted from the first tier
+	value, ok := c.dirty[key]
+	if !ok {
+		value, ok = c.l2.Get(key)
+	}
+	if !ok {
+		c.stats.Misses++
+		return value, false
+	}
+	c.stats.Hits2++
+	c.l1.Set(key, value)
+	return value, true
+}
+
+// Set stores the value for the given key in the first tier and, depending on the write policy, in the second tier.
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.l1.Set(key, value)
+	if c.maxDirty == 0 {
+		c.write(key, value)
+		return
+	}
+	c.dirty[key] = value
+	if len(c.dirty) >= c.maxDirty {
+		c.Flush()
+	}
+}
+
+// Delete removes the given key from both tiers.
+// It returns true, if an entry was removed or false when no such key exists.
+func (c *Cache[K, V]) Delete(key K) bool {
+	_, dirty := c.dirty[key]
+	delete(c.dirty, key)
+	ok1 := c.l1.Delete(key)
+	ok2 := c.l2.Delete(key)
+	return dirty || ok1 || ok2
+}
+
+// Len returns the number of entries contained in the second tier.
+// In write-back mode, this does not include pending writes until they have been flushed.
+func (c *Cache[K, V]) Len() int {
+	return c.l2.Len()
+}
+
+// Flush writes all pending writes to the second tier.
+func (c *Cache[K, V]) Flush() {
+	for key, value := range c.dirty {
+		c.write(key, value)
+		delete(c.dirty, key)
+	}
+}
+
+// Stats returns statistics about the cache.
+func (c *Cache[K, V]) Stats() Stats {
+	s := c.stats
+	s.Pending = len(c.dirty)
+	return s
+}
+
+func (c *Cache[K, V]) write(key K, value V) {
+	c.l2.Set(key, value)
+	c.stats.Writes++
+}
@@ -0,0 +1,91 @@
+package tiered_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/cache/tiered"
+	"github.com/wollac/pkg/container/lru"
+)
+
+const testCapacity = 10
+
+func newTiers() (*lru.Cache[string, int], *lru.Cache[string, int]) {
+	return lru.New[string, int](testCapacity / 2), lru.New[string, int](testCapacity)
+}
+
+func TestCache_WriteThrough(t *testing.T) {
+	l1, l2 := newTiers()
+	c := New[string, int](l1, l2)
+
+	for i := 0; i < testCapacity; i++ {
+		c.Set(fmt.Sprint(i), i)
+	}
+	assert.Equal(t, testCapacity, c.Len())
+	assert.Equal(t, testCapacity/2, l1.Len())
+
+	for i := 0; i < testCapacity; i++ {
+		value, ok := c.Get(fmt.Sprint(i))
+		assert.True(t, ok)
+		assert.Equal(t, i, value)
+	}
+	_, ok := c.Get("not contained")
+	assert.False(t, ok)
+
+	assert.Equal(t, Stats{Hits2: testCapacity, Misses: 1, Writes: testCapacity}, c.Stats())
+}
+
+func TestCache_Promotion(t *testing.T) {
+	l1, l2 := newTiers()
+	c := New[string, int](l1, l2)
+	l2.Set("key", 1)
+
+	_, ok := c.Get("key")
+	assert.True(t, ok)
+	_, ok = l1.Peek("key")
+	assert.True(t, ok)
+
+	_, ok = c.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, Stats{Hits1: 1, Hits2: 1}, c.Stats())
+}
+
+func TestCache_WriteBack(t *testing.T) {
+	l1, l2 := newTiers()
+	c := New[string, int](l1, l2, WithWriteBack(testCapacity))
+
+	for i := 0; i < testCapacity-1; i++ {
+		c.Set(fmt.Sprint(i), i)
+	}
+	assert.Zero(t, l2.Len())
+	assert.Equal(t, testCapacity-1, c.Stats().Pending)
+
+	// pending writes evicted from the first tier are not lost
+	value, ok := c.Get("0")
+	assert.True(t, ok)
+	assert.Equal(t, 0, value)
+
+	c.Set("last", -1)
+	assert.Equal(t, testCapacity, l2.Len())
+	assert.Zero(t, c.Stats().Pending)
+	assert.EqualValues(t, testCapacity, c.Stats().Writes)
+}
+
+func TestCache_Delete(t *testing.T) {
+	l1, l2 := newTiers()
+	c := New[string, int](l1, l2, WithWriteBack(testCapacity))
+	c.Set("1", 1)
+	c.Set("2", 2)
+	c.Flush()
+	c.Set("3", 3)
+
+	assert.False(t, c.Delete("not contained"))
+	for _, key := range []string{"1", "2", "3"} {
+		assert.True(t, c.Delete(key))
+		_, ok := c.Get(key)
+		assert.False(t, ok)
+	}
+	assert.Zero(t, c.Len())
+	assert.Zero(t, l1.Len())
+}
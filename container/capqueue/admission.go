@@ -0,0 +1,188 @@
+package capqueue
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"reflect"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// AdmissionPolicy decides whether a new entry is admitted to a full queue.
+type AdmissionPolicy[K comparable, V ordering.Ordered] interface {
+	// Record is called for every key that is added to the queue, regardless of whether it is admitted.
+	Record(key K)
+	// Admit returns whether the candidate should be added to the queue, evicting the victim.
+	Admit(candidate, victim Entry[K, V]) bool
+}
+
+const (
+	sketchDepth      = 4
+	maxSketchCounter = 15
+)
+
+// TinyLFU is an AdmissionPolicy that only admits candidates whose keys have been added more frequently than the
+// key of the victim. The frequencies are estimated using a count-min sketch, whose counters are halved
+// periodically, so that the estimates reflect the recent history.
+// A TinyLFU is not safe for concurrent use and must only be used by a single queue, so that it is protected by the
+// lock of a Sync queue. Every shard of a Sharded queue needs its own instance, see NewShardedFunc.
+//
+// See: Einziger, G., Friedman, R., & Manes, B. (2017). TinyLFU: A highly efficient cache admission policy.
+type TinyLFU[K comparable, V ordering.Ordered] struct {
+	rows       [sketchDepth][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+// NewTinyLFU creates a new TinyLFU policy with at least the given number of counters per row of the sketch.
+// The width should be in the order of the capacity of the queue.
+func NewTinyLFU[K comparable, V ordering.Ordered](width int) *TinyLFU[K, V] {
+	if width < 1 {
+		panic("invalid width")
+	}
+	w := 1
+	for w < width {
+		w *= 2
+	}
+	p := &TinyLFU[K, V]{
+		mask:       uint64(w - 1),
+		sampleSize: 10 * w,
+	}
+	for i := range p.rows {
+		p.rows[i] = make([]uint8, w)
+	}
+	return p
+}
+
+// Record increments the estimated frequency of the given key.
+func (p *TinyLFU[K, V]) Record(key K) {
+	h := hashKey(key)
+	for i := range p.rows {
+		if c := &p.rows[i][p.slot(h, i)]; *c < maxSketchCounter {
+			*c++
+		}
+	}
+	if p.additions++; p.additions == p.sampleSize {
+		p.reset()
+	}
+}
+
+// Clone returns an independent copy of the sketch.
+func (p *TinyLFU[K, V]) Clone() *TinyLFU[K, V] {
+	c := *p
+	for i := range c.rows {
+		c.rows[i] = append([]uint8(nil), p.rows[i]...)
+	}
+	return &c
+}
+
+// Admit returns whether the key of the candidate is estimated to be more frequent than the key of the victim.
+func (p *TinyLFU[K, V]) Admit(candidate, victim Entry[K, V]) bool {
+	return p.Frequency(candidate.Key) > p.Frequency(victim.Key)
+}
+
+// Frequency returns the estimated number of recent additions of the given key.
+func (p *TinyLFU[K, V]) Frequency(key K) int {
+	h := hashKey(key)
+	freq := math.MaxInt32
+	for i := range p.rows {
+		if c := int(p.rows[i][p.slot(h, i)]); c < freq {
+			freq = c
+		}
+	}
+	return freq
+}
+
+// reset halves all counters to age the frequencies.
+func (p *TinyLFU[K, V]) reset() {
+	for i := range p.rows {
+		for j := range p.rows[i] {
+			p.rows[i][j] /= 2
+		}
+	}
+	p.additions /= 2
+}
+
+// slot returns the counter of the given hash in the i-th row using double hashing.
+func (p *TinyLFU[K, V]) slot(h uint64, i int) uint64 {
+	return (h + uint64(i)*(h>>32|1)) & p.mask
+}
+
+// cloneAdmission returns an admission policy for a clone of a queue, which does not modify the given policy.
+// A TinyLFU is copied, any other policy is used without recording the keys of the clone.
+func cloneAdmission[K comparable, V ordering.Ordered](policy AdmissionPolicy[K, V]) AdmissionPolicy[K, V] {
+	switch p := policy.(type) {
+	case nil:
+		return nil
+	case *TinyLFU[K, V]:
+		return p.Clone()
+	default:
+		return readOnlyAdmission[K, V]{policy}
+	}
+}
+
+// readOnlyAdmission forwards the decisions to another policy, but does not record any keys.
+type readOnlyAdmission[K comparable, V ordering.Ordered] struct {
+	AdmissionPolicy[K, V]
+}
+
+func (readOnlyAdmission[K, V]) Record(K) {}
+
+// FNV-1a parameters, see hash/fnv.
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// hashKey returns the 64-bit FNV-1a hash of the given key.
+// Strings, integers and byte arrays are hashed directly without allocating. Keys of other types are hashed using
+// their default format, see fmt.
+func hashKey[K comparable](key K) uint64 {
+	if s, ok := interface{}(key).(string); ok {
+		return hashString(s)
+	}
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.String:
+		return hashString(v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return hashUint64(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return hashUint64(v.Uint())
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			h := uint64(fnvOffset64)
+			for i := 0; i < v.Len(); i++ {
+				h ^= v.Index(i).Uint()
+				h *= fnvPrime64
+			}
+			return h
+		}
+	}
+	h := fnv.New64a()
+	_, _ = fmt.Fprint(h, key)
+	return h.Sum64()
+}
+
+// hashString returns the 64-bit FNV-1a hash of s.
+func hashString(s string) uint64 {
+	h := uint64(fnvOffset64)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	return h
+}
+
+// hashUint64 returns the 64-bit FNV-1a hash of the little-endian bytes of x.
+func hashUint64(x uint64) uint64 {
+	h := uint64(fnvOffset64)
+	for i := 0; i < 8; i++ {
+		h ^= x & 0xff
+		h *= fnvPrime64
+		x >>= 8
+	}
+	return h
+}
@@ -0,0 +1,60 @@
+package capqueue_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestTinyLFU(t *testing.T) {
+	p := NewTinyLFU[string, int](testCapacity)
+	for i := 0; i < 3; i++ {
+		p.Record("frequent")
+	}
+	p.Record("rare")
+	assert.Equal(t, 3, p.Frequency("frequent"))
+	assert.Equal(t, 1, p.Frequency("rare"))
+	assert.True(t, p.Admit(Entry[string, int]{Key: "frequent"}, Entry[string, int]{Key: "rare"}))
+	assert.False(t, p.Admit(Entry[string, int]{Key: "rare"}, Entry[string, int]{Key: "frequent"}))
+
+	// the counters are aged periodically
+	for i := 0; i < 100*testCapacity; i++ {
+		p.Record("other")
+	}
+	assert.Less(t, p.Frequency("frequent"), 3)
+}
+
+func TestTinyLFUKeyTypes(t *testing.T) {
+	type id int
+	type digest [4]byte
+	type point struct{ x, y int }
+
+	ints := NewTinyLFU[id, int](testCapacity)
+	ints.Record(1)
+	ints.Record(1)
+	assert.Equal(t, 2, ints.Frequency(1))
+	assert.Zero(t, ints.Frequency(2))
+
+	digests := NewTinyLFU[digest, int](testCapacity)
+	digests.Record(digest{1, 2, 3, 4})
+	assert.Equal(t, 1, digests.Frequency(digest{1, 2, 3, 4}))
+	assert.Zero(t, digests.Frequency(digest{4, 3, 2, 1}))
+
+	points := NewTinyLFU[point, int](testCapacity)
+	points.Record(point{1, 2})
+	assert.Equal(t, 1, points.Frequency(point{1, 2}))
+	assert.Zero(t, points.Frequency(point{2, 1}))
+}
+
+func TestTinyLFUAllocs(t *testing.T) {
+	ints := NewTinyLFU[int, int](testCapacity)
+	strs := NewTinyLFU[string, int](testCapacity)
+	arrs := NewTinyLFU[[16]byte, int](testCapacity)
+	allocs := testing.AllocsPerRun(100, func() {
+		ints.Record(123456789)
+		strs.Record("key")
+		arrs.Record([16]byte{1})
+	})
+	assert.Zero(t, allocs)
+}
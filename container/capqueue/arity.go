@@ -0,0 +1,86 @@
+package capqueue
+
+// The heap of a queue is a d-ary heap, where d is configured using WithArity. The children of the item at index i are
+// stored at the indexes d*i+1 to d*i+d and its parent at (i-1)/d. For d = 2, the functions below perform exactly the
+// same operations as the functions of container/heap.
+
+// arity returns the number of children of each item of the heap.
+func (h *CapQueue[K, V]) arity() int {
+	if h.opts.arity == 0 {
+		return 2
+	}
+	return h.opts.arity
+}
+
+// children returns the range [lo, hi) of the indexes of the children of the item at index i.
+func (h *CapQueue[K, V]) children(i int) (lo, hi int) {
+	d := h.arity()
+	lo = d*i + 1
+	return lo, min(lo+d, len(h.heap))
+}
+
+// parent returns the index of the parent of the item at index i > 0.
+func (h *CapQueue[K, V]) parent(i int) int {
+	return (i - 1) / h.arity()
+}
+
+// firstLeaf returns the index of the first item of the heap without children.
+func (h *CapQueue[K, V]) firstLeaf() int {
+	if len(h.heap) <= 1 {
+		return 0
+	}
+	return h.parent(len(h.heap)-1) + 1
+}
+
+// heapInit establishes the heap ordering in O(n).
+func (h *CapQueue[K, V]) heapInit() {
+	n := len(h.heap)
+	for i := h.firstLeaf() - 1; i >= 0; i-- {
+		h.down(i, n)
+	}
+}
+
+// heapPop removes and returns the root of the heap.
+func (h *CapQueue[K, V]) heapPop() *item[K, V] {
+	n := len(h.heap) - 1
+	h.heap.Swap(0, n)
+	h.down(0, n)
+	return h.heap.Pop().(*item[K, V])
+}
+
+// up moves the item at index j towards the root until its parent is higher.
+func (h *CapQueue[K, V]) up(j int) {
+	for j > 0 {
+		i := h.parent(j)
+		if !h.higher(h.heap[j], h.heap[i]) {
+			break
+		}
+		h.heap.Swap(i, j)
+		j = i
+	}
+}
+
+// down moves the item at index i0 towards the leaves of the first n items until no child is higher.
+// It returns whether the item has been moved.
+func (h *CapQueue[K, V]) down(i0, n int) bool {
+	d := h.arity()
+	i := i0
+	for {
+		j1 := d*i + 1
+		if j1 >= n || j1 < 0 { // j1 < 0 after int overflow
+			break
+		}
+		j := j1 // highest child
+		for c := j1 + 1; c < j1+d && c < n; c++ {
+			if h.higher(h.heap[c], h.heap[j]) {
+				j = c
+			}
+		}
+		if !h.higher(h.heap[j], h.heap[i]) {
+			break
+		}
+		h.heap.Swap(i, j)
+		i = j
+	}
+	return i > i0
+}
@@ -0,0 +1,80 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestWithArity(t *testing.T) {
+	const capacity = 100
+	for _, d := range []int{2, 3, 4, 8} {
+		for name, opts := range map[string][]Option[string, int]{
+			"default": nil,
+			"fixup":   {WithFixupBudget[string, int](1)},
+			"lazy":    {WithLazyDeletion[string, int]()},
+		} {
+			t.Run(fmt.Sprint(name, "/d=", d), func(t *testing.T) {
+				q := New[string, int](capacity, append(opts, WithArity[string, int](d))...)
+				r := rand.New(rand.NewSource(0))
+				for i := 0; i < 10*capacity; i++ {
+					key := fmt.Sprint(r.Intn(2 * capacity))
+					switch r.Intn(4) {
+					case 0:
+						q.Delete(key)
+					case 1:
+						q.Update(key, r.Intn(capacity))
+					default:
+						q.Add(key, r.Intn(capacity))
+					}
+					_, value := q.Max()
+					require.Equal(t, maxValue(q.Entries()), value)
+				}
+
+				_, minValue := q.Min()
+				assert.Equal(t, q.Entries()[q.Len()-1].Value, minValue)
+				assert.Equal(t, entryValues(q.Entries()[:10]), entryValues(q.TopK(10)))
+				assert.Len(t, q.AllAbove(capacity/2), q.CountAbove(capacity/2))
+				for q.Len() > 0 {
+					_, value := q.PopMax()
+					if q.Len() > 0 {
+						require.GreaterOrEqual(t, value, maxValue(q.Entries()))
+					}
+				}
+			})
+		}
+	}
+
+	assert.Panics(t, func() { WithArity[string, int](1) })
+}
+
+func BenchmarkCapQueue_Arity(b *testing.B) {
+	const capacity = 1 << 17
+	for _, d := range []int{2, 4, 8} {
+		q := New[int, int](capacity, WithArity[int, int](d))
+		r := rand.New(rand.NewSource(0))
+		for i := 0; i < capacity; i++ {
+			q.Add(i, r.Int())
+		}
+		data := make([]int, capacity)
+		for i := range data {
+			data[i] = r.Int()
+		}
+
+		b.Run(fmt.Sprint("Add/d=", d), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				q.Add(capacity+i, data[i%capacity])
+			}
+		})
+		b.Run(fmt.Sprint("PopMax/d=", d), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				q.PopMax()
+				q.Add(-i-1, data[i%capacity])
+			}
+		})
+	}
+}
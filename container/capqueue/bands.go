@@ -0,0 +1,121 @@
+package capqueue
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// bandSet maintains a separate heap for each priority band.
+type bandSet[K comparable, V ordering.Ordered] struct {
+	bounds []V
+	heaps  []bandHeap[K, V]
+	next   int // band that is served next by PopFair
+}
+
+// bandHeap is a max-heap of the items within one band.
+type bandHeap[K comparable, V ordering.Ordered] struct {
+	items  []*item[K, V]
+	higher func(a, b *item[K, V]) bool // ordering of the queue
+}
+
+func newBandSet[K comparable, V ordering.Ordered](bounds []V, higher func(a, b *item[K, V]) bool) *bandSet[K, V] {
+	b := &bandSet[K, V]{
+		bounds: bounds,
+		heaps:  make([]bandHeap[K, V], len(bounds)+1),
+		next:   len(bounds),
+	}
+	for i := range b.heaps {
+		b.heaps[i].higher = higher
+	}
+	return b
+}
+
+// PopFair removes and returns the entry with the highest value of the next non-empty priority band.
+// The bands are served in a round-robin fashion starting with the highest band, so that a flood of high-priority
+// entries cannot starve entries in lower bands. Without the WithPriorityBands option, all entries belong to the
+// same band and PopFair removes the maximum.
+// This will panic if the queue is empty.
+func (h *CapQueue[K, V]) PopFair() (K, V) {
+	key, value, err := h.TryPopFair()
+	if err != nil {
+		panic(err)
+	}
+	return key, value
+}
+
+// TryPopFair removes and returns the entry with the highest value of the next non-empty priority band.
+// In contrast to PopFair, it returns ErrEmpty instead of panicking if the queue is empty.
+func (h *CapQueue[K, V]) TryPopFair() (K, V, error) {
+	h.expire()
+	if h.size() == 0 {
+		var key K
+		var value V
+		return key, value, ErrEmpty
+	}
+	it := h.top()
+	if b := h.bands; b != nil {
+		for len(b.heaps[b.next].items) == 0 {
+			b.advance()
+		}
+		it = b.heaps[b.next].items[0]
+		b.advance()
+	}
+	key, value := it.key, it.value
+	h.remove(it)
+	return key, value, nil
+}
+
+// advance moves to the next lower band, wrapping around to the highest band.
+func (b *bandSet[K, V]) advance() {
+	if b.next == 0 {
+		b.next = len(b.heaps)
+	}
+	b.next--
+}
+
+func (b *bandSet[K, V]) add(it *item[K, V]) {
+	it.band = sort.Search(len(b.bounds), func(i int) bool { return b.bounds[i] > it.value })
+	heap.Push(&b.heaps[it.band], it)
+}
+
+func (b *bandSet[K, V]) remove(it *item[K, V]) {
+	heap.Remove(&b.heaps[it.band], it.bandIndex)
+}
+
+// fix updates the band of the item after its value has changed.
+func (b *bandSet[K, V]) fix(it *item[K, V]) {
+	b.remove(it)
+	b.add(it)
+}
+
+func (h *bandHeap[K, V]) Len() int {
+	return len(h.items)
+}
+
+func (h *bandHeap[K, V]) Less(i, j int) bool {
+	return h.higher(h.items[i], h.items[j])
+}
+
+func (h *bandHeap[K, V]) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].bandIndex = i
+	h.items[j].bandIndex = j
+}
+
+func (h *bandHeap[K, V]) Push(x interface{}) {
+	it := x.(*item[K, V])
+	it.bandIndex = len(h.items)
+	h.items = append(h.items, it)
+}
+
+func (h *bandHeap[K, V]) Pop() interface{} {
+	old := h.items
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil // avoid memory leak
+	it.bandIndex = -1
+	h.items = old[0 : n-1]
+	return it
+}
@@ -0,0 +1,55 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestCapQueue_PopFair(t *testing.T) {
+	q := New[string, int](testCapacity, WithPriorityBands[string, int](10, 100))
+	assert.Panics(t, func() { _, _ = q.PopFair() })
+
+	for _, v := range []int{1000, 500, 200, 50, 5, 2} {
+		q.Add(fmt.Sprint(v), v)
+	}
+	var values []int
+	for q.Len() > 0 {
+		_, v := q.PopFair()
+		values = append(values, v)
+	}
+	assert.Equal(t, []int{1000, 50, 5, 500, 2, 200}, values)
+
+	_, _, err := q.TryPopFair()
+	assert.Equal(t, ErrEmpty, err)
+}
+
+func TestCapQueue_PopFairWithoutBands(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	for i := testCapacity; i > 0; i-- {
+		_, v := q.PopFair()
+		assert.Equal(t, i, v)
+	}
+}
+
+func TestCapQueue_PopFairEviction(t *testing.T) {
+	q := New[string, int](2, WithPriorityBands[string, int](0))
+	q.Add("a", -1)
+	q.Add("b", 1)
+	q.Add("c", 2) // evicts a, so only the upper band remains
+
+	_, v := q.PopFair()
+	assert.Equal(t, 2, v)
+	_, v = q.PopFair()
+	assert.Equal(t, 1, v)
+}
+
+func TestWithPriorityBands(t *testing.T) {
+	assert.Panics(t, func() { WithPriorityBands[string, int]() })
+	assert.Panics(t, func() { WithPriorityBands[string, int](2, 1) })
+}
@@ -0,0 +1,28 @@
+package capqueue
+
+// BeginBatch freezes the queue until the matching call of EndBatch.
+// While the queue is frozen, all mutations are buffered and the queue keeps presenting the state at the time of
+// BeginBatch, so that it can be iterated consistently while being modified without creating a copy. Methods that
+// report the outcome of a mutation, like Remove, report it with respect to the frozen state.
+// Batches can be nested, the buffered mutations are applied when the outermost batch ends.
+func (h *CapQueue[K, V]) BeginBatch() {
+	h.batchDepth++
+}
+
+// EndBatch ends a batch started by BeginBatch. When the outermost batch ends, all buffered mutations are applied
+// in the order in which they were performed.
+// This will panic if no batch is active.
+func (h *CapQueue[K, V]) EndBatch() {
+	if h.batchDepth == 0 {
+		panic("no active batch")
+	}
+	h.batchDepth--
+	if h.batchDepth > 0 {
+		return
+	}
+	pending := h.pending
+	h.pending = nil
+	for _, f := range pending {
+		f()
+	}
+}
@@ -0,0 +1,49 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestCapQueue_Batch(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity/2; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	entries := q.Entries()
+
+	q.BeginBatch()
+	for _, e := range q.OldestK(testCapacity) {
+		// mutations during the iteration are buffered
+		value, ok := q.Remove(e.Key)
+		assert.True(t, ok)
+		assert.Equal(t, e.Value, value)
+		q.Add(e.Key+"'", -e.Value)
+		assert.Equal(t, entries, q.Entries())
+	}
+	q.EndBatch()
+
+	assert.Equal(t, testCapacity/2, q.Len())
+	for _, e := range entries {
+		assert.Zero(t, q.Value(e.Key))
+		assert.Equal(t, -e.Value, q.Value(e.Key+"'"))
+	}
+}
+
+func TestCapQueue_BatchNested(t *testing.T) {
+	q := New[string, int](testCapacity)
+	q.BeginBatch()
+	q.Add("1", 1)
+	q.BeginBatch()
+	q.MergeMap(map[string]int{"1": 2})
+	q.EndBatch()
+	assert.Zero(t, q.Len())
+	q.EndBatch()
+
+	assert.Equal(t, 1, q.Len())
+	assert.Equal(t, 2, q.Value("1"))
+	assert.Panics(t, q.EndBatch)
+}
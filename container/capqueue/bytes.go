@@ -0,0 +1,53 @@
+package capqueue
+
+import (
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// The following functions accept keys as byte slices for queues with string keys, e.g. when the keys are read from
+// the network. Lookups do not convert the key into a string, so ValueBytes, DeleteBytes and AddBytes for a contained
+// key do not allocate. The key is only copied when AddBytes inserts a new entry, or for every call, if a key
+// normalizer is configured, as the normalizer receives the key as a string and may retain it.
+
+// AddBytes adds a new key-value pair to the queue like CapQueue.Add.
+func AddBytes[V ordering.Ordered](h *CapQueue[string, V], key []byte, value V) {
+	if it, ok := lookupBytes(h, key); ok {
+		h.Add(it.key, value) // reuse the stored key instead of copying it
+		return
+	}
+	h.Add(string(key), value)
+}
+
+// ValueBytes returns the value of the given key like CapQueue.Value.
+func ValueBytes[V ordering.Ordered](h *CapQueue[string, V], key []byte) V {
+	h.expire()
+	it, ok := lookupBytes(h, key)
+	h.countLookup(ok)
+	if !ok {
+		return h.missingValue
+	}
+	h.accessed(it)
+	return it.value
+}
+
+// DeleteBytes removes the element with the given key like CapQueue.Delete.
+func DeleteBytes[V ordering.Ordered](h *CapQueue[string, V], key []byte) bool {
+	it, ok := lookupBytes(h, key)
+	if ok {
+		h.remove(it)
+	}
+	return ok
+}
+
+// lookupBytes returns the item with the given key.
+func lookupBytes[V ordering.Ordered](h *CapQueue[string, V], key []byte) (*item[string, V], bool) {
+	if h.normalizeKey != nil {
+		return h.lookup(string(key))
+	}
+	if h.table != nil {
+		return h.table.getBytes(key)
+	}
+	// the compiler does not allocate a string for the conversion in a map index expression
+	it, ok := h.index[string(key)]
+	return it, ok
+}
@@ -0,0 +1,47 @@
+package capqueue_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestBytes(t *testing.T) {
+	q := New[string, int](testCapacity, WithZeroValueSentinel[string, int](-1))
+	key := []byte("key")
+	AddBytes(q, key, 1)
+	key[0] = 'K' // the queue must not retain the slice
+	assert.Equal(t, 1, q.Value("key"))
+	assert.Equal(t, -1, ValueBytes(q, key))
+
+	key[0] = 'k'
+	assert.Equal(t, 1, ValueBytes(q, key))
+	assert.True(t, DeleteBytes(q, key))
+	assert.False(t, DeleteBytes(q, key))
+	assert.Zero(t, q.Len())
+}
+
+func TestBytesNormalizer(t *testing.T) {
+	q := New[string, int](testCapacity, WithKeyNormalizer[string, int](strings.ToLower))
+	AddBytes(q, []byte("KEY"), 1)
+	assert.Equal(t, 1, ValueBytes(q, []byte("Key")))
+	AddBytes(q, []byte("Key"), 2)
+	assert.Equal(t, 1, q.Len())
+	assert.Equal(t, 2, q.Value("key"))
+	assert.True(t, DeleteBytes(q, []byte("kEY")))
+}
+
+func TestBytesAllocs(t *testing.T) {
+	q := New[string, int](testCapacity)
+	key := []byte("a long key that does not fit into a small buffer")
+	AddBytes(q, key, 1)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = ValueBytes(q, key)
+		_ = DeleteBytes(q, []byte("missing key"))
+		AddBytes(q, key, 2) // the key is contained, so it is not copied
+	})
+	assert.Zero(t, allocs)
+}
@@ -0,0 +1,35 @@
+package capqueue
+
+import (
+	"github.com/wollac/pkg/container/cache"
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// cacheAdapter exposes a CapQueue through the cache.Cache interface.
+type cacheAdapter[K comparable, V ordering.Ordered] struct {
+	q *CapQueue[K, V]
+}
+
+// AsCache returns a cache.Cache backed by the given queue.
+// Get is counted as a lookup and an access of the entry like CapQueue.Get. Setting a key that is already contained
+// updates the existing entry like CapQueue.AddOrUpdate. When the queue is full, the entry selected by the eviction
+// policy of the queue gets evicted, see WithEviction.
+func AsCache[K comparable, V ordering.Ordered](q *CapQueue[K, V]) cache.Cache[K, V] {
+	return cacheAdapter[K, V]{q: q}
+}
+
+func (c cacheAdapter[K, V]) Get(key K) (V, bool) {
+	return c.q.Get(key)
+}
+
+func (c cacheAdapter[K, V]) Set(key K, value V) {
+	c.q.AddOrUpdate(key, value)
+}
+
+func (c cacheAdapter[K, V]) Delete(key K) bool {
+	return c.q.Delete(key)
+}
+
+func (c cacheAdapter[K, V]) Len() int {
+	return c.q.Len()
+}
@@ -0,0 +1,70 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestAsCache(t *testing.T) {
+	q := New[string, int](testCapacity)
+	c := AsCache(q)
+
+	_, ok := c.Get("0")
+	assert.False(t, ok)
+
+	c.Set("0", 0)
+	value, ok := c.Get("0")
+	assert.True(t, ok)
+	assert.Zero(t, value)
+
+	// setting an existing key replaces it
+	c.Set("0", 1)
+	assert.Equal(t, 1, c.Len())
+	value, _ = c.Get("0")
+	assert.Equal(t, 1, value)
+
+	for i := 1; i <= testCapacity; i++ {
+		c.Set(fmt.Sprint(i), i)
+	}
+	assert.Equal(t, testCapacity, c.Len())
+	_, ok = c.Get("0")
+	assert.False(t, ok)
+
+	assert.True(t, c.Delete("1"))
+	assert.False(t, c.Delete("1"))
+	assert.Equal(t, testCapacity-1, q.Len())
+}
+
+func TestAsCache_Eviction(t *testing.T) {
+	q := New[string, int](2, WithEviction[string, int](EvictLRU), WithRates[string, int]())
+	c := AsCache(q)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	_, _ = c.Get("a") // a becomes the most recently used entry
+	c.Set("c", 3)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	assert.InDelta(t, 2.0/3, q.Stats().HitRatio.Minute, 1e-9)
+}
+
+func TestAsCache_TTL(t *testing.T) {
+	q := New[string, int](testCapacity)
+	c := AsCache(q)
+	q.AddWithTTL("a", 1, testTTL)
+	time.Sleep(testTTL)
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	// setting an existing key keeps its expiry time
+	q.AddWithTTL("b", 1, testTTL)
+	c.Set("b", 2)
+	time.Sleep(testTTL)
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+}
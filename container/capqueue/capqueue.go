@@ -1,159 +1,1265 @@
 /*
 Package capqueue implements a key-value priority queue with limited number of entries.
 This differs from a standard heap in that it maintains a doubly-linked list running through all of its entries.
-When a new entry is added to a full queue, the oldest element (not the element with lowest priority) gets deleted.
+When a new entry is added to a full queue, the oldest element (not the element with lowest priority) gets deleted,
+unless a different policy is configured using WithEviction.
+A queue with a capacity of zero is unbounded and never evicts any elements.
+The keys can be of any comparable type and the values of any ordered type, i.e. integers, floating-point numbers or
+strings. The entries are ordered by their values, unless a custom ordering is configured using WithLess or
+WithMinOrder.
 
-The underlying heap implementation uses container/heap which is based on a binary heap, providing O(log n)
+Each key is contained at most once. Adding a key that is already contained replaces its entry in place instead of
+adding a second one: the value is changed, the entry becomes the newest one and its time of addition is refreshed,
+so that it is evicted last. Use AddOrUpdate to keep the remaining properties of the existing entry, like its
+tiebreak or payload.
+
+Accessing the elements of an empty queue using Max or First panics. Long-running servers that cannot tolerate
+panics from library code should use the corresponding Try variants, which return ErrEmpty instead.
+
+The underlying heap is a binary heap by default, or a d-ary heap configured using WithArity, providing O(log n)
 complexity for q.Add() and q.Remove() and O(1) for q.Max().
 */
 package capqueue
 
 import (
 	"container/heap"
-	"container/list"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+var (
+	// ErrEmpty is returned when an element of an empty queue is accessed.
+	ErrEmpty = errors.New("empty queue")
+	// ErrKeyTooLong is returned when a key exceeds the maximum key length of a preallocated queue.
+	ErrKeyTooLong = errors.New("key too long")
+	// ErrInvalidSnapshot is returned when a snapshot cannot be restored.
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
 )
 
 // CapQueue represents a priority queue with limited number of entries.
-type CapQueue struct {
-	heap binHeap
+type CapQueue[K comparable, V ordering.Ordered] struct {
+	heap binHeap[K, V]
 	cap  int
+	opts options[K, V]
+
+	maxCost   int                      // maximum total cost of the entries, only used with NewWeighted
+	totalCost int                      // total cost of the entries
+	costFn    func(key K, value V) int // cost of an entry, nil unless created using NewWeighted
+
+	index map[K]*item[K, V]
+	table *hashIndex[K, V] // replaces index, only used with WithCompactIndex
+	order itemList[K, V]
+	seq   uint64 // sequence number of the most recently linked item
+
+	missingValue V                           // value returned for missing keys
+	normalizeKey func(K) K                   // optional key normalizer
+	admission    AdmissionPolicy[K, V]       // optional admission policy
+	overflow     *CapQueue[K, V]             // optional queue receiving the evicted entries
+	onEvict      func(key K, value V)        // optional callback receiving the evicted entries
+	less         func(a, b Entry[K, V]) bool // optional ordering of the entries
+
+	maxHooks []*maxHook // called whenever the maximum of the queue changes
+	history  *maxHistory[K, V]
+	bands    *bandSet[K, V]
+	values   *valueIndex[K, V]
+	median   *medianSet[K, V] // halves of the values, only used with WithMedian
+	freqs    *freqList[K, V]  // use counts of the items, only used with EvictLFU
+	expiries expiryHeap[K, V] // items with an expiry time ordered by expiry
+	rates    *rateSet
+
+	batchDepth int      // number of active batches
+	pending    []func() // mutations buffered during a batch
 
-	index map[string]*item
-	order *list.List
+	tombstones int           // number of deleted items still contained in the heap
+	dirty      []*item[K, V] // items with a deferred heap fix-up, only used with WithFixupBudget
+
+	maxKeyLen int         // maximum length of a key, 0 means unlimited
+	free      *item[K, V] // list of unused items linked by next
+
+	seed        int64
+	rand        *rand.Rand
+	adds        uint64
+	evictions   uint64
+	rejections  uint64
+	expirations uint64
+}
+
+// Entry represents a key-value pair contained in a CapQueue.
+type Entry[K comparable, V ordering.Ordered] struct {
+	Key       K
+	Value     V
+	Tiebreak  int       // secondary priority deciding between entries with equal value
+	AddedAt   time.Time // time when the entry was added to the queue
+	ExpiresAt time.Time // time when the entry expires, zero if it never expires
+	// Payload is arbitrary data stored alongside the entry, see AddWithPayload.
+	// It is not included in the JSON and CBOR encodings.
+	Payload interface{} `json:"-"`
 }
 
 // item represents one entry of CapQueue.
-type item struct {
-	*list.Element // position of the item in the list
+type item[K comparable, V ordering.Ordered] struct {
+	prev, next *item[K, V] // position of the item in the insertion order
+
+	key       K
+	value     V
+	tiebreak  int
+	addedAt   time.Time
+	expiresAt time.Time
+	payload   interface{}
+	index     int  // index of the item in the heap<
+	deleted   bool // whether the item is a tombstone
+
+	band      int // priority band of the item, only used with WithPriorityBands
+	bandIndex int // index of the item in the heap of its band
+
+	dirty      bool // whether the heap fix-up of the item has been deferred
+	dirtyIndex int  // index of the item in the list of dirty items
+
+	node *valueNode[K, V] // node of the item in the value index, only used with WithValueIndex
+	seq  uint64           // position of the item in the insertion order
+
+	bucket           *freqBucket[K, V] // bucket of the use count of the item, only used with EvictLFU
+	prevUse, nextUse *item[K, V]       // position of the item in its bucket
+
+	expiryIndex int // index of the item in the expiry heap, only used if the item has an expiry time
+	cost        int // cost of the entry, only used by queues created using NewWeighted
 
-	key   string
-	value int
-	index int // index of the item in the heap<
+	medianIndex int  // index of the item in its heap of the median set, only used with WithMedian
+	medianHigh  bool // whether the item belongs to the upper half of the values
+}
+
+// maxHook is a callback that is called whenever the maximum of a queue changes.
+type maxHook struct {
+	f func()
 }
 
 // binary heap of the items
-type binHeap []*item
+type binHeap[K comparable, V ordering.Ordered] []*item[K, V]
 
-// New crates a new CapQueue instance.
-func New(cap int) *CapQueue {
-	h := &CapQueue{
-		heap:  make(binHeap, 0, cap),
-		cap:   cap,
-		index: make(map[string]*item, cap),
-		order: list.New(),
+// New crates a new CapQueue instance ordering the entries by values of type V.
+// If cap is zero, the queue is unbounded and its heap grows dynamically. Otherwise, the items for cap entries are
+// allocated up front. Items of removed entries are kept in a free list and reused, so that a steady state of
+// additions and deletions does not allocate.
+// This will panic if cap is negative.
+func New[K comparable, V ordering.Ordered](cap int, opts ...Option[K, V]) *CapQueue[K, V] {
+	if cap < 0 {
+		panic("negative capacity")
+	}
+	h := &CapQueue[K, V]{
+		heap: make(binHeap[K, V], 0, cap),
+		cap:  cap,
+	}
+	h.order.init()
+	h.preallocate(cap)
+	for _, opt := range opts {
+		opt.apply(&h.opts)
+	}
+	h.seed = time.Now().UnixNano()
+	if h.opts.seed != nil {
+		h.seed = *h.opts.seed
+	}
+	h.rand = rand.New(rand.NewSource(h.seed))
+	if h.opts.compactIndex {
+		h.table = newHashIndex[K, V](cap, h.seed)
+	} else {
+		h.index = make(map[K]*item[K, V], cap)
+	}
+	h.missingValue = h.opts.missingValue
+	h.normalizeKey = h.opts.normalizeKey
+	h.admission = h.opts.admission
+	h.overflow = h.opts.overflow
+	h.onEvict = h.opts.onEvict
+	h.less = lessOption(&h.opts)
+	if h.opts.bandBounds != nil {
+		h.bands = newBandSet(h.opts.bandBounds, h.higher)
+	}
+	if h.opts.fixupBudget > 0 && h.opts.lazyDeletion {
+		panic("fix-up budget cannot be combined with lazy deletion")
+	}
+	if h.opts.rates {
+		h.rates = newRateSet()
+	}
+	if h.opts.valueIndex {
+		h.values = newValueIndex[K, V](h.seed)
+	}
+	if h.opts.median {
+		h.median = newMedianSet[K, V]()
+	}
+	if h.opts.eviction == EvictLFU {
+		h.freqs = newFreqList[K, V]()
+	}
+	if h.opts.maxHistory > 0 {
+		h.history = &maxHistory[K, V]{records: make([]MaxRecord[K, V], 0, h.opts.maxHistory)}
+		h.addMaxHook(h.recordMax)
+	}
+	if h.opts.onMaxChange != nil {
+		h.addMaxHook(h.opts.onMaxChange)
 	}
-	heap.Init(&h.heap)
 	return h
 }
 
 // Add adds a new key-value pair to the queue.
+// If the queue already contains the key, its value is replaced and it becomes the newest entry. Otherwise, if the
+// queue is already full, the oldest element gets removed, unless the entry is rejected by the admission policy
+// configured using WithAdmission.
+// This will panic if the key is longer than the maximum key length of a preallocated queue.
+func (h *CapQueue[K, V]) Add(key K, value V) {
+	if err := h.add(Entry[K, V]{Key: key, Value: value, AddedAt: time.Now()}); err != nil {
+		panic(err)
+	}
+}
+
+// TryAdd adds a new key-value pair to the queue.
+// In contrast to Add, it returns ErrKeyTooLong instead of panicking if the key is too long.
+func (h *CapQueue[K, V]) TryAdd(key K, value V) error {
+	return h.add(Entry[K, V]{Key: key, Value: value, AddedAt: time.Now()})
+}
+
+// AddOrUpdate adds a new key-value pair to the queue like Add, if the key is not contained. Otherwise, the existing
+// entry gets the new value and becomes the newest entry with a refreshed time of addition, while it keeps its
+// tiebreak, expiry time and payload. It returns whether an existing entry was updated.
+// This will panic if the key is longer than the maximum key length of a preallocated queue.
+func (h *CapQueue[K, V]) AddOrUpdate(key K, value V) bool {
+	now := time.Now()
+	e := Entry[K, V]{Key: key, Value: value, AddedAt: now}
+	it, exists := h.lookup(key)
+	if exists = exists && !it.expired(now); exists {
+		e.Tiebreak, e.ExpiresAt, e.Payload = it.tiebreak, it.expiresAt, it.payload
+	}
+	if err := h.add(e); err != nil {
+		panic(err)
+	}
+	return exists
+}
+
+// AddWithTiebreak adds a new key-value pair with a secondary priority to the queue.
+// Entries are compared lexicographically by (value, tiebreak), i.e. when two entries have the same value, the one
+// with the higher tiebreak has the higher priority. Entries added using Add have a tiebreak of 0.
 // If the queue is already full, the oldest element gets removed.
-func (h *CapQueue) Add(key string, value int) {
-	var it *item
+func (h *CapQueue[K, V]) AddWithTiebreak(key K, value V, tiebreak int) {
+	if err := h.add(Entry[K, V]{Key: key, Value: value, Tiebreak: tiebreak, AddedAt: time.Now()}); err != nil {
+		panic(err)
+	}
+}
+
+// AddIfBetter adds a new key-value pair to the queue like Add, but only if the queue is not full or the entry has a
+// higher priority than the entry returned by Min. Otherwise, the entry is rejected and counted in Stats.Rejections,
+// so that a low-value newcomer cannot push out a better entry. When the entry is added to a full queue, the evicted
+// entry is still chosen by the eviction policy. Keys already contained in the queue are always updated.
+// It returns whether the entry was added. While a batch is active, the addition is buffered and true is returned.
+// This will panic if the key is longer than the maximum key length of a preallocated queue.
+func (h *CapQueue[K, V]) AddIfBetter(key K, value V) bool {
+	e := Entry[K, V]{Key: h.normalize(key), Value: value, AddedAt: time.Now()}
+	if h.maxKeyLen > 0 && keyLen(e.Key) > h.maxKeyLen {
+		panic(ErrKeyTooLong)
+	}
+	if h.batchDepth > 0 {
+		h.pending = append(h.pending, func() { h.addIfBetter(e) })
+		return true
+	}
+	return h.addIfBetter(e)
+}
+
+// addIfBetter inserts the entry with a normalized key, unless it would replace a better entry.
+func (h *CapQueue[K, V]) addIfBetter(e Entry[K, V]) bool {
+	h.expire()
+	_, exists := h.find(e.Key)
+	if full := h.full() || h.exceedsCost(h.costOf(e)); !exists && full && !h.outranks(e, h.bottom()) {
+		h.rejections++
+		return false
+	}
+	h.insert(e)
+	_, added := h.find(e.Key) // the entry may still be rejected by the admission policy
+	return added
+}
+
+// AddEvict adds a new key-value pair to the queue like Add and returns the key-value pair that was evicted to make
+// room for it. The last return value is false, if no entry was evicted. With WithEvictionBatch, the oldest of the
+// evicted entries is returned; use WithEvictCallback to observe all of them. While a batch is active, the addition
+// is buffered and no eviction is reported.
+// This will panic if the key is longer than the maximum key length of a preallocated queue.
+func (h *CapQueue[K, V]) AddEvict(key K, value V) (K, V, bool) {
+	evicted, ok, err := h.addEvict(Entry[K, V]{Key: key, Value: value, AddedAt: time.Now()})
+	if err != nil {
+		panic(err)
+	}
+	return evicted.Key, evicted.Value, ok
+}
+
+// add adds the given entry to the queue.
+func (h *CapQueue[K, V]) add(e Entry[K, V]) error {
+	_, _, err := h.addEvict(e)
+	return err
+}
+
+// addEvict adds the given entry to the queue and returns the entry that was evicted for it, if any.
+func (h *CapQueue[K, V]) addEvict(e Entry[K, V]) (Entry[K, V], bool, error) {
+	e.Key = h.normalize(e.Key)
+	if h.maxKeyLen > 0 && keyLen(e.Key) > h.maxKeyLen {
+		return Entry[K, V]{}, false, ErrKeyTooLong
+	}
+	if h.batchDepth > 0 {
+		h.pending = append(h.pending, func() { h.insert(e) })
+		return Entry[K, V]{}, false, nil
+	}
+	evicted, ok := h.insert(e)
+	return evicted, ok, nil
+}
+
+// insert inserts the given entry with a normalized key into the queue.
+// It returns the entry that was evicted first to make room for the new one, if any.
+func (h *CapQueue[K, V]) insert(e Entry[K, V]) (evicted Entry[K, V], ok bool) {
+	defer h.trackMax()()
+
+	h.expire() // expired entries must not cause evictions
+
+	cost := h.costOf(e)
+	if h.costFn != nil && cost > h.maxCost {
+		h.rejections++ // the entry cannot fit, even if all other entries are evicted
+		return
+	}
+
+	if it, exists := h.find(e.Key); exists {
+		// replace the existing entry instead of adding a second item with the same key
+		if h.admission != nil {
+			h.admission.Record(e.Key)
+		}
+		h.countAdd()
+		h.detach(it)
+		it.set(e)
+		it.cost = cost
+		h.attach(it) // before fixing the heap, which depends on the insertion order with WithStableOrder
+		h.heapFix(it)
+		h.pruneRoot()
+		h.accessed(it)
+		return h.fitCost(0)
+	}
+
+	var it *item[K, V]
+	full := h.full() || h.exceedsCost(cost)
+	if policy := h.admission; policy != nil {
+		policy.Record(e.Key)
+		if full && !policy.Admit(e, h.victim().entry()) {
+			h.rejections++
+			return
+		}
+	}
+	if full && h.opts.eviction == EvictLowest && !h.outranks(e, h.victim()) {
+		h.rejections++
+		return
+	}
+	h.countAdd()
+	if full && h.opts.evictBatch > 1 {
+		evicted, ok = h.victim().entry(), true
+		h.evict(h.opts.evictBatch)
+	}
+	if first, evictedCost := h.fitCost(cost); evictedCost && !ok {
+		evicted, ok = first, true
+	}
 	// assure that there is always space in the heap
-	if h.Len() == h.cap {
-		it = h.first()
-		h.order.Remove(it.Element)
-		delete(h.index, it.key)
+	if h.full() {
+		it = h.victim()
+		evicted, ok = it.entry(), true
+		h.evicted(it)
+		h.unlink(it)
 		// replace with new key/value
-		it.key = key
-		it.value = value
-		heap.Fix(&h.heap, it.index)
+		it.set(e)
+		it.cost = cost
+		h.link(it)
+		h.heapFix(it)
+		h.pruneRoot()
 	} else {
 		// create a new item
-		it = &item{key: key, value: value}
-		heap.Push(&h.heap, it)
+		it = h.newItem()
+		it.set(e)
+		it.cost = cost
+		h.link(it)
+		h.heapPush(it)
+		if h.size()-1 == h.softLimit() && h.opts.onSoftLimit != nil {
+			defer h.opts.onSoftLimit(h.size())
+		}
+	}
+	return evicted, ok
+}
+
+// link adds the new item to the queue.
+func (h *CapQueue[K, V]) link(it *item[K, V]) {
+	h.attach(it)
+	if h.freqs != nil {
+		h.freqs.add(it)
+	}
+}
+
+// unlink removes the item from the queue, but not from the heap.
+func (h *CapQueue[K, V]) unlink(it *item[K, V]) {
+	h.detach(it)
+	if h.freqs != nil {
+		h.freqs.remove(it)
+	}
+}
+
+// attach adds the item to the index and the insertion order.
+func (h *CapQueue[K, V]) attach(it *item[K, V]) {
+	h.indexAdd(it)
+	h.totalCost += it.cost
+	h.order.pushBack(it)
+	h.seq++
+	it.seq = h.seq
+	if h.bands != nil {
+		h.bands.add(it)
+	}
+	if h.values != nil {
+		h.values.add(it)
+	}
+	if h.median != nil {
+		h.median.add(it)
+	}
+	if !it.expiresAt.IsZero() {
+		heap.Push(&h.expiries, it)
+	}
+}
+
+// detach removes the item from the index and the insertion order, but keeps its use count.
+// It must be followed by attach.
+func (h *CapQueue[K, V]) detach(it *item[K, V]) {
+	h.indexRemove(it)
+	h.totalCost -= it.cost
+	h.order.remove(it)
+	if h.bands != nil {
+		h.bands.remove(it)
+	}
+	if h.values != nil {
+		h.values.remove(it)
+	}
+	if h.median != nil {
+		h.median.remove(it)
+	}
+	if !it.expiresAt.IsZero() {
+		heap.Remove(&h.expiries, it.expiryIndex)
+	}
+}
+
+// evict removes the next k victims from the queue and rebuilds the heap once.
+func (h *CapQueue[K, V]) evict(k int) {
+	for i := 0; i < k && h.size() > 0; i++ {
+		it := h.victim()
+		h.evicted(it)
+		h.unlink(it)
+		if h.opts.eviction == EvictLowest {
+			// the next victim is searched in the heap, which must therefore remain valid
+			h.heapRemove(it)
+			h.release(it)
+			continue
+		}
+		it.index = -1 // mark as removed
+	}
+	h.rebuild()
+}
+
+// evicted counts the eviction of the given item, reports it to the evict callback and cascades its entry into the
+// overflow queue. It must be called before the item is modified.
+func (h *CapQueue[K, V]) evicted(it *item[K, V]) {
+	h.countEviction()
+	if h.onEvict != nil {
+		h.onEvict(it.key, it.value)
+	}
+	if h.overflow != nil {
+		_ = h.overflow.add(it.entry()) // keys that are too long for the overflow queue are dropped
 	}
-	// add the item to the map and list
-	h.index[key] = it
-	it.Element = h.order.PushBack(it)
+}
+
+// rebuild removes all evicted and deleted items from the heap and restores the heap ordering in O(n).
+func (h *CapQueue[K, V]) rebuild() {
+	n := 0
+	for _, it := range h.heap {
+		if it.index < 0 || it.deleted {
+			h.release(it)
+			continue
+		}
+		it.index = n
+		h.heap[n] = it
+		n++
+	}
+	for i := n; i < len(h.heap); i++ {
+		h.heap[i] = nil // avoid memory leak
+	}
+	h.heap = h.heap[:n]
+	h.tombstones = 0
+	h.cleanAll()
+	h.heapInit()
+}
+
+// full returns whether the queue is bounded and has reached its capacity.
+func (h *CapQueue[K, V]) full() bool {
+	return h.cap > 0 && h.size() >= h.cap
+}
+
+// softLimit returns the number of entries above which the soft limit callback is triggered.
+func (h *CapQueue[K, V]) softLimit() int {
+	if h.cap == 0 {
+		return math.MaxInt // unbounded queues have no soft limit
+	}
+	if h.opts.headroom > h.cap {
+		return 0
+	}
+	return h.cap - h.opts.headroom
 }
 
 // Delete removes the element with the given key.
 // It returns true, if an element was removed or false when no element with the given key exists.
-func (h *CapQueue) Delete(key string) bool {
-	it, ok := h.index[key]
+func (h *CapQueue[K, V]) Delete(key K) bool {
+	_, ok := h.Remove(key)
+	return ok
+}
+
+// Update changes the value of the given key and restores the heap ordering in O(log n).
+// In contrast to Add, the entry keeps its tiebreak and its position in the insertion order.
+// It returns false, if the queue does not contain the key.
+func (h *CapQueue[K, V]) Update(key K, value V) bool {
+	it, ok := h.lookup(key)
+	if ok {
+		h.update(it, value)
+	}
+	return ok
+}
+
+// Touch makes the entry with the given key the newest entry of the queue without changing its value, so that it is
+// evicted last, e.g. to retain recently used entries longer. Its time of addition is set to the current time.
+// It returns false, if the queue does not contain the key.
+func (h *CapQueue[K, V]) Touch(key K) bool {
+	it, ok := h.lookup(key)
 	if !ok {
 		return false
 	}
+	if h.batchDepth > 0 {
+		key := it.key
+		h.pending = append(h.pending, func() { h.Touch(key) })
+		return true
+	}
+	defer h.trackMax()()
 
-	delete(h.index, it.key)
-	h.order.Remove(it.Element)
-	heap.Remove(&h.heap, it.index)
+	h.touch(it, time.Now())
+	// the ordering may depend on the time of addition or the insertion order
+	h.heapFix(it)
+	h.pruneRoot()
 	return true
 }
 
+// Remove removes the element with the given key and returns its value.
+// The second return value is false, when no element with the given key exists.
+func (h *CapQueue[K, V]) Remove(key K) (V, bool) {
+	it, ok := h.lookup(key)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	value := it.value
+	h.remove(it)
+	return value, true
+}
+
+// remove removes the given item from the queue.
+// The item must not be used afterwards, as it might get reused.
+func (h *CapQueue[K, V]) remove(it *item[K, V]) {
+	if h.batchDepth > 0 {
+		key := it.key
+		h.pending = append(h.pending, func() {
+			if it, ok := h.find(key); ok {
+				h.remove(it)
+			}
+		})
+		return
+	}
+	defer h.trackMax()()
+
+	h.unlink(it)
+	if h.opts.lazyDeletion {
+		// only mark the item as deleted, it is removed from the heap when it reaches the root
+		it.deleted = true
+		h.tombstones++
+		h.pruneRoot()
+		if float64(h.tombstones) > h.compactionThreshold()*float64(len(h.heap)) {
+			h.rebuild()
+		}
+		return
+	}
+	h.heapRemove(it)
+	h.release(it)
+}
+
+// Get returns the value of the given key.
+// The second return value is false, when no element with the given key exists.
+func (h *CapQueue[K, V]) Get(key K) (V, bool) {
+	h.expire()
+	it, ok := h.lookup(key)
+	h.countLookup(ok)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	h.accessed(it)
+	return it.value, true
+}
+
+// Contains returns whether the queue contains an element with the given key.
+// In contrast to Get, it is not counted as a lookup by the hit ratio of the queue.
+func (h *CapQueue[K, V]) Contains(key K) bool {
+	h.expire()
+	_, ok := h.lookup(key)
+	return ok
+}
+
 // Value returns the value of the given key or 0 if no such key exists.
-func (h *CapQueue) Value(key string) int {
-	it, ok := h.index[key]
+// The value returned for missing keys can be changed using the WithZeroValueSentinel option.
+//
+// Deprecated: The value of a missing key cannot be distinguished from a stored value. Use Get instead.
+func (h *CapQueue[K, V]) Value(key K) V {
+	h.expire()
+	it, ok := h.lookup(key)
+	h.countLookup(ok)
 	if !ok {
-		return 0
+		return h.missingValue
 	}
+	h.accessed(it)
 	return it.value
 }
 
 // Len returns the number of elements contained in the queue.
-// The number of elements will never be larger than the initial capacity of the queue.
-func (h *CapQueue) Len() int {
-	return h.heap.Len()
+// The number of elements will never be larger than the capacity of a bounded queue.
+func (h *CapQueue[K, V]) Len() int {
+	h.expire()
+	return h.size()
+}
+
+// size returns the number of elements contained in the queue, including expired ones.
+func (h *CapQueue[K, V]) size() int {
+	return h.heap.Len() - h.tombstones
 }
 
-// Cap returns the maximum capacity of the queue.
-func (h *CapQueue) Cap() int {
+// Cap returns the maximum capacity of the queue or zero if the queue is unbounded.
+func (h *CapQueue[K, V]) Cap() int {
 	return h.cap
 }
 
+// SetCap changes the capacity of the queue to n.
+// When the capacity of a bounded queue grows, the heap is reallocated to hold n elements and the additional items
+// are allocated up front. When it shrinks, entries are evicted like by Add until the queue contains
+// at most n elements; the memory is retained and can be released using ShrinkToFit. If n is zero, the queue
+// becomes unbounded.
+// This will panic if n is negative or if n is zero for a queue created by NewPreallocated.
+func (h *CapQueue[K, V]) SetCap(n int) {
+	if n < 0 {
+		panic("negative capacity")
+	}
+	if n == 0 && h.maxKeyLen > 0 {
+		panic("preallocated queue cannot be unbounded")
+	}
+	if h.batchDepth > 0 {
+		h.pending = append(h.pending, func() { h.SetCap(n) })
+		return
+	}
+	defer h.trackMax()()
+
+	if k := h.size() - n; n > 0 && k > 0 {
+		h.evict(k)
+	}
+	if n > cap(h.heap) {
+		grown := make(binHeap[K, V], len(h.heap), n)
+		copy(grown, h.heap)
+		h.heap = grown
+	}
+	if h.cap > 0 && n > h.cap {
+		h.preallocate(n - h.cap)
+	}
+	if h.table != nil && slotsFor(n) > len(h.table.slots) {
+		h.table.resize(slotsFor(n))
+	}
+	h.cap = n
+}
+
 // Max returns the key-value pair with the highest value.
 // This will panic if the queue is empty.
-func (h *CapQueue) Max() (string, int) {
-	if h.Len() == 0 {
-		panic("empty queue")
+func (h *CapQueue[K, V]) Max() (K, V) {
+	key, value, err := h.TryMax()
+	if err != nil {
+		panic(err)
+	}
+	return key, value
+}
+
+// TryMax returns the key-value pair with the highest value.
+// In contrast to Max, it returns ErrEmpty instead of panicking if the queue is empty.
+func (h *CapQueue[K, V]) TryMax() (K, V, error) {
+	h.expire()
+	if h.size() == 0 {
+		var key K
+		var value V
+		return key, value, ErrEmpty
+	}
+	it := h.top()
+	h.accessed(it)
+	return it.key, it.value, nil
+}
+
+// PopMax removes and returns the key-value pair with the highest value in O(log n).
+// This will panic if the queue is empty.
+func (h *CapQueue[K, V]) PopMax() (K, V) {
+	key, value, err := h.TryPopMax()
+	if err != nil {
+		panic(err)
+	}
+	return key, value
+}
+
+// TryPopMax removes and returns the key-value pair with the highest value.
+// In contrast to PopMax, it returns ErrEmpty instead of panicking if the queue is empty.
+func (h *CapQueue[K, V]) TryPopMax() (K, V, error) {
+	h.expire()
+	if h.size() == 0 {
+		var key K
+		var value V
+		return key, value, ErrEmpty
+	}
+	it := h.top()
+	key, value := it.key, it.value
+	h.remove(it)
+	return key, value, nil
+}
+
+// Min returns the key-value pair with the lowest value.
+// With WithValueIndex, this takes O(log n) time. Otherwise, the leaves of the heap are scanned in O(n) time, or all
+// entries if the heap contains tombstones or deferred fix-ups.
+// This will panic if the queue is empty.
+func (h *CapQueue[K, V]) Min() (K, V) {
+	key, value, err := h.TryMin()
+	if err != nil {
+		panic(err)
+	}
+	return key, value
+}
+
+// TryMin returns the key-value pair with the lowest value.
+// In contrast to Min, it returns ErrEmpty instead of panicking if the queue is empty.
+func (h *CapQueue[K, V]) TryMin() (K, V, error) {
+	h.expire()
+	if h.size() == 0 {
+		var key K
+		var value V
+		return key, value, ErrEmpty
+	}
+	it := h.bottom()
+	return it.key, it.value, nil
+}
+
+// bottom returns the item with the lowest priority of a non-empty queue.
+func (h *CapQueue[K, V]) bottom() *item[K, V] {
+	if h.values != nil && h.less == nil && !h.opts.stableOrder {
+		n := h.values.root
+		for n.left != nil {
+			n = n.left
+		}
+		return n.it
+	}
+
+	// the minimum of a valid max-heap is one of its leaves
+	var items []*item[K, V]
+	if h.tombstones == 0 && len(h.dirty) == 0 {
+		items = h.heap[h.firstLeaf():]
+	} else {
+		items = h.heap
+	}
+	var low *item[K, V]
+	for _, it := range items {
+		if !it.deleted && (low == nil || h.higher(low, it)) {
+			low = it
+		}
 	}
-	it := h.heap[0]
-	return it.key, it.value
+	return low
 }
 
 // First returns the oldest key-value pair.
 // This returns the element that was added to the queue first, not the one with the lowest value.
 // If more than capacity elements are added to the queue, the oldest element gets removed.
-func (h *CapQueue) First() (string, int) {
-	if h.Len() == 0 {
-		panic("empty queue")
+// This will panic if the queue is empty.
+func (h *CapQueue[K, V]) First() (K, V) {
+	key, value, err := h.TryFirst()
+	if err != nil {
+		panic(err)
+	}
+	return key, value
+}
+
+// TryFirst returns the oldest key-value pair.
+// In contrast to First, it returns ErrEmpty instead of panicking if the queue is empty.
+func (h *CapQueue[K, V]) TryFirst() (K, V, error) {
+	h.expire()
+	if h.size() == 0 {
+		var key K
+		var value V
+		return key, value, ErrEmpty
+	}
+	it := h.first()
+	return it.key, it.value, nil
+}
+
+// Last returns the newest key-value pair, i.e. the element that was added to the queue most recently.
+// This will panic if the queue is empty.
+func (h *CapQueue[K, V]) Last() (K, V) {
+	key, value, err := h.TryLast()
+	if err != nil {
+		panic(err)
+	}
+	return key, value
+}
+
+// TryLast returns the newest key-value pair.
+// In contrast to Last, it returns ErrEmpty instead of panicking if the queue is empty.
+func (h *CapQueue[K, V]) TryLast() (K, V, error) {
+	h.expire()
+	if h.size() == 0 {
+		var key K
+		var value V
+		return key, value, ErrEmpty
+	}
+	it := h.order.back()
+	return it.key, it.value, nil
+}
+
+// PopFirst removes and returns the oldest key-value pair, which allows to consume the entries in insertion order.
+// This will panic if the queue is empty.
+func (h *CapQueue[K, V]) PopFirst() (K, V) {
+	key, value, err := h.TryPopFirst()
+	if err != nil {
+		panic(err)
+	}
+	return key, value
+}
+
+// TryPopFirst removes and returns the oldest key-value pair.
+// In contrast to PopFirst, it returns ErrEmpty instead of panicking if the queue is empty.
+func (h *CapQueue[K, V]) TryPopFirst() (K, V, error) {
+	h.expire()
+	if h.size() == 0 {
+		var key K
+		var value V
+		return key, value, ErrEmpty
 	}
 	it := h.first()
-	return it.key, it.value
+	key, value := it.key, it.value
+	h.remove(it)
+	return key, value, nil
+}
+
+// PeekEvictee returns the key-value pair that would be evicted by the next addition of a new key.
+// The last return value is false, if the queue is not full and nothing would be evicted.
+// With WithEvictionBatch, the next addition evicts further entries following the returned one.
+func (h *CapQueue[K, V]) PeekEvictee() (K, V, bool) {
+	h.expire()
+	if !h.full() || h.size() == 0 {
+		var key K
+		var value V
+		return key, value, false
+	}
+	it := h.victim()
+	return it.key, it.value, true
+}
+
+// Entries returns a snapshot of all entries contained in the queue.
+// The entries are ordered by descending priority, i.e. the first entry is the one returned by Max. Entries with the
+// same priority are returned in unspecified order, or from oldest to newest with WithStableOrder. Use OldestK to get
+// all entries from oldest to newest.
+// The entries are sorted on a copy of the heap in O(n log n), so the queue itself is not modified.
+func (h *CapQueue[K, V]) Entries() []Entry[K, V] {
+	h.expire()
+	eh := h.newEntryHeap()
+	entries := make([]Entry[K, V], eh.Len())
+	for i := range entries {
+		entries[i] = heap.Pop(eh).(Entry[K, V])
+	}
+	return entries
+}
+
+// entries returns all entries ordered from oldest to newest.
+func (h *CapQueue[K, V]) entries() []Entry[K, V] {
+	entries := make([]Entry[K, V], 0, h.size())
+	for it := h.order.front(); it != nil; it = h.order.next(it) {
+		entries = append(entries, it.entry())
+	}
+	return entries
+}
+
+// Keys returns a snapshot of the keys of all entries contained in the queue.
+// The keys are ordered from oldest to newest.
+func (h *CapQueue[K, V]) Keys() []K {
+	h.expire()
+	keys := make([]K, 0, h.size())
+	for it := h.order.front(); it != nil; it = h.order.next(it) {
+		keys = append(keys, it.key)
+	}
+	return keys
+}
+
+// ForEach calls f for each entry contained in the queue, ordered from oldest to newest, until f returns false.
+// The queue may be modified by f: ForEach runs in a batch (see BeginBatch), so f sees and iterates the queue as it
+// was when ForEach was called, and all its mutations are applied after the iteration has finished.
+func (h *CapQueue[K, V]) ForEach(f func(key K, value V) bool) {
+	h.expire()
+	h.BeginBatch()
+	defer h.EndBatch()
+	for it := h.order.front(); it != nil; it = h.order.next(it) {
+		if !f(it.key, it.value) {
+			return
+		}
+	}
+}
+
+// OldestK returns up to k of the oldest entries, starting with the oldest one.
+// These are the entries that get evicted next when new elements are added to a full queue.
+func (h *CapQueue[K, V]) OldestK(k int) []Entry[K, V] {
+	h.expire()
+	entries := make([]Entry[K, V], 0, min(k, h.size()))
+	for it := h.order.front(); it != nil && len(entries) < k; it = h.order.next(it) {
+		entries = append(entries, it.entry())
+	}
+	return entries
+}
+
+// NewestK returns up to k of the most recently added entries, starting with the newest one.
+func (h *CapQueue[K, V]) NewestK(k int) []Entry[K, V] {
+	h.expire()
+	entries := make([]Entry[K, V], 0, min(k, h.size()))
+	for it := h.order.back(); it != nil && len(entries) < k; it = h.order.prev(it) {
+		entries = append(entries, it.entry())
+	}
+	return entries
+}
+
+// DrainSorted removes all entries from the queue and returns them ordered by descending priority, as if PopMax was
+// called until the queue is empty. Expired entries are not returned. Like with Clear, the memory is retained.
+func (h *CapQueue[K, V]) DrainSorted() []Entry[K, V] {
+	h.expire()
+	entries := h.Entries()
+	h.Clear()
+	return entries
+}
+
+// Clear removes all elements from the queue.
+// In contrast to creating a new queue, the memory of the heap and the index is retained for subsequent additions.
+// The statistics of the queue are not reset.
+func (h *CapQueue[K, V]) Clear() {
+	if h.batchDepth > 0 {
+		h.pending = append(h.pending, h.Clear)
+		return
+	}
+	defer h.trackMax()()
+
+	for i, it := range h.heap {
+		h.heap[i] = nil // avoid memory leaks
+		h.release(it)
+	}
+	h.heap = h.heap[:0]
+	if h.table != nil {
+		h.table.clear()
+	}
+	for key := range h.index {
+		delete(h.index, key)
+	}
+	h.order.init()
+	h.tombstones = 0
+	h.totalCost = 0
+	for i := range h.expiries {
+		h.expiries[i] = nil
+	}
+	h.expiries = h.expiries[:0]
+	for i := range h.dirty {
+		h.dirty[i] = nil
+	}
+	h.dirty = h.dirty[:0]
+	if b := h.bands; b != nil {
+		for i := range b.heaps {
+			bh := &b.heaps[i]
+			for j := range bh.items {
+				bh.items[j] = nil
+			}
+			bh.items = bh.items[:0]
+		}
+		b.next = len(b.bounds)
+	}
+	if h.values != nil {
+		h.values.root = nil
+	}
+	if h.median != nil {
+		h.median.clear()
+	}
+	if h.freqs != nil {
+		h.freqs.init()
+	}
+}
+
+// ShrinkToFit releases memory that is no longer needed after entries have been removed.
+// It reallocates the heap and the index to the current number of elements and drops the unused items. The heap grows
+// again on demand, so subsequent additions may allocate until the queue has reached its capacity again.
+// ShrinkToFit has no effect on queues created by NewPreallocated.
+func (h *CapQueue[K, V]) ShrinkToFit() {
+	if h.batchDepth > 0 {
+		h.pending = append(h.pending, h.ShrinkToFit)
+		return
+	}
+	if h.maxKeyLen > 0 {
+		return // preallocated queues keep their memory
+	}
+	if h.tombstones > 0 {
+		h.rebuild()
+	}
+	h.free = nil
+	n := h.size()
+	if cap(h.heap) > n {
+		shrunk := make(binHeap[K, V], n)
+		copy(shrunk, h.heap)
+		h.heap = shrunk
+	}
+	if h.table != nil {
+		h.table.resize(slotsFor(n))
+		return
+	}
+	index := make(map[K]*item[K, V], n)
+	for key, it := range h.index {
+		index[key] = it
+	}
+	h.index = index
+}
+
+// lookup returns the item with the given key.
+func (h *CapQueue[K, V]) lookup(key K) (*item[K, V], bool) {
+	return h.find(h.normalize(key))
+}
+
+// find returns the item with the given normalized key.
+func (h *CapQueue[K, V]) find(key K) (*item[K, V], bool) {
+	if h.table != nil {
+		return h.table.get(key)
+	}
+	it, ok := h.index[key]
+	return it, ok
+}
+
+// indexAdd adds the item to the index of the keys.
+func (h *CapQueue[K, V]) indexAdd(it *item[K, V]) {
+	if h.table != nil {
+		h.table.put(it)
+		return
+	}
+	h.index[it.key] = it
+}
+
+// indexRemove removes the item from the index of the keys.
+func (h *CapQueue[K, V]) indexRemove(it *item[K, V]) {
+	if h.table != nil {
+		h.table.remove(it.key)
+		return
+	}
+	delete(h.index, it.key)
+}
+
+// normalize applies the configured key normalizer to the given key.
+func (h *CapQueue[K, V]) normalize(key K) K {
+	if h.normalizeKey == nil {
+		return key
+	}
+	return h.normalizeKey(key)
+}
+
+// update changes the value of the given item and restores the heap ordering.
+func (h *CapQueue[K, V]) update(it *item[K, V], value V) {
+	if h.batchDepth > 0 {
+		key := it.key
+		h.pending = append(h.pending, func() {
+			if it, ok := h.find(key); ok {
+				h.update(it, value)
+			}
+		})
+		return
+	}
+	defer h.trackMax()()
+
+	h.setValue(it, value)
+	h.heapFix(it)
+	h.pruneRoot()
+	h.fitCost(0)
+}
+
+// setValue changes the value of the given item and updates the secondary indexes, but not the heap.
+func (h *CapQueue[K, V]) setValue(it *item[K, V], value V) {
+	if h.values != nil {
+		h.values.remove(it)
+	}
+	if h.median != nil {
+		h.median.remove(it)
+	}
+	it.value = value
+	if h.costFn != nil {
+		h.totalCost -= it.cost
+		it.cost = h.costOf(it.entry())
+		h.totalCost += it.cost
+	}
+	if h.values != nil {
+		h.values.add(it)
+	}
+	if h.median != nil {
+		h.median.add(it)
+	}
+	if h.bands != nil {
+		h.bands.fix(it)
+	}
+}
+
+// addMaxHook registers f to be called whenever the maximum of the queue changes.
+func (h *CapQueue[K, V]) addMaxHook(f func()) *maxHook {
+	hook := &maxHook{f: f}
+	h.maxHooks = append(h.maxHooks[:len(h.maxHooks):len(h.maxHooks)], hook)
+	return hook
+}
+
+// removeMaxHook unregisters the given hook.
+func (h *CapQueue[K, V]) removeMaxHook(hook *maxHook) {
+	// copy the hooks, as they might currently be iterated
+	hooks := make([]*maxHook, 0, len(h.maxHooks))
+	for _, other := range h.maxHooks {
+		if other != hook {
+			hooks = append(hooks, other)
+		}
+	}
+	h.maxHooks = hooks
+}
+
+// trackMax records the current maximum and returns a function that calls the max change hooks,
+// if the maximum has changed in the meantime. It should be used as "defer h.trackMax()()".
+func (h *CapQueue[K, V]) trackMax() func() {
+	if len(h.maxHooks) == 0 {
+		return noop
+	}
+	key, value, ok := h.peekMax()
+	return func() {
+		if k, v, o := h.peekMax(); k != key || v != value || o != ok {
+			for _, hook := range h.maxHooks {
+				hook.f()
+			}
+		}
+	}
+}
+
+func noop() {}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// peekMax returns the key-value pair with the highest value, if the queue is not empty.
+func (h *CapQueue[K, V]) peekMax() (K, V, bool) {
+	if h.size() == 0 {
+		var key K
+		var value V
+		return key, value, false
+	}
+	it := h.top()
+	return it.key, it.value, true
+}
+
+// victim returns the element that gets evicted when a new element is added to the full queue.
+func (h *CapQueue[K, V]) victim() *item[K, V] {
+	switch {
+	case h.freqs != nil:
+		return h.freqs.front()
+	case h.opts.eviction == EvictLowest:
+		return h.bottom()
+	}
+	return h.first()
 }
 
 // first returns the oldest element in the queue.
-func (h *CapQueue) first() *item {
-	return h.order.Front().Value.(*item)
+func (h *CapQueue[K, V]) first() *item[K, V] {
+	return h.order.front()
+}
+
+// entry returns the exported representation of the item.
+func (it *item[K, V]) entry() Entry[K, V] {
+	return Entry[K, V]{Key: it.key, Value: it.value, Tiebreak: it.tiebreak, AddedAt: it.addedAt, ExpiresAt: it.expiresAt,
+		Payload: it.payload}
+}
+
+// set sets the content of the item to the given entry.
+func (it *item[K, V]) set(e Entry[K, V]) {
+	it.key = e.Key
+	it.value = e.Value
+	it.tiebreak = e.Tiebreak
+	it.addedAt = e.AddedAt
+	it.expiresAt = e.ExpiresAt
+	it.payload = e.Payload
 }
 
-func (h binHeap) Len() int {
+func (h binHeap[K, V]) Len() int {
 	return len(h)
 }
 
-func (h binHeap) Less(i, j int) bool {
-	return h[i].value > h[j].value
+// higher returns whether a has a higher priority than b according to the ordering of the queue.
+func (h *CapQueue[K, V]) higher(a, b *item[K, V]) bool {
+	if h.less != nil {
+		if h.less(b.entry(), a.entry()) {
+			return true
+		}
+		return h.opts.stableOrder && !h.less(a.entry(), b.entry()) && a.seq < b.seq
+	}
+	if h.opts.stableOrder && a.value == b.value && a.tiebreak == b.tiebreak {
+		return a.seq < b.seq
+	}
+	return higher(a, b)
+}
+
+// higher returns whether a has a higher priority than b according to the default ordering by (value, tiebreak).
+func higher[K comparable, V ordering.Ordered](a, b *item[K, V]) bool {
+	if a.value != b.value {
+		return a.value > b.value
+	}
+	return a.tiebreak > b.tiebreak
+}
+
+// entryLess returns the ordering of the queue as a less function of entries.
+func (h *CapQueue[K, V]) entryLess() func(a, b Entry[K, V]) bool {
+	if h.less != nil {
+		return h.less
+	}
+	return lessEntry[K, V]
 }
 
-func (h binHeap) Swap(i, j int) {
+// lessEntry is the default ordering of the entries by (value, tiebreak).
+func lessEntry[K comparable, V ordering.Ordered](a, b Entry[K, V]) bool {
+	if a.Value != b.Value {
+		return a.Value < b.Value
+	}
+	return a.Tiebreak < b.Tiebreak
+}
+
+func (h binHeap[K, V]) Swap(i, j int) {
 	h[i], h[j] = h[j], h[i]
 	h[i].index = i
 	h[j].index = j
 }
 
-func (h *binHeap) Push(x interface{}) {
+func (h *binHeap[K, V]) Push(x interface{}) {
 	n := len(*h)
-	if n == cap(*h) {
-		panic("insufficient capacity")
-	}
-	item := x.(*item)
+	item := x.(*item[K, V])
 	item.index = n
 	*h = append(*h, item)
 }
 
-func (h *binHeap) Pop() interface{} {
+func (h *binHeap[K, V]) Pop() interface{} {
 	old := *h
 	n := len(old)
 	item := old[n-1]
@@ -162,3 +1268,56 @@ func (h *binHeap) Pop() interface{} {
 	*h = old[0 : n-1]
 	return item
 }
+
+// entryHeap is a max-heap of entries, which does not share any state with the queue.
+type entryHeap[K comparable, V ordering.Ordered] struct {
+	entries []Entry[K, V]
+	less    func(a, b Entry[K, V]) bool
+	order   []int // positions of the entries in the insertion order, only used with WithStableOrder
+}
+
+// newEntryHeap returns a heap of all entries contained in the queue.
+func (h *CapQueue[K, V]) newEntryHeap() *entryHeap[K, V] {
+	eh := &entryHeap[K, V]{entries: h.entries(), less: h.entryLess()}
+	if h.opts.stableOrder {
+		eh.order = make([]int, len(eh.entries))
+		for i := range eh.order {
+			eh.order[i] = i
+		}
+	}
+	heap.Init(eh)
+	return eh
+}
+
+func (h *entryHeap[K, V]) Len() int {
+	return len(h.entries)
+}
+
+func (h *entryHeap[K, V]) Less(i, j int) bool {
+	if h.less(h.entries[j], h.entries[i]) {
+		return true
+	}
+	return h.order != nil && !h.less(h.entries[i], h.entries[j]) && h.order[i] < h.order[j]
+}
+
+func (h *entryHeap[K, V]) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	if h.order != nil {
+		h.order[i], h.order[j] = h.order[j], h.order[i]
+	}
+}
+
+func (h *entryHeap[K, V]) Push(x interface{}) {
+	h.entries = append(h.entries, x.(Entry[K, V]))
+}
+
+func (h *entryHeap[K, V]) Pop() interface{} {
+	old := h.entries
+	n := len(old)
+	e := old[n-1]
+	h.entries = old[0 : n-1]
+	if h.order != nil {
+		h.order = h.order[0 : n-1]
+	}
+	return e
+}
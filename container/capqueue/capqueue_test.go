@@ -1,9 +1,12 @@
 package capqueue_test
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	. "github.com/wollac/pkg/container/capqueue"
@@ -12,13 +15,49 @@ import (
 const testCapacity = 10
 
 func TestNew(t *testing.T) {
-	q := New(testCapacity)
+	q := New[string, int](testCapacity)
 	assert.Equal(t, 0, q.Len())
 	assert.Equal(t, testCapacity, q.Cap())
 }
 
+func TestNew_ValueTypes(t *testing.T) {
+	f := New[string, float64](testCapacity)
+	f.Add("a", 0.5)
+	f.Add("b", 1.5)
+	f.Add("c", -1)
+	maxKey, maxFloat := f.Max()
+	assert.Equal(t, "b", maxKey)
+	assert.Equal(t, 1.5, maxFloat)
+
+	s := New[string, string](testCapacity)
+	s.Add("a", "apple")
+	s.Add("b", "pear")
+	s.Add("c", "banana")
+	maxKey, maxString := s.Max()
+	assert.Equal(t, "b", maxKey)
+	assert.Equal(t, "pear", maxString)
+	assert.Equal(t, "", s.Value("d"))
+}
+
+func TestNew_KeyTypes(t *testing.T) {
+	q := New[[32]byte, int](testCapacity)
+	for i := 0; i < testCapacity+1; i++ {
+		q.Add([32]byte{byte(i)}, i)
+	}
+	assert.Equal(t, testCapacity, q.Len())
+	assert.Equal(t, 0, q.Value([32]byte{0}))
+	maxKey, maxValue := q.Max()
+	assert.Equal(t, [32]byte{testCapacity}, maxKey)
+	assert.Equal(t, testCapacity, maxValue)
+
+	type point struct{ x, y int }
+	p := New[point, int](testCapacity, WithAdmission[point, int](NewTinyLFU[point, int](testCapacity)))
+	p.Add(point{1, 2}, 1)
+	assert.True(t, p.Delete(point{1, 2}))
+}
+
 func TestCapQueue_Max(t *testing.T) {
-	q := New(testCapacity)
+	q := New[string, int](testCapacity)
 	assert.Panics(t, func() { _, _ = q.Max() })
 
 	q.Add("1", 1)
@@ -27,8 +66,92 @@ func TestCapQueue_Max(t *testing.T) {
 	assert.Equal(t, 1, maxValue)
 }
 
+func TestCapQueue_TryMax(t *testing.T) {
+	q := New[string, int](testCapacity)
+	_, _, err := q.TryMax()
+	assert.True(t, errors.Is(err, ErrEmpty))
+
+	q.Add("1", 1)
+	q.Add("2", 2)
+	maxKey, maxValue, err := q.TryMax()
+	assert.NoError(t, err)
+	assert.Equal(t, "2", maxKey)
+	assert.Equal(t, 2, maxValue)
+}
+
+func TestCapQueue_PopMax(t *testing.T) {
+	q := New[string, int](testCapacity)
+	assert.PanicsWithValue(t, ErrEmpty, func() { _, _ = q.PopMax() })
+	_, _, err := q.TryPopMax()
+	assert.True(t, errors.Is(err, ErrEmpty))
+
+	for _, i := range rand.Perm(testCapacity) {
+		q.Add(fmt.Sprint(i), i)
+	}
+	for i := testCapacity - 1; i >= 0; i-- {
+		key, value := q.PopMax()
+		assert.Equal(t, fmt.Sprint(i), key)
+		assert.Equal(t, i, value)
+		assert.Equal(t, i, q.Len())
+	}
+}
+
+func TestCapQueue_TryFirst(t *testing.T) {
+	q := New[string, int](testCapacity)
+	assert.PanicsWithValue(t, ErrEmpty, func() { _, _ = q.First() })
+	_, _, err := q.TryFirst()
+	assert.True(t, errors.Is(err, ErrEmpty))
+
+	q.Add("1", 1)
+	q.Add("2", 2)
+	firstKey, firstValue, err := q.TryFirst()
+	assert.NoError(t, err)
+	assert.Equal(t, "1", firstKey)
+	assert.Equal(t, 1, firstValue)
+}
+
+func TestCapQueue_Last(t *testing.T) {
+	q := New[string, int](testCapacity)
+	assert.PanicsWithValue(t, ErrEmpty, func() { _, _ = q.Last() })
+	_, _, err := q.TryLast()
+	assert.True(t, errors.Is(err, ErrEmpty))
+
+	for i := 0; i < testCapacity+1; i++ {
+		q.Add(fmt.Sprint(i), i)
+		lastKey, lastValue := q.Last()
+		assert.Equal(t, fmt.Sprint(i), lastKey)
+		assert.Equal(t, i, lastValue)
+	}
+	q.Delete(fmt.Sprint(testCapacity))
+	lastKey, lastValue, err := q.TryLast()
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprint(testCapacity-1), lastKey)
+	assert.Equal(t, testCapacity-1, lastValue)
+}
+
+func TestCapQueue_PopFirst(t *testing.T) {
+	q := New[string, int](testCapacity)
+	assert.PanicsWithValue(t, ErrEmpty, func() { _, _ = q.PopFirst() })
+	_, _, err := q.TryPopFirst()
+	assert.True(t, errors.Is(err, ErrEmpty))
+
+	for i := 0; i < testCapacity; i++ {
+		q.Add(fmt.Sprint(i), testCapacity-i)
+	}
+	for i := 0; i < testCapacity; i++ {
+		key, value := q.PopFirst()
+		assert.Equal(t, fmt.Sprint(i), key)
+		assert.Equal(t, testCapacity-i, value)
+		if i < testCapacity-1 {
+			_, maxValue := q.Max()
+			assert.Equal(t, testCapacity-i-1, maxValue)
+		}
+	}
+	assert.Zero(t, q.Len())
+}
+
 func TestCapQueue_Add(t *testing.T) {
-	q := New(testCapacity)
+	q := New[string, int](testCapacity)
 	for i := 1; i <= testCapacity+1; i++ {
 		q.Add(fmt.Sprint(i), i)
 	}
@@ -38,8 +161,147 @@ func TestCapQueue_Add(t *testing.T) {
 	assert.Equal(t, max, testCapacity+1)
 }
 
+func TestCapQueue_AddEvict(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity; i++ {
+		_, _, ok := q.AddEvict(fmt.Sprint(i), i)
+		assert.False(t, ok)
+	}
+	key, value, ok := q.AddEvict("new", 0)
+	assert.True(t, ok)
+	assert.Equal(t, "1", key)
+	assert.Equal(t, 1, value)
+
+	// replacing an existing key does not evict
+	_, _, ok = q.AddEvict("2", 0)
+	assert.False(t, ok)
+
+	// the oldest entry of a batch is returned
+	q = New[string, int](testCapacity, WithEvictionBatch[string, int](testCapacity/2))
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	key, _, ok = q.AddEvict("new", 0)
+	assert.True(t, ok)
+	assert.Equal(t, "1", key)
+	assert.Equal(t, testCapacity/2+1, q.Len())
+
+	p := NewPreallocated[string, int](testCapacity, 2)
+	assert.Panics(t, func() { p.AddEvict("too long", 0) })
+}
+
+func TestCapQueue_AddIfBetter(t *testing.T) {
+	q := New[string, int](3)
+	assert.True(t, q.AddIfBetter("b", 2))
+	assert.True(t, q.AddIfBetter("a", 1))
+	assert.True(t, q.AddIfBetter("c", 3))
+
+	// the queue is full and the entry is not better than the minimum
+	assert.False(t, q.AddIfBetter("x", 1))
+	assert.False(t, q.Contains("x"))
+	assert.EqualValues(t, 1, q.Stats().Rejections)
+	// existing keys are always updated
+	assert.True(t, q.AddIfBetter("c", 0))
+	assert.Equal(t, 0, q.Value("c"))
+
+	// the oldest entry is evicted, even though it is not the minimum
+	assert.True(t, q.AddIfBetter("d", 4))
+	assert.Equal(t, []string{"a", "c", "d"}, q.Keys())
+
+	q.BeginBatch()
+	assert.True(t, q.AddIfBetter("e", -1))
+	q.EndBatch()
+	assert.False(t, q.Contains("e"))
+}
+
+func TestCapQueue_AddExisting(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 0; i < testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	q.Add("0", 2*testCapacity)
+	assert.Equal(t, testCapacity, q.Len())
+	assert.Equal(t, 2*testCapacity, q.Value("0"))
+	lastKey, _ := q.Last()
+	assert.Equal(t, "0", lastKey)
+
+	// the replaced entry is not evicted as the oldest one
+	q.Add("new", 0)
+	assert.Equal(t, 2*testCapacity, q.Value("0"))
+	assert.Equal(t, 0, q.Value("1"))
+
+	// the entry is only contained once
+	assert.True(t, q.Delete("0"))
+	assert.False(t, q.Delete("0"))
+	_, maxValue := q.Max()
+	assert.Equal(t, testCapacity-1, maxValue)
+}
+
+func TestCapQueue_AddOrUpdate(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 0; i < testCapacity; i++ {
+		assert.False(t, q.AddOrUpdate(fmt.Sprint(i), i))
+	}
+	q.AddWithTiebreak("0", 0, 5)
+	q.AddWithPayload("1", 1, "payload")
+
+	assert.True(t, q.AddOrUpdate("1", 2*testCapacity))
+	assert.Equal(t, testCapacity, q.Len())
+	maxKey, maxValue := q.Max()
+	assert.Equal(t, "1", maxKey)
+	assert.Equal(t, 2*testCapacity, maxValue)
+	lastKey, _ := q.Last()
+	assert.Equal(t, "1", lastKey)
+	p, _ := q.Payload("1")
+	assert.Equal(t, "payload", p)
+
+	assert.True(t, q.AddOrUpdate("0", 1))
+	e := q.NewestK(1)[0]
+	assert.Equal(t, Entry[string, int]{Key: "0", Value: 1, Tiebreak: 5, AddedAt: e.AddedAt}, e)
+
+	// the updated entries are evicted last
+	q.Add("new", 0)
+	assert.False(t, q.Contains("2"))
+	assert.True(t, q.Contains("0"))
+	assert.True(t, q.Contains("1"))
+}
+
+func TestCapQueue_Update(t *testing.T) {
+	q := New[string, int](testCapacity)
+	assert.False(t, q.Update("0", 0))
+
+	for i := 0; i < testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	assert.True(t, q.Update("0", testCapacity))
+	maxKey, maxValue := q.Max()
+	assert.Equal(t, "0", maxKey)
+	assert.Equal(t, testCapacity, maxValue)
+	firstKey, _ := q.First()
+	assert.Equal(t, "0", firstKey)
+
+	assert.True(t, q.Update("0", -1))
+	maxKey, _ = q.Max()
+	assert.Equal(t, fmt.Sprint(testCapacity-1), maxKey)
+	assert.Equal(t, testCapacity, q.Len())
+}
+
+func TestCapQueue_AddWithTiebreak(t *testing.T) {
+	q := New[string, int](testCapacity)
+	q.AddWithTiebreak("1", 1, 2)
+	q.AddWithTiebreak("2", 1, 3)
+	q.AddWithTiebreak("3", 0, 4)
+	q.Add("4", 1)
+
+	for _, key := range []string{"2", "1", "4", "3"} {
+		maxKey, _ := q.Max()
+		assert.Equal(t, key, maxKey)
+		q.Delete(maxKey)
+	}
+}
+
 func TestCapQueue_Delete(t *testing.T) {
-	q := New(testCapacity)
+	q := New[string, int](testCapacity)
 	for i := 1; i <= testCapacity; i++ {
 		q.Add(fmt.Sprint(i), i)
 	}
@@ -53,8 +315,79 @@ func TestCapQueue_Delete(t *testing.T) {
 	}
 }
 
+func TestCapQueue_Touch(t *testing.T) {
+	q := New[string, int](testCapacity, WithStableOrder[string, int]())
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i%2)
+	}
+	assert.False(t, q.Touch("missing"))
+
+	start := time.Now()
+	assert.True(t, q.Touch("1"))
+	assert.Equal(t, "1", q.Keys()[testCapacity-1])
+	e := q.NewestK(1)[0]
+	assert.Equal(t, 1, e.Value)
+	assert.False(t, e.AddedAt.Before(start))
+	// the touched entry is now the newest of its ties
+	key, _ := q.Max()
+	assert.Equal(t, "3", key)
+
+	// touched entries survive longer
+	q.Add("new", 0)
+	assert.True(t, q.Contains("1"))
+	assert.False(t, q.Contains("2"))
+
+	q.BeginBatch()
+	assert.True(t, q.Touch("3"))
+	assert.Equal(t, "3", q.Keys()[0])
+	q.EndBatch()
+	assert.Equal(t, "3", q.Keys()[testCapacity-1])
+}
+
+func TestCapQueue_Remove(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+
+	_, ok := q.Remove("not contained")
+	assert.False(t, ok)
+
+	for i := 1; i <= testCapacity; i++ {
+		value, ok := q.Remove(fmt.Sprint(i))
+		assert.True(t, ok)
+		assert.Equal(t, i, value)
+		assert.Equal(t, testCapacity-i, q.Len())
+	}
+}
+
+func TestCapQueue_Get(t *testing.T) {
+	q := New[string, int](testCapacity)
+	q.Add("zero", 0)
+	q.Add("one", 1)
+
+	value, ok := q.Get("zero")
+	assert.True(t, ok)
+	assert.Equal(t, 0, value)
+	value, ok = q.Get("one")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	_, ok = q.Get("not contained")
+	assert.False(t, ok)
+}
+
+func TestCapQueue_Contains(t *testing.T) {
+	q := New[string, int](testCapacity, WithKeyNormalizer[string, int](strings.ToLower))
+	assert.False(t, q.Contains("a"))
+	q.Add("a", 0)
+	assert.True(t, q.Contains("a"))
+	assert.True(t, q.Contains("A"))
+	q.Delete("a")
+	assert.False(t, q.Contains("a"))
+}
+
 func TestCapQueue_Value(t *testing.T) {
-	q := New(testCapacity)
+	q := New[string, int](testCapacity)
 	for i := 1; i <= testCapacity; i++ {
 		q.Add(fmt.Sprint(i), i)
 	}
@@ -66,8 +399,256 @@ func TestCapQueue_Value(t *testing.T) {
 	}
 }
 
+func TestCapQueue_PeekEvictee(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 0; i < testCapacity; i++ {
+		_, _, ok := q.PeekEvictee()
+		assert.False(t, ok)
+		q.Add(fmt.Sprint(i), i)
+	}
+	key, value, ok := q.PeekEvictee()
+	assert.True(t, ok)
+	assert.Equal(t, "0", key)
+	assert.Equal(t, 0, value)
+
+	q.Add("new", testCapacity)
+	assert.Equal(t, 0, q.Value("0"))
+	key, _, _ = q.PeekEvictee()
+	assert.Equal(t, "1", key)
+}
+
+func TestCapQueue_Entries(t *testing.T) {
+	q := New[string, int](testCapacity)
+	assert.Empty(t, q.Entries())
+
+	start := time.Now()
+	for i := 1; i <= testCapacity+1; i++ {
+		q.AddWithTiebreak(fmt.Sprint(i), (i*7)%testCapacity, i)
+	}
+	q.Delete("4")
+
+	entries := q.Entries()
+	assert.Len(t, entries, testCapacity-1)
+	for i, e := range entries {
+		assert.Equal(t, q.Value(e.Key), e.Value)
+		assert.False(t, e.AddedAt.Before(start))
+		if i > 0 {
+			prev := entries[i-1]
+			assert.True(t, prev.Value > e.Value || prev.Value == e.Value && prev.Tiebreak > e.Tiebreak)
+		}
+	}
+	key, value := q.Max()
+	assert.Equal(t, key, entries[0].Key)
+	assert.Equal(t, value, entries[0].Value)
+
+	// the queue is not modified
+	assert.Equal(t, testCapacity-1, q.Len())
+	assert.Equal(t, "2", q.OldestK(1)[0].Key)
+}
+
+func TestCapQueue_Keys(t *testing.T) {
+	q := New[string, int](testCapacity)
+	assert.Empty(t, q.Keys())
+
+	for i := 1; i <= testCapacity+1; i++ {
+		q.Add(fmt.Sprint(i), testCapacity-i)
+	}
+	q.Delete("5")
+	q.Add("3", 0)
+
+	keys := q.Keys()
+	assert.Len(t, keys, testCapacity-1)
+	assert.Equal(t, []string{"2", "4", "6"}, keys[:3])
+	assert.Equal(t, "3", keys[len(keys)-1])
+}
+
+func TestCapQueue_ForEach(t *testing.T) {
+	q := New[string, int](testCapacity)
+	q.ForEach(func(string, int) bool {
+		t.Fatal("unexpected call")
+		return true
+	})
+
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	var keys []string
+	q.ForEach(func(key string, value int) bool {
+		assert.Equal(t, q.Value(key), value)
+		keys = append(keys, key)
+		return len(keys) < 3
+	})
+	assert.Equal(t, []string{"1", "2", "3"}, keys)
+
+	// mutations are applied after the iteration
+	keys = nil
+	q.ForEach(func(key string, value int) bool {
+		keys = append(keys, key)
+		q.Delete(key)
+		q.Add(key+"'", -value)
+		return true
+	})
+	assert.Equal(t, []string{"1'", "2'", "3'", "4'", "5'", "6'", "7'", "8'", "9'", "10'"}, q.Keys())
+	assert.Len(t, keys, testCapacity)
+}
+
+func TestCapQueue_OldestK(t *testing.T) {
+	q := New[string, int](testCapacity)
+	assert.Empty(t, q.OldestK(1))
+
+	for i := 1; i <= testCapacity+1; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	assert.Empty(t, q.OldestK(0))
+	assert.Equal(t, q.NewestK(2*testCapacity), q.Entries())
+
+	entries := q.OldestK(3)
+	assert.Len(t, entries, 3)
+	for i, e := range entries {
+		assert.Equal(t, fmt.Sprint(i+2), e.Key)
+	}
+}
+
+func TestCapQueue_NewestK(t *testing.T) {
+	q := New[string, int](testCapacity)
+	assert.Empty(t, q.NewestK(1))
+
+	for i := 1; i <= testCapacity+1; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	assert.Empty(t, q.NewestK(0))
+	assert.Len(t, q.NewestK(2*testCapacity), testCapacity)
+
+	entries := q.NewestK(3)
+	assert.Len(t, entries, 3)
+	for i, e := range entries {
+		assert.Equal(t, fmt.Sprint(testCapacity+1-i), e.Key)
+	}
+}
+
+func TestCapQueue_ShrinkToFit(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	for i := 1; i <= testCapacity/2; i++ {
+		q.Delete(fmt.Sprint(i))
+	}
+
+	q.ShrinkToFit()
+	assert.Equal(t, testCapacity/2, q.Len())
+	for i := testCapacity/2 + 1; i <= testCapacity; i++ {
+		assert.Equal(t, i, q.Value(fmt.Sprint(i)))
+	}
+
+	// the queue must still be usable up to its capacity
+	for i := testCapacity + 1; i <= 2*testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	assert.Equal(t, testCapacity, q.Len())
+	_, max := q.Max()
+	assert.Equal(t, 2*testCapacity, max)
+}
+
+func TestNewUnbounded(t *testing.T) {
+	var softLimit bool
+	q := New[string, int](0, WithCapacityHeadroom[string, int](1, func(int) { softLimit = true }))
+	assert.Zero(t, q.Cap())
+	for i := 1; i <= 100*testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	assert.Equal(t, 100*testCapacity, q.Len())
+	assert.Zero(t, q.Stats().Evictions)
+	assert.False(t, softLimit)
+	_, _, ok := q.PeekEvictee()
+	assert.False(t, ok)
+	_, max := q.Max()
+	assert.Equal(t, 100*testCapacity, max)
+	key, _ := q.First()
+	assert.Equal(t, "1", key)
+
+	assert.Panics(t, func() { New[string, int](-1) })
+}
+
+func TestCapQueue_SetCap(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+
+	q.SetCap(2 * testCapacity)
+	assert.Equal(t, 2*testCapacity, q.Cap())
+	for i := testCapacity + 1; i <= 2*testCapacity; i++ {
+		q.Add(fmt.Sprint(i), -i)
+	}
+	assert.Equal(t, 2*testCapacity, q.Len())
+
+	// shrinking evicts the oldest entries
+	q.SetCap(testCapacity / 2)
+	assert.Equal(t, testCapacity/2, q.Len())
+	assert.Equal(t, uint64(2*testCapacity-testCapacity/2), q.Stats().Evictions)
+	assert.Equal(t, fmt.Sprint(2*testCapacity-testCapacity/2+1), q.Keys()[0])
+	_, max := q.Max()
+	assert.Equal(t, -2*testCapacity+testCapacity/2-1, max)
+
+	q.Add("new", 0)
+	assert.Equal(t, testCapacity/2, q.Len())
+	assert.Panics(t, func() { q.SetCap(-1) })
+
+	// removing the capacity makes the queue unbounded
+	q.SetCap(0)
+	for i := 0; i < 2*testCapacity; i++ {
+		q.Add(fmt.Sprint("unbounded", i), i)
+	}
+	assert.Equal(t, testCapacity/2+2*testCapacity, q.Len())
+}
+
+func TestCapQueue_FullAddAllocs(t *testing.T) {
+	q := New[string, int](testCapacity)
+	keys := make([]string, 2*testCapacity)
+	for i := range keys {
+		keys[i] = fmt.Sprint(i)
+		q.Add(keys[i], i)
+	}
+
+	// the insertion order is tracked by the items themselves and evicted items are reused
+	allocs := testing.AllocsPerRun(100, func() {
+		for i, key := range keys {
+			q.Add(key, i)
+		}
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestCapQueue_AddDeleteAllocs(t *testing.T) {
+	for name, q := range map[string]*CapQueue[string, int]{
+		"bounded":   New[string, int](testCapacity),
+		"unbounded": New[string, int](0),
+	} {
+		t.Run(name, func(t *testing.T) {
+			keys := make([]string, testCapacity)
+			for i := range keys {
+				keys[i] = fmt.Sprint(i)
+				q.Add(keys[i], i)
+			}
+			q.Clear()
+
+			// the items of deleted entries are reused
+			allocs := testing.AllocsPerRun(100, func() {
+				for i, key := range keys {
+					q.Add(key, i)
+				}
+				for _, key := range keys {
+					q.Delete(key)
+				}
+			})
+			assert.Zero(t, allocs)
+		})
+	}
+}
+
 func BenchmarkCapQueue_Add(b *testing.B) {
-	q := New(b.N)
+	q := New[string, int](b.N)
 	// prepare random adds
 	data := make([]int, b.N)
 	for i := range data {
@@ -82,7 +663,7 @@ func BenchmarkCapQueue_Add(b *testing.B) {
 
 func BenchmarkCapQueue_FullAdd(b *testing.B) {
 	// create a queue full of random values
-	q := New(b.N)
+	q := New[string, int](b.N)
 	for i := 0; i < b.N; i++ {
 		v := rand.Intn(b.N)
 		q.Add(fmt.Sprint(v), v)
@@ -101,7 +682,7 @@ func BenchmarkCapQueue_FullAdd(b *testing.B) {
 
 func BenchmarkCapQueue_Delete(b *testing.B) {
 	// create a full queue
-	q := New(b.N)
+	q := New[string, int](b.N)
 	for i := 0; i < b.N; i++ {
 		q.Add(fmt.Sprint(i), i)
 	}
@@ -117,3 +698,102 @@ func BenchmarkCapQueue_Delete(b *testing.B) {
 		q.Delete(data[i])
 	}
 }
+
+func TestCapQueue_Min(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"default":    nil,
+		"valueIndex": {WithValueIndex[string, int]()},
+		"lazy":       {WithLazyDeletion[string, int]()},
+		"fixup":      {WithFixupBudget[string, int](1)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			q := New[string, int](testCapacity, opts...)
+			assert.PanicsWithValue(t, ErrEmpty, func() { _, _ = q.Min() })
+
+			for _, i := range rand.Perm(testCapacity) {
+				q.AddWithTiebreak(fmt.Sprint(i), i/2, i%2)
+			}
+			minKey, minValue := q.Min()
+			assert.Equal(t, "0", minKey)
+			assert.Equal(t, 0, minValue)
+
+			q.Delete("0")
+			minKey, minValue, err := q.TryMin()
+			assert.NoError(t, err)
+			assert.Equal(t, "1", minKey)
+			assert.Equal(t, 0, minValue)
+		})
+	}
+}
+
+func TestCapQueue_DrainSorted(t *testing.T) {
+	q := New[string, int](testCapacity, WithStableOrder[string, int]())
+	for i := 0; i < testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i%4)
+	}
+	c := q.Clone()
+
+	entries := q.DrainSorted()
+	assert.Zero(t, q.Len())
+	assert.Len(t, entries, testCapacity)
+	for _, e := range entries {
+		key, value := c.PopMax()
+		assert.Equal(t, key, e.Key)
+		assert.Equal(t, value, e.Value)
+	}
+	assert.Empty(t, q.DrainSorted())
+}
+
+func TestCapQueue_Clear(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"default":    nil,
+		"bands":      {WithPriorityBands[string, int](testCapacity / 2)},
+		"valueIndex": {WithValueIndex[string, int]()},
+		"lazy":       {WithLazyDeletion[string, int]()},
+		"fixup":      {WithFixupBudget[string, int](1)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var changes int
+			q := New[string, int](testCapacity, append(opts, WithMaxChangeCallback[string, int](func() { changes++ }))...)
+			for i := 0; i < testCapacity; i++ {
+				q.Add(fmt.Sprint(i), i)
+			}
+			q.Delete("0")
+			changes = 0
+
+			q.Clear()
+			assert.Zero(t, q.Len())
+			assert.False(t, q.Contains("1"))
+			assert.Empty(t, q.Entries())
+			assert.Empty(t, q.ValuesBetween(0, testCapacity))
+			assert.Equal(t, 1, changes)
+			_, _, err := q.TryMax()
+			assert.True(t, errors.Is(err, ErrEmpty))
+
+			// the queue is fully functional afterwards
+			for i := 0; i < testCapacity+1; i++ {
+				q.Add(fmt.Sprint(i), i)
+			}
+			assert.Equal(t, testCapacity, q.Len())
+			_, maxValue := q.Max()
+			assert.Equal(t, testCapacity, maxValue)
+			_, minValue := q.Min()
+			assert.Equal(t, 1, minValue)
+			_, fairValue := q.PopFair()
+			assert.Equal(t, testCapacity, fairValue)
+		})
+	}
+
+	p := NewPreallocated[string, int](testCapacity, 2)
+	for i := 0; i < testCapacity; i++ {
+		p.Add(fmt.Sprint(i), i)
+	}
+	p.Clear()
+	allocs := testing.AllocsPerRun(10, func() {
+		for i := 0; i < testCapacity; i++ {
+			p.Add("k", i)
+		}
+		p.Clear()
+	})
+	assert.Zero(t, allocs)
+}
@@ -0,0 +1,137 @@
+/*
+Package capqueuehttp exposes a capqueue.Sync over a minimal HTTP/JSON API for operational inspection and remote
+feeding of the queue.
+
+The handler serves the following endpoints relative to its mount point:
+
+	GET    /top?k=N      the N entries with the highest priority, ordered by descending priority (default N=10)
+	GET    /stats        the statistics of the queue
+	POST   /entries      adds or updates the entries in the JSON array of the request body (at most MaxBodyBytes)
+	DELETE /entries/KEY  removes the entry with the given key
+*/
+package capqueuehttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wollac/pkg/container/capqueue"
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// DefaultTopK is the number of entries returned by the top endpoint, when k is not specified.
+const DefaultTopK = 10
+
+// MaxBodyBytes is the maximum size of a request body accepted by the entries endpoint.
+const MaxBodyBytes = 1 << 20
+
+// Entry is the JSON representation of a queue entry.
+type Entry[V ordering.Ordered] struct {
+	Key       string    `json:"key"`
+	Value     V         `json:"value"`
+	Tiebreak  int       `json:"tiebreak,omitempty"`
+	AddedAt   time.Time `json:"addedAt,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
+
+// Stats is the JSON representation of the queue statistics.
+type Stats struct {
+	Len        int    `json:"len"`
+	Cap        int    `json:"cap"`
+	Adds       uint64 `json:"adds"`
+	Evictions  uint64 `json:"evictions"`
+	Rejections uint64 `json:"rejections"`
+}
+
+type handler[V ordering.Ordered] struct {
+	q   *capqueue.Sync[string, V]
+	mux *http.ServeMux
+}
+
+// NewHandler returns an http.Handler serving the API for the given queue.
+func NewHandler[V ordering.Ordered](q *capqueue.Sync[string, V]) http.Handler {
+	h := &handler[V]{q: q, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/top", h.top)
+	h.mux.HandleFunc("/stats", h.stats)
+	h.mux.HandleFunc("/entries", h.add)
+	h.mux.HandleFunc("/entries/", h.delete)
+	return h
+}
+
+func (h *handler[V]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *handler[V]) top(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	k := DefaultTopK
+	if s := r.URL.Query().Get("k"); s != "" {
+		var err error
+		if k, err = strconv.Atoi(s); err != nil || k < 0 {
+			http.Error(w, "invalid k", http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries := h.q.TopK(k)
+	res := make([]Entry[V], len(entries))
+	for i, e := range entries {
+		res[i] = Entry[V]{Key: e.Key, Value: e.Value, Tiebreak: e.Tiebreak, AddedAt: e.AddedAt, ExpiresAt: e.ExpiresAt}
+	}
+	writeJSON(w, res)
+}
+
+func (h *handler[V]) stats(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	s := h.q.Stats()
+	writeJSON(w, Stats{Len: s.Len, Cap: s.Cap, Adds: s.Adds, Evictions: s.Evictions, Rejections: s.Rejections})
+}
+
+func (h *handler[V]) add(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+	var entries []Entry[V]
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodyBytes)).Decode(&entries); err != nil {
+		http.Error(w, "invalid entries: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, e := range entries {
+		h.q.AddWithTiebreak(e.Key, e.Value, e.Tiebreak)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler[V]) delete(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodDelete) {
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/entries/")
+	if !h.q.Delete(key) {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allow replies with an error and returns false, if the request does not use the given method.
+func allow(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,70 @@
+package capqueuehttp_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wollac/pkg/container/capqueue"
+	. "github.com/wollac/pkg/container/capqueue/capqueuehttp"
+)
+
+const testCapacity = 10
+
+func do(t *testing.T, h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestHandler(t *testing.T) {
+	q := capqueue.NewSync[string, int](testCapacity)
+	h := NewHandler(q)
+
+	rec := do(t, h, http.MethodPost, "/entries", `[{"key":"a","value":1},{"key":"b","value":3},{"key":"c","value":2}]`)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, 3, q.Len())
+
+	rec = do(t, h, http.MethodGet, "/top?k=2", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var top []Entry[int]
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&top))
+	require.Len(t, top, 2)
+	assert.Equal(t, "b", top[0].Key)
+	assert.Equal(t, "c", top[1].Key)
+
+	// posting an existing key updates its entry
+	rec = do(t, h, http.MethodPost, "/entries", `[{"key":"a","value":4}]`)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, 3, q.Len())
+	assert.Equal(t, 4, q.Value("a"))
+	rec = do(t, h, http.MethodGet, "/top?k=1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&top))
+	require.Len(t, top, 1)
+	assert.Equal(t, "a", top[0].Key)
+
+	rec = do(t, h, http.MethodDelete, "/entries/b", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = do(t, h, http.MethodDelete, "/entries/b", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = do(t, h, http.MethodGet, "/stats", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var stats Stats
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&stats))
+	assert.Equal(t, Stats{Len: 2, Cap: testCapacity, Adds: 4}, stats)
+}
+
+func TestHandlerInvalid(t *testing.T) {
+	h := NewHandler(capqueue.NewSync[string, int](testCapacity))
+	assert.Equal(t, http.StatusMethodNotAllowed, do(t, h, http.MethodPost, "/top", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(t, h, http.MethodGet, "/top?k=-1", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(t, h, http.MethodPost, "/entries", "{").Code)
+	large := `[{"key":"` + strings.Repeat("a", MaxBodyBytes) + `","value":1}]`
+	assert.Equal(t, http.StatusBadRequest, do(t, h, http.MethodPost, "/entries", large).Code)
+}
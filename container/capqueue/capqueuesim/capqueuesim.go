@@ -0,0 +1,169 @@
+/*
+Package capqueuesim replays recorded traces of queue operations against several configurations of a capqueue.CapQueue
+to compare eviction and admission policies on real workloads.
+
+Every configuration is replayed on its own queue. Add events insert or replace an entry like cache.Cache.Set and Get
+events look up a key like cache.Cache.Get, which counts as an access for policies like capqueue.EvictLRU. Besides the
+hit ratio of the lookups, the simulation reports the sum of the values retained by the queue, which measures how well
+a policy keeps the valuable entries.
+
+A trace can be read from CSV records of the form "add,KEY,VALUE" or "get,KEY" using ReadTrace.
+*/
+package capqueuesim
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/wollac/pkg/container/capqueue"
+)
+
+// DefaultSampleInterval is the number of events between two samples of the retained value.
+const DefaultSampleInterval = 100
+
+// ErrInvalidTrace is returned when a trace cannot be parsed.
+var ErrInvalidTrace = errors.New("invalid trace")
+
+// Number is a constraint that permits any numeric type, whose values can be summed up.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Op is the type of an event.
+type Op int
+
+const (
+	// Add inserts an entry or replaces the value of an existing key.
+	Add Op = iota
+	// Get looks up a key.
+	Get
+)
+
+// Event is a single operation of a trace.
+type Event[K comparable, V Number] struct {
+	Op    Op
+	Key   K
+	Value V // value of the entry, only used by Add
+}
+
+// Config is a configuration of the queue to simulate.
+type Config[K comparable, V Number] struct {
+	Name    string
+	Cap     int
+	Options []capqueue.Option[K, V]
+}
+
+// Result contains the metrics of replaying a trace against one configuration.
+type Result struct {
+	Name       string
+	Hits       int     // number of Get events that found the key
+	Misses     int     // number of Get events that did not find the key
+	HitRatio   float64 // ratio of hits among all Get events or 0 if there were none
+	Evictions  uint64  // number of entries evicted by the queue
+	Rejections uint64  // number of entries rejected by the admission policy
+	MeanValue  float64 // mean of the sampled sums of all retained values
+	FinalValue float64 // sum of all values retained after the trace
+}
+
+// Run replays the trace against every configuration and returns the results in the order of the configurations.
+// The retained value is sampled every interval events and after the last event. If interval is not positive,
+// DefaultSampleInterval is used.
+func Run[K comparable, V Number](trace []Event[K, V], interval int, configs ...Config[K, V]) []Result {
+	if interval <= 0 {
+		interval = DefaultSampleInterval
+	}
+	results := make([]Result, len(configs))
+	for i, cfg := range configs {
+		results[i] = run(trace, interval, cfg)
+	}
+	return results
+}
+
+func run[K comparable, V Number](trace []Event[K, V], interval int, cfg Config[K, V]) Result {
+	q := capqueue.New[K, V](cfg.Cap, cfg.Options...)
+	c := capqueue.AsCache(q)
+
+	res := Result{Name: cfg.Name}
+	var sum float64
+	var samples int
+	for i, e := range trace {
+		switch e.Op {
+		case Add:
+			c.Set(e.Key, e.Value)
+		case Get:
+			if _, ok := c.Get(e.Key); ok {
+				res.Hits++
+			} else {
+				res.Misses++
+			}
+		default:
+			panic(fmt.Sprintf("invalid op %d", e.Op))
+		}
+		if (i+1)%interval == 0 || i == len(trace)-1 {
+			sum += retainedValue(q)
+			samples++
+		}
+	}
+
+	if n := res.Hits + res.Misses; n > 0 {
+		res.HitRatio = float64(res.Hits) / float64(n)
+	}
+	if samples > 0 {
+		res.MeanValue = sum / float64(samples)
+	}
+	res.FinalValue = retainedValue(q)
+	stats := q.Stats()
+	res.Evictions = stats.Evictions
+	res.Rejections = stats.Rejections
+	return res
+}
+
+// retainedValue returns the sum of all values contained in the queue.
+func retainedValue[K comparable, V Number](q *capqueue.CapQueue[K, V]) float64 {
+	var sum float64
+	for _, e := range q.Entries() {
+		sum += float64(e.Value)
+	}
+	return sum
+}
+
+// ReadTrace reads a trace from CSV records of the form "add,KEY,VALUE" or "get,KEY".
+func ReadTrace(r io.Reader) ([]Event[string, float64], error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	var trace []Event[string, float64]
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return trace, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTrace, err)
+		}
+		e, err := parseEvent(record)
+		if err != nil {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidTrace, line, err)
+		}
+		trace = append(trace, e)
+	}
+}
+
+func parseEvent(record []string) (Event[string, float64], error) {
+	switch {
+	case record[0] == "add" && len(record) == 3:
+		value, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return Event[string, float64]{}, err
+		}
+		return Event[string, float64]{Op: Add, Key: record[1], Value: value}, nil
+	case record[0] == "get" && len(record) == 2:
+		return Event[string, float64]{Op: Get, Key: record[1]}, nil
+	}
+	return Event[string, float64]{}, fmt.Errorf("unexpected record %q", record)
+}
@@ -0,0 +1,92 @@
+package capqueuesim_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wollac/pkg/container/capqueue"
+	. "github.com/wollac/pkg/container/capqueue/capqueuesim"
+)
+
+const testCapacity = 10
+
+func TestRun(t *testing.T) {
+	// a few frequently accessed keys interleaved with a scan of keys that are never accessed again
+	var trace []Event[string, int]
+	for i := 0; i < 100*testCapacity; i++ {
+		hot := fmt.Sprint("hot", i%(testCapacity/2))
+		trace = append(trace,
+			Event[string, int]{Op: Get, Key: hot},
+			Event[string, int]{Op: Add, Key: hot, Value: 10},
+		)
+		for j := 0; j < 3; j++ {
+			trace = append(trace, Event[string, int]{Op: Add, Key: fmt.Sprint("scan", i, j), Value: 1})
+		}
+	}
+
+	results := Run(trace, 0,
+		Config[string, int]{Name: "fifo", Cap: testCapacity},
+		Config[string, int]{Name: "tinylfu", Cap: testCapacity, Options: []capqueue.Option[string, int]{
+			capqueue.WithAdmission[string, int](capqueue.NewTinyLFU[string, int](testCapacity)),
+		}},
+	)
+	require.Len(t, results, 2)
+	fifo, tinyLFU := results[0], results[1]
+	assert.Equal(t, "fifo", fifo.Name)
+	assert.Equal(t, 100*testCapacity, fifo.Hits+fifo.Misses)
+	assert.InDelta(t, float64(fifo.Hits)/float64(fifo.Hits+fifo.Misses), fifo.HitRatio, 1e-9)
+	assert.Zero(t, fifo.Rejections)
+	assert.Greater(t, fifo.Evictions, uint64(0))
+
+	// rejecting the scan keeps the valuable hot keys in the queue
+	assert.Greater(t, tinyLFU.Rejections, uint64(0))
+	assert.Greater(t, tinyLFU.HitRatio, fifo.HitRatio)
+	assert.Greater(t, tinyLFU.MeanValue, fifo.MeanValue)
+	assert.GreaterOrEqual(t, tinyLFU.FinalValue, float64(testCapacity/2*10))
+}
+
+func TestRun_Recency(t *testing.T) {
+	// a is accessed again before c is added, so LRU keeps a and evicts b
+	trace := []Event[string, int]{
+		{Op: Add, Key: "a", Value: 1},
+		{Op: Add, Key: "b", Value: 1},
+		{Op: Get, Key: "a"},
+		{Op: Add, Key: "c", Value: 1},
+		{Op: Get, Key: "a"},
+	}
+	results := Run(trace, 0,
+		Config[string, int]{Name: "fifo", Cap: 2},
+		Config[string, int]{Name: "lru", Cap: 2, Options: []capqueue.Option[string, int]{
+			capqueue.WithEviction[string, int](capqueue.EvictLRU),
+		}},
+	)
+	require.Len(t, results, 2)
+	fifo, lru := results[0], results[1]
+	assert.InDelta(t, 0.5, fifo.HitRatio, 1e-9)
+	assert.InDelta(t, 1, lru.HitRatio, 1e-9)
+	assert.Equal(t, uint64(1), lru.Evictions)
+}
+
+func TestReadTrace(t *testing.T) {
+	trace, err := ReadTrace(strings.NewReader("add,a,1.5\nget,a\nget,b\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []Event[string, float64]{
+		{Op: Add, Key: "a", Value: 1.5},
+		{Op: Get, Key: "a"},
+		{Op: Get, Key: "b"},
+	}, trace)
+
+	results := Run(trace, 1, Config[string, float64]{Name: "fifo", Cap: testCapacity})
+	assert.Equal(t, 1, results[0].Hits)
+	assert.Equal(t, 1, results[0].Misses)
+	assert.Equal(t, 1.5, results[0].MeanValue)
+
+	for _, data := range []string{"add,a\n", "get,a,1\n", "add,a,x\n", "remove,a\n", "add,\"a\n"} {
+		_, err := ReadTrace(strings.NewReader(data))
+		assert.Truef(t, errors.Is(err, ErrInvalidTrace), "data: %q", data)
+	}
+}
@@ -0,0 +1,453 @@
+package capqueue
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// CBOR major types as defined in RFC 8949.
+const (
+	cborUint   byte = 0
+	cborNegInt byte = 1
+	cborBytes  byte = 2
+	cborText   byte = 3
+	cborArray  byte = 4
+	cborSimple byte = 7
+
+	cborNull    byte = cborSimple<<5 | 22
+	cborFloat64 byte = cborSimple<<5 | 27
+)
+
+// cborCodec encodes a Snapshot as a CBOR array [cap, [[key, value, tiebreak, addedAt(, expiresAt)], ...]].
+// Keys and values are encoded as integers, double-precision floats or strings depending on the kind of their type,
+// where string keys and byte array keys are encoded as byte strings and string values as text strings. Other key
+// types are not supported. The addedAt time is encoded as nanoseconds since the Unix epoch or null for the zero time.
+// The expiresAt time is encoded the same way, but only present for entries with an expiry time, so that entries
+// without one are encoded as in the original format, which is still decoded.
+type cborCodec[K comparable, V ordering.Ordered] struct{}
+
+func (cborCodec[K, V]) Encode(w io.Writer, s Snapshot[K, V]) error {
+	e := newCBOREncoder(w)
+	e.header(s.Cap, len(s.Entries))
+	for _, entry := range s.Entries {
+		encodeEntry(e, entry)
+	}
+	return e.flush()
+}
+
+func (cborCodec[K, V]) Decode(r io.Reader) (Snapshot[K, V], error) {
+	return decodeSnapshot[K, V](newCBORDecoder(bufio.NewReader(r)))
+}
+
+// decodeSnapshot reads a snapshot from d.
+func decodeSnapshot[K comparable, V ordering.Ordered](d *cborDecoder) (Snapshot[K, V], error) {
+	var s Snapshot[K, V]
+	var n uint64
+	s.Cap, n = d.header()
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		s.Entries = append(s.Entries, decodeEntry[K, V](d))
+	}
+	if d.err != nil {
+		return Snapshot[K, V]{}, d.err
+	}
+	return s, nil
+}
+
+// cborEncoder writes CBOR data items, recording the first error.
+type cborEncoder struct {
+	w   *bufio.Writer
+	buf [9]byte
+	err error
+}
+
+func newCBOREncoder(w io.Writer) *cborEncoder {
+	return &cborEncoder{w: bufio.NewWriter(w)}
+}
+
+// header writes the beginning of a snapshot containing n entries.
+func (e *cborEncoder) header(cap int, n int) {
+	e.head(cborArray, 2)
+	e.int(int64(cap))
+	e.head(cborArray, uint64(n))
+}
+
+// encodeEntry writes a single entry of a snapshot.
+func encodeEntry[K comparable, V ordering.Ordered](e *cborEncoder, entry Entry[K, V]) {
+	if entry.ExpiresAt.IsZero() {
+		e.head(cborArray, 4)
+	} else {
+		e.head(cborArray, 5)
+	}
+	e.value(reflect.ValueOf(entry.Key), cborBytes)
+	e.value(reflect.ValueOf(entry.Value), cborText)
+	e.int(int64(entry.Tiebreak))
+	e.time(entry.AddedAt)
+	if !entry.ExpiresAt.IsZero() {
+		e.time(entry.ExpiresAt)
+	}
+}
+
+func (e *cborEncoder) flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+func (e *cborEncoder) write(p []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(p)
+	}
+}
+
+// head writes the initial byte of a data item with the given major type and argument.
+func (e *cborEncoder) head(major byte, arg uint64) {
+	b := e.buf[:]
+	switch {
+	case arg < 24:
+		b[0] = major<<5 | byte(arg)
+		b = b[:1]
+	case arg <= math.MaxUint8:
+		b[0] = major<<5 | 24
+		b[1] = byte(arg)
+		b = b[:2]
+	case arg <= math.MaxUint16:
+		b[0] = major<<5 | 25
+		binary.BigEndian.PutUint16(b[1:], uint16(arg))
+		b = b[:3]
+	case arg <= math.MaxUint32:
+		b[0] = major<<5 | 26
+		binary.BigEndian.PutUint32(b[1:], uint32(arg))
+		b = b[:5]
+	default:
+		b[0] = major<<5 | 27
+		binary.BigEndian.PutUint64(b[1:], arg)
+	}
+	e.write(b)
+}
+
+func (e *cborEncoder) int(v int64) {
+	if v < 0 {
+		e.head(cborNegInt, uint64(-1-v))
+		return
+	}
+	e.head(cborUint, uint64(v))
+}
+
+// time writes t as nanoseconds since the Unix epoch or null for the zero time.
+func (e *cborEncoder) time(t time.Time) {
+	if t.IsZero() {
+		e.write([]byte{cborNull})
+		return
+	}
+	e.int(t.UnixNano())
+}
+
+func (e *cborEncoder) string(major byte, s string) {
+	e.head(major, uint64(len(s)))
+	if e.err == nil {
+		_, e.err = e.w.WriteString(s)
+	}
+}
+
+// value writes a number, a string using the given major type or a byte array.
+func (e *cborEncoder) value(v reflect.Value, strMajor byte) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.head(cborUint, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.buf[0] = cborFloat64
+		binary.BigEndian.PutUint64(e.buf[1:], math.Float64bits(v.Float()))
+		e.write(e.buf[:])
+	case reflect.String:
+		e.string(strMajor, v.String())
+	case reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			e.unsupported(v)
+			return
+		}
+		e.head(cborBytes, uint64(v.Len()))
+		for i := 0; i < v.Len() && e.err == nil; i++ {
+			e.err = e.w.WriteByte(byte(v.Index(i).Uint()))
+		}
+	default:
+		e.unsupported(v)
+	}
+}
+
+func (e *cborEncoder) unsupported(v reflect.Value) {
+	if e.err == nil {
+		e.err = fmt.Errorf("unsupported type %s", v.Type())
+	}
+}
+
+// byteReader is a reader that can also read single bytes.
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// exactReader implements io.ByteReader for a reader without buffering, so that no data following the last byte
+// requested is consumed from the underlying reader.
+type exactReader struct {
+	r   io.Reader
+	buf [1]byte
+}
+
+func (e *exactReader) Read(p []byte) (int, error) {
+	return e.r.Read(p)
+}
+
+func (e *exactReader) ReadByte() (byte, error) {
+	_, err := io.ReadFull(e.r, e.buf[:])
+	return e.buf[0], err
+}
+
+// asByteReader returns r itself, if it implements io.ByteReader, or an exactReader reading from r.
+func asByteReader(r io.Reader) byteReader {
+	if br, ok := r.(byteReader); ok {
+		return br
+	}
+	return &exactReader{r: r}
+}
+
+// cborDecoder reads CBOR data items, recording the first error.
+// It never reads beyond the end of the last data item, so that the underlying reader can be used afterwards.
+type cborDecoder struct {
+	r      byteReader
+	next   byte // byte read ahead by null
+	peeked bool // whether next is valid
+	err    error
+}
+
+func newCBORDecoder(r byteReader) *cborDecoder {
+	return &cborDecoder{r: r}
+}
+
+// header reads the beginning of a snapshot and returns its capacity and number of entries.
+func (d *cborDecoder) header() (cap int, n uint64) {
+	d.expect(cborArray, 2)
+	cap = d.int()
+	return cap, d.arrayLen()
+}
+
+// decodeEntry reads a single entry of a snapshot.
+func decodeEntry[K comparable, V ordering.Ordered](d *cborDecoder) Entry[K, V] {
+	var entry Entry[K, V]
+	m, n := d.head()
+	if d.err == nil && (m != cborArray || n < 4 || n > 5) {
+		d.fail("unexpected data item (%d, %d)", m, n)
+	}
+	d.value(reflect.ValueOf(&entry.Key).Elem(), cborBytes)
+	d.value(reflect.ValueOf(&entry.Value).Elem(), cborText)
+	entry.Tiebreak = d.int()
+	entry.AddedAt = d.time()
+	if n == 5 {
+		entry.ExpiresAt = d.time()
+	}
+	return entry
+}
+
+// time reads a time encoded as nanoseconds since the Unix epoch or null for the zero time.
+func (d *cborDecoder) time() time.Time {
+	if d.null() {
+		return time.Time{}
+	}
+	return time.Unix(0, d.int64())
+}
+
+// readByte reads the next byte, which may have been read ahead by null.
+func (d *cborDecoder) readByte() (byte, error) {
+	if d.peeked {
+		d.peeked = false
+		return d.next, nil
+	}
+	return d.r.ReadByte()
+}
+
+func (d *cborDecoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: %s", ErrInvalidSnapshot, fmt.Sprintf(format, args...))
+	}
+}
+
+// head reads the initial byte of a data item and returns its major type and argument.
+func (d *cborDecoder) head() (byte, uint64) {
+	if d.err != nil {
+		return 0, 0
+	}
+	b, err := d.readByte()
+	if err != nil {
+		d.fail("%v", err)
+		return 0, 0
+	}
+	major, info := b>>5, b&31
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info)
+	case info <= 27:
+		size = 1 << (info - 24)
+	default:
+		d.fail("unsupported additional information %d", info)
+		return 0, 0
+	}
+	var buf [8]byte
+	if _, err := io.ReadFull(d.r, buf[8-size:]); err != nil {
+		d.fail("%v", err)
+		return 0, 0
+	}
+	return major, binary.BigEndian.Uint64(buf[:])
+}
+
+func (d *cborDecoder) expect(major byte, arg uint64) {
+	if m, a := d.head(); d.err == nil && (m != major || a != arg) {
+		d.fail("unexpected data item (%d, %d)", m, a)
+	}
+}
+
+func (d *cborDecoder) arrayLen() uint64 {
+	m, n := d.head()
+	if d.err == nil && m != cborArray {
+		d.fail("unexpected major type %d", m)
+	}
+	return n
+}
+
+func (d *cborDecoder) int64() int64 {
+	m, n := d.head()
+	if d.err != nil {
+		return 0
+	}
+	switch {
+	case m == cborUint && n <= math.MaxInt64:
+		return int64(n)
+	case m == cborNegInt && n <= math.MaxInt64:
+		return -1 - int64(n)
+	}
+	d.fail("invalid integer (%d, %d)", m, n)
+	return 0
+}
+
+func (d *cborDecoder) int() int {
+	v := d.int64()
+	if v < math.MinInt || v > math.MaxInt {
+		d.fail("integer overflow %d", v)
+		return 0
+	}
+	return int(v)
+}
+
+// value reads a number, a string using the given major type or a byte array into the settable v.
+func (d *cborDecoder) value(v reflect.Value, strMajor byte) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i := d.int64(); v.OverflowInt(i) {
+			d.fail("integer overflow %d", i)
+		} else {
+			v.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		m, n := d.head()
+		if d.err == nil && (m != cborUint || v.OverflowUint(n)) {
+			d.fail("invalid unsigned integer (%d, %d)", m, n)
+		}
+		if d.err == nil {
+			v.SetUint(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		d.float64(v)
+	case reflect.String:
+		v.SetString(d.string(strMajor))
+	case reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			d.fail("unsupported type %s", v.Type())
+			return
+		}
+		m, n := d.head()
+		if d.err == nil && (m != cborBytes || n != uint64(v.Len())) {
+			d.fail("invalid byte array (%d, %d)", m, n)
+		}
+		for i := 0; i < v.Len() && d.err == nil; i++ {
+			b, err := d.readByte()
+			if err != nil {
+				d.fail("%v", err)
+				return
+			}
+			v.Index(i).SetUint(uint64(b))
+		}
+	default:
+		d.fail("unsupported type %s", v.Type())
+	}
+}
+
+func (d *cborDecoder) float64(v reflect.Value) {
+	if d.err != nil {
+		return
+	}
+	b, err := d.readByte()
+	if err == nil && b != cborFloat64 {
+		d.fail("unexpected initial byte %#x", b)
+		return
+	}
+	var buf [8]byte
+	if err == nil {
+		_, err = io.ReadFull(d.r, buf[:])
+	}
+	if err != nil {
+		d.fail("%v", err)
+		return
+	}
+	f := math.Float64frombits(binary.BigEndian.Uint64(buf[:]))
+	if v.OverflowFloat(f) {
+		d.fail("float overflow %g", f)
+		return
+	}
+	v.SetFloat(f)
+}
+
+// string reads a byte or text string depending on the given major type.
+func (d *cborDecoder) string(major byte) string {
+	m, n := d.head()
+	if d.err != nil {
+		return ""
+	}
+	if m != major || n > math.MaxInt64 {
+		d.fail("invalid string (%d, %d)", m, n)
+		return ""
+	}
+	// copy instead of allocating n bytes up front to not trust the announced length
+	var b strings.Builder
+	if _, err := io.CopyN(&b, d.r, int64(n)); err != nil {
+		d.fail("%v", err)
+		return ""
+	}
+	return b.String()
+}
+
+// null consumes a null data item and returns true, if the next data item is null.
+func (d *cborDecoder) null() bool {
+	if d.err != nil {
+		return false
+	}
+	b, err := d.readByte()
+	if err != nil {
+		d.fail("%v", err)
+		return false
+	}
+	if b != cborNull {
+		d.next, d.peeked = b, true
+		return false
+	}
+	return true
+}
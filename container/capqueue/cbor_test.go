@@ -0,0 +1,112 @@
+package capqueue_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestCBOR_Encode(t *testing.T) {
+	s := Snapshot[string, int]{
+		Cap: 1000,
+		Entries: []Entry[string, int]{
+			{Key: "a", Value: 1, Tiebreak: -1},
+			{Key: "", Value: -500, AddedAt: time.Unix(0, 24)},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, CBOR[string, int]().Encode(&buf, s))
+	assert.Equal(t, []byte{
+		0x82,             // array(2)
+		0x19, 0x03, 0xe8, // 1000
+		0x82,      // array(2)
+		0x84,      // array(4)
+		0x41, 'a', // bytes(1)
+		0x01,             // 1
+		0x20,             // -1
+		0xf6,             // null
+		0x84,             // array(4)
+		0x40,             // bytes(0)
+		0x39, 0x01, 0xf3, // -500
+		0x00,       // 0
+		0x18, 0x18, // 24
+	}, buf.Bytes())
+}
+
+func TestCBOR_BinaryKeys(t *testing.T) {
+	s := Snapshot[string, int]{Entries: []Entry[string, int]{{Key: "\x00\xff\xfe"}}}
+
+	var buf bytes.Buffer
+	require.NoError(t, CBOR[string, int]().Encode(&buf, s))
+	decoded, err := CBOR[string, int]().Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, s, decoded)
+}
+
+func TestCBOR_ValueTypes(t *testing.T) {
+	floats := Snapshot[string, float64]{Entries: []Entry[string, float64]{{Key: "a", Value: 0.5}, {Key: "b", Value: -1e300}}}
+	var buf bytes.Buffer
+	require.NoError(t, CBOR[string, float64]().Encode(&buf, floats))
+	decodedFloats, err := CBOR[string, float64]().Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, floats, decodedFloats)
+
+	strings := Snapshot[string, string]{Entries: []Entry[string, string]{{Key: "a", Value: "x"}, {Key: "b", Value: "unicode ✓"}}}
+	buf.Reset()
+	require.NoError(t, CBOR[string, string]().Encode(&buf, strings))
+	decodedStrings, err := CBOR[string, string]().Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, strings, decodedStrings)
+
+	// values must fit into the value type
+	buf.Reset()
+	require.NoError(t, CBOR[string, int]().Encode(&buf, Snapshot[string, int]{Entries: []Entry[string, int]{{Value: 1 << 10}}}))
+	_, err = CBOR[string, uint8]().Decode(&buf)
+	assert.True(t, errors.Is(err, ErrInvalidSnapshot))
+}
+
+func TestCBOR_KeyTypes(t *testing.T) {
+	ints := Snapshot[int64, int]{Entries: []Entry[int64, int]{{Key: -1, Value: 1}, {Key: 1 << 40, Value: 2}}}
+	var buf bytes.Buffer
+	require.NoError(t, CBOR[int64, int]().Encode(&buf, ints))
+	decodedInts, err := CBOR[int64, int]().Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, ints, decodedInts)
+
+	arrays := Snapshot[[4]byte, int]{Entries: []Entry[[4]byte, int]{{Key: [4]byte{1, 2, 3, 4}, Value: 1}}}
+	buf.Reset()
+	require.NoError(t, CBOR[[4]byte, int]().Encode(&buf, arrays))
+	decodedArrays, err := CBOR[[4]byte, int]().Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, arrays, decodedArrays)
+
+	// byte arrays are encoded as byte strings, but their length must match
+	buf.Reset()
+	require.NoError(t, CBOR[[4]byte, int]().Encode(&buf, arrays))
+	_, err = CBOR[[3]byte, int]().Decode(&buf)
+	assert.True(t, errors.Is(err, ErrInvalidSnapshot))
+
+	type point struct{ x, y int }
+	s := Snapshot[point, int]{Entries: []Entry[point, int]{{Key: point{1, 2}}}}
+	assert.Error(t, CBOR[point, int]().Encode(&buf, s))
+}
+
+func TestCBOR_DecodeInvalid(t *testing.T) {
+	for _, data := range [][]byte{
+		{},                       // empty
+		{0x83},                   // wrong array length
+		{0x82, 0x01, 0x81, 0x84}, // truncated entry
+		{0x82, 0x01, 0x81, 0x84, 0x5a, 0xff, 0xff, 0xff, 0xff},       // truncated key
+		{0x82, 0x01, 0x81, 0x84, 0x40, 0x1b, 0xff, 0, 0, 0, 0, 0, 0}, // integer overflow
+		{0x82, 0x01, 0x9f}, // indefinite length
+	} {
+		_, err := CBOR[string, int]().Decode(bytes.NewReader(data))
+		assert.Truef(t, errors.Is(err, ErrInvalidSnapshot), "data: %x", data)
+	}
+}
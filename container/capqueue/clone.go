@@ -0,0 +1,137 @@
+package capqueue
+
+import (
+	"math/rand"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// Clone returns an independent copy of the queue in O(n), e.g. to perform speculative modifications without
+// affecting the original queue. The copy has the same entries, heap layout, statistics and options, except for the
+// options that would let modifications of the copy escape: Evicted entries are dropped instead of being cascaded to
+// the overflow queue of WithOverflowTo, the callbacks of WithEvictCallback, WithMaxChangeCallback and
+// WithCapacityHeadroom are not called, and a TinyLFU admission policy is copied, while any other policy is consulted
+// without recording the keys of the copy. Callbacks registered by a Group, Selector or Sync are not copied either.
+// The windowed rates start empty and the random source is reseeded with the seed of the queue, so randomized
+// operations of the copy repeat the sequence of the original from its start. Mutations pending in an active batch are
+// not applied to the copy.
+func (h *CapQueue[K, V]) Clone() *CapQueue[K, V] {
+	c := new(CapQueue[K, V])
+	*c = *h
+	c.rand = rand.New(rand.NewSource(h.seed))
+	c.batchDepth = 0
+	c.pending = nil
+	c.maxHooks = nil
+	c.free = nil
+	c.admission = cloneAdmission(h.admission)
+	c.opts.admission = c.admission
+	c.overflow, c.opts.overflow = nil, nil
+	c.onEvict, c.opts.onEvict = nil, nil
+	c.opts.onMaxChange = nil
+	c.opts.onSoftLimit = nil
+
+	// the i-th item of the heap is copied to items[i]
+	n := len(h.heap)
+	if h.cap > n {
+		n = h.cap // preallocate the unused items as well
+	}
+	items := make([]item[K, V], n)
+	clone := func(it *item[K, V]) *item[K, V] {
+		return &items[it.index]
+	}
+
+	c.heap = make(binHeap[K, V], len(h.heap), cap(h.heap))
+	for i, it := range h.heap {
+		ci := &items[i]
+		*ci = *it
+		ci.prev, ci.next, ci.node = nil, nil, nil // linked below
+		ci.bucket, ci.prevUse, ci.nextUse = nil, nil, nil
+		c.heap[i] = ci
+	}
+	for i := len(h.heap); i < n; i++ {
+		c.release(&items[i])
+	}
+
+	if h.table != nil {
+		c.table = h.table.clone(clone)
+	} else {
+		c.index = make(map[K]*item[K, V], len(h.index))
+		for key, it := range h.index {
+			c.index[key] = clone(it)
+		}
+	}
+	c.order = itemList[K, V]{}
+	c.order.init()
+	for it := h.order.front(); it != nil; it = h.order.next(it) {
+		c.order.pushBack(clone(it))
+	}
+	if h.expiries != nil {
+		c.expiries = make(expiryHeap[K, V], len(h.expiries), cap(h.expiries))
+		for i, it := range h.expiries {
+			c.expiries[i] = clone(it)
+		}
+	}
+	if h.dirty != nil {
+		c.dirty = make([]*item[K, V], len(h.dirty), cap(h.dirty))
+		for i, it := range h.dirty {
+			c.dirty[i] = clone(it)
+		}
+	}
+
+	if b := h.bands; b != nil {
+		c.bands = newBandSet(b.bounds, c.higher)
+		c.bands.next = b.next
+		for i, bh := range b.heaps {
+			items := make([]*item[K, V], len(bh.items), cap(bh.items))
+			for j, it := range bh.items {
+				items[j] = clone(it)
+			}
+			c.bands.heaps[i].items = items
+		}
+	}
+	if x := h.values; x != nil {
+		c.values = newValueIndex[K, V](h.seed)
+		c.values.root = cloneValues(x.root, clone)
+	}
+	if m := h.median; m != nil {
+		c.median = newMedianSet[K, V]()
+		for _, it := range m.low.items {
+			c.median.low.items = append(c.median.low.items, clone(it))
+		}
+		for _, it := range m.high.items {
+			c.median.high.items = append(c.median.high.items, clone(it))
+		}
+	}
+	if l := h.freqs; l != nil {
+		c.freqs = newFreqList[K, V]()
+		for b := l.root.next; b != &l.root; b = b.next {
+			cb := c.freqs.insertAfter(c.freqs.root.prev, b.count)
+			for it := b.front; it != nil; it = it.nextUse {
+				cb.pushBack(clone(it))
+			}
+		}
+	}
+	if h.rates != nil {
+		c.rates = newRateSet()
+	}
+	if h.history != nil {
+		c.history = &maxHistory[K, V]{
+			records: append(make([]MaxRecord[K, V], 0, cap(h.history.records)), h.history.records...),
+			next:    h.history.next,
+		}
+		c.addMaxHook(c.recordMax)
+	}
+	return c
+}
+
+// cloneValues returns a copy of the treap n, linking the copied nodes with the cloned items.
+func cloneValues[K comparable, V ordering.Ordered](n *valueNode[K, V], clone func(*item[K, V]) *item[K, V]) *valueNode[K, V] {
+	if n == nil {
+		return nil
+	}
+	cn := &valueNode[K, V]{it: clone(n.it), prio: n.prio}
+	cn.it.node = cn
+	cn.left = cloneValues(n.left, clone)
+	cn.right = cloneValues(n.right, clone)
+	return cn
+}
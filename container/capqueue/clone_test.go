@@ -0,0 +1,134 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestCapQueue_Clone(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"default":    nil,
+		"bands":      {WithPriorityBands[string, int](testCapacity / 2)},
+		"valueIndex": {WithValueIndex[string, int]()},
+		"lazy":       {WithLazyDeletion[string, int]()},
+		"fixup":      {WithFixupBudget[string, int](1)},
+		"history":    {WithMaxHistory[string, int](testCapacity)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			q := New[string, int](testCapacity, opts...)
+			for i := 0; i < testCapacity+2; i++ {
+				q.Add(fmt.Sprint(i), i)
+			}
+			q.Delete("5")
+
+			c := q.Clone()
+			assert.Equal(t, q.Entries(), c.Entries())
+			assert.Equal(t, q.Stats(), c.Stats())
+			assert.Equal(t, q.MaxHistory(), c.MaxHistory())
+
+			// modifications of the clone do not affect the original and vice versa
+			c.Add("new", 2*testCapacity)
+			c.Update("6", -1)
+			q.Delete("2")
+			assert.Equal(t, testCapacity-2, q.Len())
+			assert.Equal(t, testCapacity, c.Len())
+			assert.False(t, q.Contains("new"))
+			assert.True(t, c.Contains("2"))
+			assert.Equal(t, 6, q.Value("6"))
+
+			maxKey, _ := c.Max()
+			assert.Equal(t, "new", maxKey)
+			maxKey, _ = q.Max()
+			assert.Equal(t, fmt.Sprint(testCapacity+1), maxKey)
+			minKey, _ := c.Min()
+			assert.Equal(t, "6", minKey)
+			assert.Equal(t, []Entry[string, int]{{Key: "6", Value: -1}}, keyValues(c.ValuesBetween(-1, -1)))
+
+			// drain both queues to verify the heap order
+			for prev := 3 * testCapacity; c.Len() > 0; {
+				_, value := c.PopFair()
+				if name != "bands" {
+					assert.LessOrEqual(t, value, prev)
+					prev = value
+				}
+			}
+			for prev := 3 * testCapacity; q.Len() > 0; {
+				_, value := q.PopMax()
+				assert.LessOrEqual(t, value, prev)
+				prev = value
+			}
+		})
+	}
+}
+
+func TestCapQueue_ClonePreallocated(t *testing.T) {
+	q := NewPreallocated[string, int](testCapacity, 2)
+	for i := 0; i < testCapacity/2; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	c := q.Clone()
+	keys := make([]string, testCapacity)
+	for i := range keys {
+		keys[i] = fmt.Sprint(i)
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		for i := 0; i < 2*testCapacity; i++ {
+			c.Add(keys[i%testCapacity], i)
+		}
+	})
+	assert.Zero(t, allocs)
+	assert.Equal(t, testCapacity/2, q.Len())
+}
+
+func TestCapQueue_CloneIsolated(t *testing.T) {
+	overflow := New[string, int](testCapacity)
+	policy := NewTinyLFU[string, int](testCapacity)
+	var callbacks int
+	q := New[string, int](testCapacity,
+		WithOverflowTo[string, int](overflow),
+		WithAdmission[string, int](policy),
+		WithEvictCallback[string, int](func(string, int) { callbacks++ }),
+		WithMaxChangeCallback[string, int](func() { callbacks++ }),
+		WithCapacityHeadroom[string, int](1, func(int) { callbacks++ }),
+	)
+	for i := 0; i < testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	q.Add("new", 0) // recorded once, but not admitted
+	callbacks = 0
+
+	c := q.Clone()
+	for i := 0; i < 3; i++ {
+		c.Add("new", 2*testCapacity) // admitted with the third addition
+	}
+	assert.True(t, c.Contains("new"))
+	assert.False(t, q.Contains("new"))
+	assert.Equal(t, 1, policy.Frequency("new"))
+	assert.Zero(t, overflow.Len())
+	assert.Zero(t, callbacks)
+
+	// the callbacks of the original are still registered
+	q.Delete(fmt.Sprint(testCapacity - 1))
+	assert.Equal(t, 1, callbacks)
+}
+
+// countingPolicy admits every entry and counts the recorded keys.
+type countingPolicy struct{ records int }
+
+func (p *countingPolicy) Record(string)                      { p.records++ }
+func (p *countingPolicy) Admit(_, _ Entry[string, int]) bool { return true }
+
+func TestCapQueue_CloneAdmission(t *testing.T) {
+	policy := &countingPolicy{}
+	q := New[string, int](1, WithAdmission[string, int](policy))
+	q.Add("a", 1)
+
+	// the policy is consulted by the clone, but the keys are not recorded
+	c := q.Clone()
+	c.Add("b", 2)
+	assert.True(t, c.Contains("b"))
+	assert.Equal(t, 1, policy.records)
+}
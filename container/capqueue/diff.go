@@ -0,0 +1,54 @@
+package capqueue
+
+import (
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// Changes describes the differences between two snapshots.
+type Changes[K comparable, V ordering.Ordered] struct {
+	Added   []Entry[K, V] // entries whose keys are only contained in the new snapshot
+	Removed []K           // keys that are only contained in the old snapshot
+	Changed []Entry[K, V] // entries of the new snapshot whose value or tiebreak differs from the old snapshot
+}
+
+// Empty returns whether there are no differences.
+func (c Changes[K, V]) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0
+}
+
+// Diff returns the added, removed and re-prioritized keys between the old and the new snapshot.
+// Added and changed entries are ordered as in the new snapshot, removed keys as in the old snapshot. If a snapshot
+// contains the same key more than once, the last entry wins.
+func Diff[K comparable, V ordering.Ordered](old, new Snapshot[K, V]) Changes[K, V] {
+	before := lastEntries(old.Entries)
+	after := lastEntries(new.Entries)
+
+	var c Changes[K, V]
+	for i, e := range new.Entries {
+		if after[e.Key] != i {
+			continue // superseded by a later entry
+		}
+		j, ok := before[e.Key]
+		switch {
+		case !ok:
+			c.Added = append(c.Added, e)
+		case old.Entries[j].Value != e.Value || old.Entries[j].Tiebreak != e.Tiebreak:
+			c.Changed = append(c.Changed, e)
+		}
+	}
+	for i, e := range old.Entries {
+		if _, ok := after[e.Key]; !ok && before[e.Key] == i {
+			c.Removed = append(c.Removed, e.Key)
+		}
+	}
+	return c
+}
+
+// lastEntries maps every key to the index of its last entry.
+func lastEntries[K comparable, V ordering.Ordered](entries []Entry[K, V]) map[K]int {
+	m := make(map[K]int, len(entries))
+	for i, e := range entries {
+		m[e.Key] = i
+	}
+	return m
+}
@@ -0,0 +1,36 @@
+package capqueue_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestDiff(t *testing.T) {
+	q := New[string, int](testCapacity)
+	q.Add("removed", 1)
+	q.Add("changed", 2)
+	q.Add("unchanged", 3)
+	old := q.Export()
+	assert.True(t, Diff(old, old).Empty())
+
+	q.Delete("removed")
+	q.Delete("changed")
+	q.AddWithTiebreak("changed", 2, 1)
+	q.Add("added", 4)
+	c := Diff(old, q.Export())
+
+	assert.Equal(t, []string{"removed"}, c.Removed)
+	assert.Len(t, c.Changed, 1)
+	assert.Equal(t, "changed", c.Changed[0].Key)
+	assert.Len(t, c.Added, 1)
+	assert.Equal(t, "added", c.Added[0].Key)
+}
+
+func TestDiffDuplicates(t *testing.T) {
+	old := Snapshot[string, int]{Entries: []Entry[string, int]{{Key: "a", Value: 1}, {Key: "a", Value: 2}}}
+	new := Snapshot[string, int]{Entries: []Entry[string, int]{{Key: "a", Value: 2}, {Key: "a", Value: 3}}}
+	assert.Equal(t, Changes[string, int]{Changed: []Entry[string, int]{{Key: "a", Value: 3}}}, Diff(old, new))
+	assert.Equal(t, Changes[string, int]{Removed: []string{"a"}}, Diff(old, Snapshot[string, int]{}))
+}
@@ -0,0 +1,114 @@
+package capqueue
+
+import (
+	"context"
+)
+
+// DrainOrder specifies the order in which Drain removes the entries of a queue.
+type DrainOrder int
+
+const (
+	// DrainByPriority removes the entry with the highest priority first like PopMax.
+	DrainByPriority DrainOrder = iota
+	// DrainFIFO removes the oldest entry first like PopFirst.
+	DrainFIFO
+)
+
+// Drain starts a goroutine that removes the entries of the queue in the given order and sends them to the returned
+// channel, so that the queue can be used as a bounded buffer between producers adding entries and a consumer
+// receiving them. When the queue is empty, the goroutine waits until a new entry is added.
+// The channel is unbuffered and an entry is only removed from the queue once the previous one has been received.
+// When ctx is done, the channel is closed. An entry that has already been removed, but not received by then, is
+// added back to the queue, unless its key has been added again in the meantime.
+// Multiple Drain goroutines may consume the same queue, each entry is sent to exactly one of them.
+func (s *Sync[K, V]) Drain(ctx context.Context, order DrainOrder) <-chan Entry[K, V] {
+	pop := s.q.PopMaxEntry
+	if order == DrainFIFO {
+		pop = s.q.popFirstEntry
+	}
+	ch := make(chan Entry[K, V])
+	go func() {
+		defer close(ch)
+		for {
+			e, err := s.popWait(ctx, pop)
+			if err != nil {
+				return
+			}
+			select {
+			case ch <- e:
+			case <-ctx.Done():
+				s.putBack(e)
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// popWait removes an entry using pop, waiting until the queue is non-empty. It returns ctx.Err() when ctx is done
+// before an entry could be removed.
+func (s *Sync[K, V]) popWait(ctx context.Context, pop func() (Entry[K, V], bool)) (Entry[K, V], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var stop func()
+	for {
+		if e, ok := pop(); ok {
+			if stop != nil {
+				stop()
+			}
+			return e, nil
+		}
+		if err := ctx.Err(); err != nil {
+			if stop != nil {
+				stop()
+			}
+			return Entry[K, V]{}, err
+		}
+		// only watch ctx, when the queue is empty, as this starts a goroutine
+		if stop == nil {
+			stop = s.wakeOnDone(ctx)
+		}
+		s.nonEmpty.Wait()
+	}
+}
+
+// wakeOnDone wakes up all goroutines waiting for the queue to become non-empty, once ctx is done.
+// The returned function must be called to stop watching ctx.
+func (s *Sync[K, V]) wakeOnDone(ctx context.Context) func() {
+	if ctx.Done() == nil {
+		return noop
+	}
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			// acquire the lock, so that the waiting goroutine cannot miss the wake-up between checking ctx and waiting
+			s.mu.Lock()
+			s.nonEmpty.Broadcast()
+			s.mu.Unlock()
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// putBack adds an entry that was removed, but not delivered, back to the queue, unless its key has been added again.
+func (s *Sync[K, V]) putBack(e Entry[K, V]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.q.lookup(e.Key); !ok {
+		_ = s.q.add(e)
+	}
+}
+
+// popFirstEntry removes and returns the oldest entry. The second return value is false, if the queue is empty.
+func (h *CapQueue[K, V]) popFirstEntry() (Entry[K, V], bool) {
+	h.expire()
+	if h.size() == 0 {
+		return Entry[K, V]{}, false
+	}
+	it := h.first()
+	e := it.entry()
+	h.remove(it)
+	return e, true
+}
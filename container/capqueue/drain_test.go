@@ -0,0 +1,132 @@
+package capqueue_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestSync_Drain(t *testing.T) {
+	for _, tt := range []struct {
+		order DrainOrder
+		keys  []string
+	}{
+		{DrainByPriority, []string{"b", "c", "a"}},
+		{DrainFIFO, []string{"a", "b", "c"}},
+	} {
+		q := NewSync[string, int](testCapacity)
+		q.Add("a", 1)
+		q.Add("b", 3)
+		q.Add("c", 2)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := q.Drain(ctx, tt.order)
+		var keys []string
+		for i := 0; i < 3; i++ {
+			keys = append(keys, (<-ch).Key)
+		}
+		assert.Equal(t, tt.keys, keys)
+
+		// the consumer waits for new entries
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			q.AddWithPayload("d", 0, "payload")
+		}()
+		e := <-ch
+		assert.Equal(t, "d", e.Key)
+		assert.Equal(t, "payload", e.Payload)
+
+		cancel()
+		_, ok := <-ch
+		assert.False(t, ok)
+	}
+}
+
+func TestSync_DrainCancel(t *testing.T) {
+	q := NewSync[string, int](testCapacity)
+	q.Add("a", 2)
+	q.Add("b", 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := q.Drain(ctx, DrainByPriority)
+	assert.Equal(t, "a", (<-ch).Key)
+
+	// the next entry is removed, but it is not received before the context is done
+	assert.Eventually(t, func() bool { return q.Len() == 0 }, time.Second, time.Millisecond)
+	cancel()
+	assert.Eventually(t, func() bool { return q.Len() == 1 }, time.Second, time.Millisecond)
+	_, ok := <-ch
+	assert.False(t, ok)
+	assert.Equal(t, []string{"b"}, q.Keys())
+}
+
+func TestSync_PopMaxWait(t *testing.T) {
+	q := NewSync[string, int](testCapacity)
+	q.Add("a", 1)
+	q.Add("b", 2)
+
+	key, value, err := q.PopMaxWait(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "b", key)
+	assert.Equal(t, 2, value)
+
+	_, _, _ = q.PopMaxWait(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Add("c", 3)
+	}()
+	key, _, err = q.PopMaxWait(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "c", key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err = q.PopMaxWait(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestSync_PopMaxWaitAllocs(t *testing.T) {
+	q := NewSync[string, int](testCapacity)
+	popAllocs := func(ctx context.Context) float64 {
+		return testing.AllocsPerRun(100, func() {
+			q.Add("a", 1)
+			_, _, _ = q.PopMaxWait(ctx)
+		})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// popping from a non-empty queue does not start a goroutine watching ctx
+	assert.Equal(t, popAllocs(context.Background()), popAllocs(ctx))
+}
+
+func TestSync_PopMaxWaitParallel(t *testing.T) {
+	const workers = 4
+	q := NewSync[int, int](0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan int)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for {
+				key, _, err := q.PopMaxWait(ctx)
+				if err != nil {
+					return
+				}
+				results <- key
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		q.Add(i, i)
+	}
+	seen := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		seen[<-results] = true
+	}
+	assert.Len(t, seen, 100)
+	cancel()
+}
@@ -0,0 +1,162 @@
+package capqueue
+
+import (
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// EvictionPolicy determines which entry is evicted when a new entry is added to a full queue.
+type EvictionPolicy int
+
+const (
+	// EvictOldest evicts the entry that was added to the queue first. This is the default policy.
+	EvictOldest EvictionPolicy = iota
+	// EvictLRU evicts the least recently used entry, where adding an entry or accessing it using Get, Value or Max
+	// counts as a use. The entries are kept in the order of their last use instead of their insertion, which is
+	// reflected by First, Keys and all other methods referring to the insertion order, but an access does not change
+	// the time of addition of the entry.
+	EvictLRU
+	// EvictLFU evicts the least frequently used entry, where adding an entry or accessing it using Get, Value or Max
+	// counts as a use. Between entries with the same number of uses, the least recently used one is evicted.
+	// In contrast to EvictLRU, the insertion order is not affected by the uses. Removing an entry discards its count.
+	EvictLFU
+	// EvictLowest evicts the entry with the lowest priority, i.e. the one returned by Min. A new entry that does not
+	// have a higher priority than this entry is rejected instead and counted in Stats.Rejections. Finding the entry
+	// takes O(n), unless the queue maintains a value index using WithValueIndex.
+	EvictLowest
+)
+
+// accessed records a use of the item by a lookup for the eviction policy.
+func (h *CapQueue[K, V]) accessed(it *item[K, V]) {
+	if !h.tracksAccess() {
+		return
+	}
+	if h.batchDepth > 0 {
+		key := it.key
+		h.pending = append(h.pending, func() {
+			if it, ok := h.find(key); ok {
+				h.accessed(it)
+			}
+		})
+		return
+	}
+	switch h.opts.eviction {
+	case EvictLRU:
+		h.order.remove(it)
+		h.order.pushBack(it)
+	case EvictLFU:
+		h.freqs.use(it)
+	}
+}
+
+// outranks returns whether the new entry has a higher priority than the given item.
+func (h *CapQueue[K, V]) outranks(e Entry[K, V], it *item[K, V]) bool {
+	candidate := item[K, V]{seq: h.seq + 1} // the newest item with WithStableOrder
+	candidate.set(e)
+	return h.higher(&candidate, it)
+}
+
+// tracksAccess returns whether lookups modify the queue to record the use of the entries.
+func (h *CapQueue[K, V]) tracksAccess() bool {
+	return h.opts.eviction == EvictLRU || h.opts.eviction == EvictLFU
+}
+
+// freqList keeps the items ordered by their use count for EvictLFU.
+// The items with the same count share a bucket, in which they are ordered by their last use, and the buckets are
+// ordered by ascending count. Thus, recording a use and finding the least frequently used item both take O(1).
+type freqList[K comparable, V ordering.Ordered] struct {
+	root freqBucket[K, V] // sentinel bucket, root.next is the bucket with the lowest count
+}
+
+// freqBucket contains all items with the same use count.
+type freqBucket[K comparable, V ordering.Ordered] struct {
+	count       uint64
+	prev, next  *freqBucket[K, V]
+	front, back *item[K, V] // least and most recently used item of the bucket
+}
+
+func newFreqList[K comparable, V ordering.Ordered]() *freqList[K, V] {
+	l := &freqList[K, V]{}
+	l.init()
+	return l
+}
+
+// init initializes or clears the list.
+func (l *freqList[K, V]) init() {
+	l.root.next = &l.root
+	l.root.prev = &l.root
+}
+
+// front returns the least frequently used item or nil if the list is empty.
+func (l *freqList[K, V]) front() *item[K, V] {
+	if l.root.next == &l.root {
+		return nil
+	}
+	return l.root.next.front
+}
+
+// add adds the new item with a use count of one.
+func (l *freqList[K, V]) add(it *item[K, V]) {
+	b := l.root.next
+	if b == &l.root || b.count != 1 {
+		b = l.insertAfter(&l.root, 1)
+	}
+	b.pushBack(it)
+}
+
+// use increments the use count of the item.
+func (l *freqList[K, V]) use(it *item[K, V]) {
+	b := it.bucket
+	next := b.next
+	if next == &l.root || next.count != b.count+1 {
+		next = l.insertAfter(b, b.count+1)
+	}
+	l.remove(it)
+	next.pushBack(it)
+}
+
+// remove removes the item and discards its use count.
+func (l *freqList[K, V]) remove(it *item[K, V]) {
+	b := it.bucket
+	b.remove(it)
+	if b.front == nil {
+		b.prev.next = b.next
+		b.next.prev = b.prev
+		b.prev, b.next = nil, nil // avoid memory leaks
+	}
+}
+
+// insertAfter inserts a new empty bucket with the given count after at.
+func (l *freqList[K, V]) insertAfter(at *freqBucket[K, V], count uint64) *freqBucket[K, V] {
+	b := &freqBucket[K, V]{count: count, prev: at, next: at.next}
+	at.next.prev = b
+	at.next = b
+	return b
+}
+
+// pushBack adds the item as the most recently used item of the bucket.
+func (b *freqBucket[K, V]) pushBack(it *item[K, V]) {
+	it.bucket = b
+	it.prevUse = b.back
+	it.nextUse = nil
+	if b.back != nil {
+		b.back.nextUse = it
+	} else {
+		b.front = it
+	}
+	b.back = it
+}
+
+// remove removes the item from the bucket.
+func (b *freqBucket[K, V]) remove(it *item[K, V]) {
+	if it.prevUse != nil {
+		it.prevUse.nextUse = it.nextUse
+	} else {
+		b.front = it.nextUse
+	}
+	if it.nextUse != nil {
+		it.nextUse.prevUse = it.prevUse
+	} else {
+		b.back = it.prevUse
+	}
+	it.bucket, it.prevUse, it.nextUse = nil, nil, nil
+}
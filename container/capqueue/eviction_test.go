@@ -0,0 +1,143 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestEvictLRU(t *testing.T) {
+	q := New[string, int](testCapacity, WithEviction[string, int](EvictLRU))
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+
+	_, ok := q.Get("1")
+	assert.True(t, ok)
+	assert.Equal(t, 2, q.Value("2"))
+	key, _ := q.Max()
+	assert.Equal(t, fmt.Sprint(testCapacity), key)
+	assert.True(t, q.Contains("3")) // not counted as a use
+	assert.Equal(t, []string{"3", "4"}, q.Keys()[:2])
+
+	key, _, _ = q.PeekEvictee()
+	assert.Equal(t, "3", key)
+	key, _, _ = q.AddEvict("new", 0)
+	assert.Equal(t, "3", key)
+	assert.True(t, q.Contains("1"))
+	assert.True(t, q.Contains("2"))
+
+	// lookups during a batch do not affect the iteration
+	var keys []string
+	q.ForEach(func(key string, _ int) bool {
+		keys = append(keys, key)
+		_, _ = q.Get(key)
+		return true
+	})
+	assert.Equal(t, keys, q.Keys())
+}
+
+func TestEvictLFU(t *testing.T) {
+	q := New[string, int](3, WithEviction[string, int](EvictLFU))
+	q.Add("a", 1)
+	q.Add("b", 2)
+	q.Add("c", 3)
+
+	_, _ = q.Get("a")
+	_, _ = q.Get("a")
+	_, _ = q.Get("b")
+	q.Max() // c
+	_, _ = q.Get("missing")
+	q.Add("b", 4) // adding an existing key counts as a use
+	assert.Equal(t, []string{"a", "c", "b"}, q.Keys())
+
+	// a and b have been used three times, c twice
+	key, _, _ := q.AddEvict("d", 0)
+	assert.Equal(t, "c", key)
+	// d is the least frequently used entry now
+	key, _, _ = q.AddEvict("e", 0)
+	assert.Equal(t, "d", key)
+
+	// the use count is kept in a clone
+	c := q.Clone()
+	_, _ = c.Get("e")
+	key, _, _ = c.PeekEvictee()
+	assert.Equal(t, "e", key)
+	key, _, _ = q.PeekEvictee()
+	assert.Equal(t, "e", key)
+
+	// the use count is discarded when the entry is removed
+	_, _ = q.Get("e")
+	q.Remove("a")
+	q.Add("a", 1)
+	key, _, _ = q.PeekEvictee()
+	assert.Equal(t, "a", key)
+
+	q.Clear()
+	q.Add("x", 1)
+	q.Add("y", 1)
+	q.Add("z", 1)
+	_, _ = q.Get("x")
+	q.SetCap(2)
+	assert.ElementsMatch(t, []string{"x", "z"}, q.Keys())
+}
+
+func TestEvictLRU_Sync(t *testing.T) {
+	const parallelism = 4
+
+	q := NewSync[string, int](testCapacity, WithEviction[string, int](EvictLRU))
+	var wg sync.WaitGroup
+	wg.Add(parallelism)
+	for i := 0; i < parallelism; i++ {
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				q.Add(fmt.Sprint(i, j), j)
+				_, _ = q.Get(fmt.Sprint(i, j/2))
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, testCapacity, q.Len())
+}
+
+func TestEvictLowest(t *testing.T) {
+	for _, opts := range [][]Option[string, int]{nil, {WithValueIndex[string, int]()}, {WithStableOrder[string, int]()}, {WithLazyDeletion[string, int]()}} {
+		q := New[string, int](3, append(opts, WithEviction[string, int](EvictLowest))...)
+		q.Add("b", 2)
+		q.Add("a", 1)
+		q.Add("c", 3)
+
+		key, _, _ := q.AddEvict("d", 4)
+		assert.Equal(t, "a", key)
+		// entries that would become the minimum are rejected
+		_, _, ok := q.AddEvict("x", 0)
+		assert.False(t, ok)
+		q.Add("y", 2)
+		assert.False(t, q.Contains("x"))
+		assert.False(t, q.Contains("y"))
+		assert.EqualValues(t, 2, q.Stats().Rejections)
+		// existing keys are updated
+		q.Add("b", 0)
+		assert.Equal(t, 0, q.Value("b"))
+
+		q.MergeMap(map[string]int{"e": 5, "f": -1})
+		assert.ElementsMatch(t, []string{"c", "d", "e"}, q.Keys())
+		q.SetCap(1)
+		assert.Equal(t, []string{"e"}, q.Keys())
+	}
+}
+
+func TestEvictLowest_Batch(t *testing.T) {
+	q := New[int, int](testCapacity, WithEviction[int, int](EvictLowest), WithEvictionBatch[int, int](3))
+	for i := testCapacity; i > 0; i-- {
+		q.Add(i, i)
+	}
+	q.Add(testCapacity+1, testCapacity+1)
+	assert.Equal(t, testCapacity-2, q.Len())
+	key, _ := q.Min()
+	assert.Equal(t, 4, key)
+}
@@ -0,0 +1,164 @@
+package capqueue
+
+// The fix-up budget bounds the number of swaps a single operation performs to restore the heap ordering.
+// Items whose fix-up could not be completed are marked as dirty and continue moving during subsequent operations.
+//
+// Dirty items are transparent with respect to the heap ordering: Every clean item is ordered below its nearest
+// clean ancestor, but nothing is known about the order of dirty items. As a consequence, the maximum is either the
+// root, a dirty item or the child of a dirty item, which top checks in O(k) for k dirty items.
+//
+// A dirty item becomes clean once it is ordered below its nearest clean ancestor and all its children are clean and
+// ordered below it. An item only swaps with a clean child, if the siblings of that child are also clean, as otherwise
+// the order between the promoted child and the descendants of a dirty sibling would be unknown.
+
+// Pending returns the number of entries whose heap fix-up has been deferred to subsequent operations.
+// This is always 0, unless the queue was created using the WithFixupBudget option.
+func (h *CapQueue[K, V]) Pending() int {
+	return len(h.dirty)
+}
+
+// top returns the item with the highest priority.
+func (h *CapQueue[K, V]) top() *item[K, V] {
+	best := h.heap[0]
+	for _, d := range h.dirty {
+		if h.higher(d, best) {
+			best = d
+		}
+		lo, hi := h.children(d.index)
+		for c := lo; c < hi; c++ {
+			if h.higher(h.heap[c], best) {
+				best = h.heap[c]
+			}
+		}
+	}
+	return best
+}
+
+// heapPush adds the item to the heap.
+func (h *CapQueue[K, V]) heapPush(it *item[K, V]) {
+	it.index = len(h.heap)
+	h.heap = append(h.heap, it)
+	if h.opts.fixupBudget == 0 {
+		h.up(it.index)
+		return
+	}
+	h.fixup(it)
+}
+
+// heapFix restores the heap ordering after the priority of the item has changed.
+func (h *CapQueue[K, V]) heapFix(it *item[K, V]) {
+	if h.opts.fixupBudget == 0 {
+		if !h.down(it.index, len(h.heap)) {
+			h.up(it.index)
+		}
+		return
+	}
+	h.fixup(it)
+}
+
+// heapRemove removes the item from the heap.
+func (h *CapQueue[K, V]) heapRemove(it *item[K, V]) {
+	i, n := it.index, len(h.heap)-1
+	if h.opts.fixupBudget == 0 {
+		if i != n {
+			h.heap.Swap(i, n)
+			if !h.down(i, n) {
+				h.up(i)
+			}
+		}
+		h.heap.Pop()
+		return
+	}
+	h.clean(it)
+	if i != n {
+		h.heap.Swap(i, n)
+	}
+	h.heap.Pop()
+	if i != n {
+		h.fixup(h.heap[i])
+	}
+}
+
+// fixup marks the item as dirty and spends the fix-up budget on moving the dirty items, oldest first.
+func (h *CapQueue[K, V]) fixup(it *item[K, V]) {
+	if !it.dirty {
+		it.dirty = true
+		it.dirtyIndex = len(h.dirty)
+		h.dirty = append(h.dirty, it)
+	}
+	budget := h.opts.fixupBudget
+	for i := 0; i < len(h.dirty) && budget > 0; {
+		if d := h.dirty[i]; h.sift(d, &budget) {
+			h.clean(d) // moves the last dirty item to position i
+		} else {
+			i++
+		}
+	}
+}
+
+// sift moves the dirty item towards its correct position using at most budget swaps.
+// It returns true, if the item has reached a position where it can be marked as clean.
+func (h *CapQueue[K, V]) sift(d *item[K, V], budget *int) bool {
+	for {
+		i := d.index
+		// move up, if the item is higher than its nearest clean ancestor
+		if i > 0 {
+			a := h.parent(i)
+			for a > 0 && h.heap[a].dirty {
+				a = h.parent(a)
+			}
+			if !h.heap[a].dirty && h.higher(d, h.heap[a]) {
+				if *budget == 0 {
+					return false
+				}
+				h.heap.Swap(i, h.parent(i))
+				*budget--
+				continue
+			}
+		}
+		// move down, if a child is higher than the item
+		lo, hi := h.children(i)
+		if lo >= hi {
+			return true
+		}
+		c := lo
+		for j := lo; j < hi; j++ {
+			if h.heap[j].dirty {
+				return false // wait until the children are clean
+			}
+			if h.higher(h.heap[j], h.heap[c]) {
+				c = j
+			}
+		}
+		if !h.higher(h.heap[c], d) {
+			return true
+		}
+		if *budget == 0 {
+			return false
+		}
+		h.heap.Swap(i, c)
+		*budget--
+	}
+}
+
+// clean removes the item from the list of dirty items.
+func (h *CapQueue[K, V]) clean(it *item[K, V]) {
+	if !it.dirty {
+		return
+	}
+	last := h.dirty[len(h.dirty)-1]
+	h.dirty[it.dirtyIndex] = last
+	last.dirtyIndex = it.dirtyIndex
+	h.dirty[len(h.dirty)-1] = nil
+	h.dirty = h.dirty[:len(h.dirty)-1]
+	it.dirty = false
+}
+
+// cleanAll marks all items as clean, after the heap ordering has been fully restored.
+func (h *CapQueue[K, V]) cleanAll() {
+	for i, it := range h.dirty {
+		it.dirty = false
+		h.dirty[i] = nil
+	}
+	h.dirty = h.dirty[:0]
+}
@@ -0,0 +1,64 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestWithFixupBudget(t *testing.T) {
+	const capacity = 1000
+	q := New[string, int](capacity, WithFixupBudget[string, int](1))
+	r := rand.New(rand.NewSource(0))
+
+	var maxPending int
+	for i := 0; i < 5*capacity; i++ {
+		key := fmt.Sprint(r.Intn(2 * capacity))
+		if r.Intn(3) == 0 {
+			q.Delete(key)
+		} else {
+			q.Delete(key)
+			q.Add(key, r.Intn(capacity))
+		}
+		if q.Pending() > maxPending {
+			maxPending = q.Pending()
+		}
+
+		// the maximum is always exact
+		if q.Len() > 0 {
+			_, value := q.Max()
+			assert.Equal(t, maxValue(q.Entries()), value)
+		}
+	}
+	assert.Greater(t, maxPending, 0)
+
+	q.Compact()
+	assert.Zero(t, q.Pending())
+	for q.Len() > 0 {
+		key, value := q.Max()
+		require.Equal(t, maxValue(q.Entries()), value)
+		q.Delete(key)
+	}
+}
+
+func TestWithFixupBudgetInvalid(t *testing.T) {
+	assert.Panics(t, func() { WithFixupBudget[string, int](0) })
+	assert.Panics(t, func() {
+		New[string, int](testCapacity, WithFixupBudget[string, int](1), WithLazyDeletion[string, int]())
+	})
+}
+
+// maxValue returns the highest value of the given entries.
+func maxValue(entries []Entry[string, int]) int {
+	max := entries[0].Value
+	for _, e := range entries[1:] {
+		if e.Value > max {
+			max = e.Value
+		}
+	}
+	return max
+}
@@ -0,0 +1,121 @@
+package capqueue
+
+import (
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// Group is a two-level priority queue, where each group maps to a child CapQueue.
+// The priority of a group is the highest value contained in its child queue, so Max returns the best entry of
+// the best group. Changes of a child queue automatically propagate to the group, even when the child is modified
+// directly through Child.
+// The number of groups is limited in the same way as the entries of a CapQueue: When a new group is added to a
+// full Group, the oldest group together with all its entries gets removed.
+type Group[K comparable, V ordering.Ordered] struct {
+	parent   *CapQueue[string, V]
+	children map[string]*CapQueue[K, V]
+	hooks    map[string]*maxHook
+	childCap int
+}
+
+// NewGroup creates a new Group holding at most cap groups with at most childCap entries each.
+func NewGroup[K comparable, V ordering.Ordered](cap int, childCap int) *Group[K, V] {
+	return &Group[K, V]{
+		parent:   New[string, V](cap),
+		children: make(map[string]*CapQueue[K, V], cap),
+		hooks:    make(map[string]*maxHook, cap),
+		childCap: childCap,
+	}
+}
+
+// Add adds a new key-value pair to the child queue of the given group, creating the group if necessary.
+// If the group already contains the key, its value is replaced.
+func (g *Group[K, V]) Add(group string, key K, value V) {
+	child, ok := g.children[group]
+	if !ok {
+		child = New[K, V](g.childCap)
+		g.hooks[group] = child.addMaxHook(func() { g.propagate(group, child) })
+	}
+	child.Delete(key)
+	child.Add(key, value)
+}
+
+// Delete removes the element with the given key from the given group.
+// It returns true, if an element was removed or false when no such element exists.
+// Groups without any remaining elements are removed.
+func (g *Group[K, V]) Delete(group string, key K) bool {
+	child, ok := g.children[group]
+	if !ok {
+		return false
+	}
+	return child.Delete(key)
+}
+
+// Child returns the child queue of the given group or nil if no such group exists.
+func (g *Group[K, V]) Child(group string) *CapQueue[K, V] {
+	return g.children[group]
+}
+
+// Len returns the number of groups.
+func (g *Group[K, V]) Len() int {
+	return g.parent.Len()
+}
+
+// Max returns the group and the key-value pair with the highest value among all groups.
+// This will panic if the group is empty.
+func (g *Group[K, V]) Max() (group string, key K, value V) {
+	group, key, value, err := g.TryMax()
+	if err != nil {
+		panic(err)
+	}
+	return group, key, value
+}
+
+// TryMax returns the group and the key-value pair with the highest value among all groups.
+// In contrast to Max, it returns ErrEmpty instead of panicking if the group is empty.
+func (g *Group[K, V]) TryMax() (group string, key K, value V, err error) {
+	for {
+		if group, _, err = g.parent.TryMax(); err != nil {
+			return "", key, value, err
+		}
+		// the priority of the group is outdated, if entries of its child queue have expired
+		child := g.children[group]
+		if child.expire() > 0 {
+			g.propagate(group, child)
+			continue
+		}
+		if key, value, err = child.TryMax(); err == nil {
+			return group, key, value, nil
+		}
+		g.propagate(group, child)
+	}
+}
+
+// propagate updates the priority of the given group to the maximum of its child queue.
+func (g *Group[K, V]) propagate(group string, child *CapQueue[K, V]) {
+	_, value, ok := child.peekMax()
+	if !ok {
+		g.remove(group)
+		return
+	}
+
+	if it, ok := g.parent.index[group]; ok {
+		g.parent.update(it, value)
+		return
+	}
+	// remove the oldest group explicitly to also drop its child queue
+	if g.parent.full() {
+		g.remove(g.parent.first().key)
+	}
+	g.parent.Add(group, value)
+	g.children[group] = child
+}
+
+// remove removes the given group and detaches its child queue.
+func (g *Group[K, V]) remove(group string) {
+	g.parent.Delete(group)
+	if child, ok := g.children[group]; ok {
+		child.removeMaxHook(g.hooks[group])
+		delete(g.children, group)
+		delete(g.hooks, group)
+	}
+}
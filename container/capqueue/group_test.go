@@ -0,0 +1,106 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestGroup_Add(t *testing.T) {
+	g := NewGroup[string, int](testCapacity, testCapacity)
+	assert.Panics(t, func() { _, _, _ = g.Max() })
+	_, _, _, err := g.TryMax()
+	assert.Equal(t, ErrEmpty, err)
+
+	g.Add("a", "1", 1)
+	g.Add("b", "2", 2)
+	g.Add("a", "3", 3)
+	assert.Equal(t, 2, g.Len())
+
+	group, key, value := g.Max()
+	assert.Equal(t, "a", group)
+	assert.Equal(t, "3", key)
+	assert.Equal(t, 3, value)
+
+	// replacing a value propagates to the group
+	g.Add("a", "3", 0)
+	group, key, value = g.Max()
+	assert.Equal(t, "b", group)
+	assert.Equal(t, "2", key)
+	assert.Equal(t, 2, value)
+}
+
+func TestGroup_Delete(t *testing.T) {
+	g := NewGroup[string, int](testCapacity, testCapacity)
+	g.Add("a", "1", 1)
+	g.Add("b", "2", 2)
+
+	assert.False(t, g.Delete("a", "not contained"))
+	assert.False(t, g.Delete("not contained", "1"))
+
+	assert.True(t, g.Delete("b", "2"))
+	assert.Nil(t, g.Child("b"))
+	assert.Equal(t, 1, g.Len())
+
+	group, key, _ := g.Max()
+	assert.Equal(t, "a", group)
+	assert.Equal(t, "1", key)
+}
+
+func TestGroup_Child(t *testing.T) {
+	g := NewGroup[string, int](testCapacity, testCapacity)
+	g.Add("a", "1", 1)
+	g.Add("b", "2", 2)
+
+	// modifications of the child propagate to the group
+	g.Child("a").Add("3", 3)
+	group, key, _ := g.Max()
+	assert.Equal(t, "a", group)
+	assert.Equal(t, "3", key)
+
+	g.Child("a").Delete("3")
+	group, key, _ = g.Max()
+	assert.Equal(t, "b", group)
+	assert.Equal(t, "2", key)
+}
+
+func TestGroup_Capacity(t *testing.T) {
+	g := NewGroup[string, int](testCapacity, 1)
+	for i := 0; i <= testCapacity; i++ {
+		g.Add(fmt.Sprint(i), "key", i)
+	}
+	assert.Equal(t, testCapacity, g.Len())
+	assert.Nil(t, g.Child("0"))
+
+	// the child capacity is respected
+	g.Add("1", "other", 0)
+	assert.Equal(t, 1, g.Child("1").Len())
+}
+
+func TestGroup_TTL(t *testing.T) {
+	g := NewGroup[string, int](testCapacity, testCapacity)
+	g.Add("a", "x", 1)
+	g.Child("a").AddWithTTL("y", 10, testTTL)
+	g.Add("b", "z", 5)
+	// the child queue of c expires completely
+	g.Add("c", "tmp", 0)
+	g.Child("c").AddWithTTL("w", 20, testTTL)
+	g.Child("c").Delete("tmp")
+
+	group, key, value := g.Max()
+	assert.Equal(t, "c", group)
+	assert.Equal(t, "w", key)
+	assert.Equal(t, 20, value)
+
+	time.Sleep(2 * testTTL)
+	group, key, value, err := g.TryMax()
+	assert.NoError(t, err)
+	assert.Equal(t, "b", group)
+	assert.Equal(t, "z", key)
+	assert.Equal(t, 5, value)
+	assert.Equal(t, 2, g.Len())
+	assert.Nil(t, g.Child("c"))
+}
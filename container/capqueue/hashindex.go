@@ -0,0 +1,213 @@
+package capqueue
+
+import (
+	"reflect"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// hashIndex is a compact replacement for the map from keys to items, see WithCompactIndex.
+// It is an open-addressing hash table using robin hood hashing with linear probing: Every item is stored at most as
+// far from the slot of its hash as the item it displaced, so that lookups of missing keys can stop early, and
+// deletions shift the following items back instead of leaving tombstones. In contrast to a map, the keys are not
+// duplicated, as they are read from the items themselves.
+type hashIndex[K comparable, V ordering.Ordered] struct {
+	slots []hashSlot[K, V]
+	mask  uint64 // len(slots)-1, the number of slots is a power of two
+	n     int    // number of occupied slots
+	seed  uint64
+	str   func(K) string // returns the string of a key
+}
+
+// hashSlot is a single slot of a hashIndex.
+type hashSlot[K comparable, V ordering.Ordered] struct {
+	it   *item[K, V] // nil if the slot is empty
+	hash uint32      // hash of the key, which also skips most comparisons of keys
+	dist uint32      // distance of the slot from the slot of the hash
+}
+
+// maxLoad is the maximum fraction of occupied slots in eighths.
+const maxLoad = 7
+
+// newHashIndex creates a new hashIndex with enough slots for n items without growing.
+// It panics if K is not a string type.
+func newHashIndex[K comparable, V ordering.Ordered](n int, seed int64) *hashIndex[K, V] {
+	t := &hashIndex[K, V]{seed: uint64(seed), str: stringKey[K]()}
+	t.slots = make([]hashSlot[K, V], slotsFor(n))
+	t.mask = uint64(len(t.slots) - 1)
+	return t
+}
+
+// stringKey returns a function converting keys of type K into strings, or panics if K is not a string type.
+func stringKey[K comparable]() func(K) string {
+	var key K
+	if _, ok := interface{}(key).(string); ok {
+		return func(key K) string { return interface{}(key).(string) }
+	}
+	if t := reflect.TypeOf(key); t != nil && t.Kind() == reflect.String {
+		return func(key K) string { return reflect.ValueOf(key).String() }
+	}
+	panic("compact index requires string keys")
+}
+
+// slotsFor returns the number of slots required for n items.
+func slotsFor(n int) int {
+	slots := 8
+	for slots*maxLoad/8 < n {
+		slots *= 2
+	}
+	return slots
+}
+
+// hashString returns the seeded 64-bit FNV-1a hash of s folded to 32 bits.
+func (t *hashIndex[K, V]) hashString(s string) uint32 {
+	h := 14695981039346656037 ^ t.seed
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	return uint32(h ^ h>>32) // mix the high bits into the bits selecting the slot
+}
+
+// hashBytes returns the same hash as hashString for the string of b without converting it.
+func (t *hashIndex[K, V]) hashBytes(b []byte) uint32 {
+	h := 14695981039346656037 ^ t.seed
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+	return uint32(h ^ h>>32)
+}
+
+// get returns the item with the given key.
+func (t *hashIndex[K, V]) get(key K) (*item[K, V], bool) {
+	h := t.hashString(t.str(key))
+	i := uint64(h) & t.mask
+	for dist := uint32(0); ; dist++ {
+		s := &t.slots[i]
+		if s.it == nil || s.dist < dist {
+			return nil, false // the key would have displaced this slot
+		}
+		if s.hash == h && s.it.key == key {
+			return s.it, true
+		}
+		i = (i + 1) & t.mask
+	}
+}
+
+// getBytes returns the item whose key is the string of b.
+func (t *hashIndex[K, V]) getBytes(b []byte) (*item[K, V], bool) {
+	h := t.hashBytes(b)
+	i := uint64(h) & t.mask
+	for dist := uint32(0); ; dist++ {
+		s := &t.slots[i]
+		if s.it == nil || s.dist < dist {
+			return nil, false
+		}
+		if s.hash == h && t.str(s.it.key) == string(b) {
+			return s.it, true
+		}
+		i = (i + 1) & t.mask
+	}
+}
+
+// put adds the item to the index, replacing an item with the same key.
+func (t *hashIndex[K, V]) put(it *item[K, V]) {
+	if (t.n+1)*8 > len(t.slots)*maxLoad {
+		t.resize(slotsFor(t.n + 1))
+	}
+	t.insert(hashSlot[K, V]{it: it, hash: t.hashString(t.str(it.key))}, it.key)
+}
+
+// insert stores the given slot, starting at the slot of its hash.
+func (t *hashIndex[K, V]) insert(cur hashSlot[K, V], key K) {
+	i := t.home(cur)
+	for {
+		s := &t.slots[i]
+		if s.it == nil {
+			*s = cur
+			t.n++
+			return
+		}
+		if s.dist == cur.dist && s.hash == cur.hash && s.it.key == key {
+			s.it = cur.it
+			return
+		}
+		if s.dist < cur.dist {
+			// take the slot from the item that is closer to the slot of its hash
+			*s, cur = cur, *s
+			key = cur.it.key
+		}
+		cur.dist++
+		i = (i + 1) & t.mask
+	}
+}
+
+// home returns the slot in which the insertion of the given slot continues.
+func (t *hashIndex[K, V]) home(s hashSlot[K, V]) uint64 {
+	return (uint64(s.hash) + uint64(s.dist)) & t.mask
+}
+
+// remove removes the item with the given key from the index.
+func (t *hashIndex[K, V]) remove(key K) {
+	h := t.hashString(t.str(key))
+	i := uint64(h) & t.mask
+	for dist := uint32(0); ; dist++ {
+		s := &t.slots[i]
+		if s.it == nil || s.dist < dist {
+			return
+		}
+		if s.hash == h && s.it.key == key {
+			break
+		}
+		i = (i + 1) & t.mask
+	}
+	// shift the following items back by one slot until an empty slot or an item in the slot of its hash is reached
+	for {
+		j := (i + 1) & t.mask
+		next := t.slots[j]
+		if next.it == nil || next.dist == 0 {
+			break
+		}
+		next.dist--
+		t.slots[i] = next
+		i = j
+	}
+	t.slots[i] = hashSlot[K, V]{}
+	t.n--
+}
+
+// resize moves all items into a new table with the given number of slots.
+func (t *hashIndex[K, V]) resize(n int) {
+	old := t.slots
+	t.slots = make([]hashSlot[K, V], n)
+	t.mask = uint64(n - 1)
+	t.n = 0
+	for _, s := range old {
+		if s.it != nil {
+			s.dist = 0
+			t.insert(s, s.it.key)
+		}
+	}
+}
+
+// clear removes all items from the index without releasing its memory.
+func (t *hashIndex[K, V]) clear() {
+	for i := range t.slots {
+		t.slots[i] = hashSlot[K, V]{}
+	}
+	t.n = 0
+}
+
+// clone returns a copy of the index, in which every item is replaced using f.
+func (t *hashIndex[K, V]) clone(f func(*item[K, V]) *item[K, V]) *hashIndex[K, V] {
+	c := *t
+	c.slots = make([]hashSlot[K, V], len(t.slots))
+	for i, s := range t.slots {
+		if s.it != nil {
+			s.it = f(s.it)
+		}
+		c.slots[i] = s
+	}
+	return &c
+}
@@ -0,0 +1,103 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestWithCompactIndex(t *testing.T) {
+	for _, cap := range []int{testCapacity, 0} {
+		t.Run(fmt.Sprint("cap=", cap), func(t *testing.T) {
+			q := New[string, int](cap, WithCompactIndex[string, int](), WithSeed[string, int](1))
+			ref := New[string, int](cap, WithSeed[string, int](1))
+			r := rand.New(rand.NewSource(1))
+			for i := 0; i < 10000; i++ {
+				key := fmt.Sprint(r.Intn(3 * testCapacity))
+				switch r.Intn(3) {
+				case 0, 1:
+					q.Add(key, i)
+					ref.Add(key, i)
+				case 2:
+					require.Equal(t, ref.Delete(key), q.Delete(key))
+				}
+				value, ok := q.Get(key)
+				refValue, refOK := ref.Get(key)
+				require.Equal(t, refOK, ok)
+				require.Equal(t, refValue, value)
+			}
+			assert.Equal(t, ref.Keys(), q.Keys())
+			for _, key := range ref.Keys() {
+				assert.Equal(t, ref.Value(key), ValueBytes(q, []byte(key)))
+			}
+
+			c := q.Clone()
+			q.ShrinkToFit()
+			for _, key := range ref.Keys() {
+				assert.True(t, q.Contains(key))
+				assert.True(t, DeleteBytes(c, []byte(key)))
+			}
+			assert.Zero(t, c.Len())
+
+			q.Clear()
+			for _, key := range ref.Keys() {
+				assert.False(t, q.Contains(key))
+			}
+		})
+	}
+}
+
+func TestWithCompactIndexKeyTypes(t *testing.T) {
+	type name string
+	q := New[name, int](testCapacity, WithCompactIndex[name, int]())
+	q.Add("a", 1)
+	assert.Equal(t, 1, q.Value("a"))
+
+	assert.Panics(t, func() { New[int, int](testCapacity, WithCompactIndex[int, int]()) })
+}
+
+func TestWithCompactIndexAllocs(t *testing.T) {
+	q := NewPreallocated[string, int](testCapacity, 2, WithCompactIndex[string, int]())
+	keys := make([]string, 2*testCapacity)
+	for i := range keys {
+		keys[i] = fmt.Sprint(i)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		for i, key := range keys {
+			q.Add(key, i)
+			_ = q.Value(key)
+		}
+		for _, key := range keys {
+			q.Delete(key)
+		}
+	})
+	assert.Zero(t, allocs)
+}
+
+func BenchmarkCapQueue_Get(b *testing.B) {
+	const n = 1 << 16
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprint("key", i)
+	}
+	for name, opts := range map[string][]Option[string, int]{
+		"map":     nil,
+		"compact": {WithCompactIndex[string, int]()},
+	} {
+		b.Run(name, func(b *testing.B) {
+			q := New[string, int](n, opts...)
+			for i, key := range keys {
+				q.Add(key, i)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = q.Get(keys[i%n])
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package capqueue
+
+import (
+	"time"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// MaxRecord describes a past maximum of a queue.
+type MaxRecord[K comparable, V ordering.Ordered] struct {
+	Time  time.Time // time when the entry became the maximum
+	Key   K
+	Value V
+}
+
+// maxHistory is a ring buffer of the most recent maxima.
+type maxHistory[K comparable, V ordering.Ordered] struct {
+	records []MaxRecord[K, V]
+	next    int // position of the next record, once the buffer is full
+}
+
+// MaxHistory returns the recorded maxima ordered from oldest to newest.
+// A new record is added whenever the entry with the highest value changes, while the queue is not empty.
+// It returns nil, unless the queue was created using the WithMaxHistory option.
+func (h *CapQueue[K, V]) MaxHistory() []MaxRecord[K, V] {
+	if h.history == nil {
+		return nil
+	}
+	r := h.history.records
+	records := make([]MaxRecord[K, V], 0, len(r))
+	records = append(records, r[h.history.next:]...)
+	return append(records, r[:h.history.next]...)
+}
+
+// recordMax adds the current maximum to the history.
+func (h *CapQueue[K, V]) recordMax() {
+	key, value, ok := h.peekMax()
+	if !ok {
+		return
+	}
+	h.history.add(MaxRecord[K, V]{Time: time.Now(), Key: key, Value: value})
+}
+
+func (m *maxHistory[K, V]) add(r MaxRecord[K, V]) {
+	if len(m.records) < cap(m.records) {
+		m.records = append(m.records, r)
+		return
+	}
+	m.records[m.next] = r
+	m.next = (m.next + 1) % len(m.records)
+}
@@ -0,0 +1,43 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestCapQueue_MaxHistory(t *testing.T) {
+	const size = 3
+
+	assert.Nil(t, New[string, int](testCapacity).MaxHistory())
+
+	q := New[string, int](testCapacity, WithMaxHistory[string, int](size))
+	assert.Empty(t, q.MaxHistory())
+
+	q.Add("1", 1)
+	q.Add("0", 0)
+	q.Add("2", 2)
+	keys := func() (keys []string) {
+		for _, r := range q.MaxHistory() {
+			keys = append(keys, r.Key)
+		}
+		return keys
+	}
+	assert.Equal(t, []string{"1", "2"}, keys())
+
+	for i := 3; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	assert.Equal(t, []string{fmt.Sprint(testCapacity - 2), fmt.Sprint(testCapacity - 1), fmt.Sprint(testCapacity)}, keys())
+
+	q.Delete(fmt.Sprint(testCapacity))
+	history := q.MaxHistory()
+	assert.Len(t, history, size)
+	assert.Equal(t, fmt.Sprint(testCapacity-1), history[size-1].Key)
+	assert.Equal(t, testCapacity-1, history[size-1].Value)
+	assert.False(t, history[size-1].Time.Before(history[0].Time))
+
+	assert.Panics(t, func() { WithMaxHistory[string, int](0) })
+}
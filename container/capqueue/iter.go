@@ -0,0 +1,57 @@
+//go:build go1.23
+
+package capqueue
+
+import (
+	"container/heap"
+	"iter"
+)
+
+// All returns an iterator over all key-value pairs of the queue, ordered from oldest to newest.
+// The queue may be modified during the iteration with the same guarantees as in ForEach.
+func (h *CapQueue[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		h.ForEach(yield)
+	}
+}
+
+// ByPriority returns an iterator over all key-value pairs of the queue, ordered by descending priority like Entries.
+// The iterator operates on a copy of the entries taken when the iteration starts, which is sorted lazily, so that
+// consuming only the first k pairs takes O(n + k log n).
+func (h *CapQueue[K, V]) ByPriority() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		h.expire()
+		eh := h.newEntryHeap()
+		for eh.Len() > 0 {
+			e := heap.Pop(eh).(Entry[K, V])
+			if !yield(e.Key, e.Value) {
+				return
+			}
+		}
+	}
+}
+
+// All returns an iterator over all key-value pairs of the queue, ordered from oldest to newest.
+// The queue may be modified during the iteration with the same guarantees as in ForEach.
+func (s *Sync[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		s.ForEach(yield)
+	}
+}
+
+// ByPriority returns an iterator over all key-value pairs of the queue, ordered by descending priority.
+// See CapQueue.ByPriority for details. The lock is only held while the entries are copied.
+func (s *Sync[K, V]) ByPriority() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		unlock := s.lockLookup(false)
+		s.q.expire()
+		eh := s.q.newEntryHeap()
+		unlock()
+		for eh.Len() > 0 {
+			e := heap.Pop(eh).(Entry[K, V])
+			if !yield(e.Key, e.Value) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+//go:build go1.23
+
+package capqueue_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestCapQueue_All(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity+1; i++ {
+		q.Add(fmt.Sprint(i), -i)
+	}
+
+	var entries []Entry[string, int]
+	for key, value := range q.All() {
+		entries = append(entries, Entry[string, int]{Key: key, Value: value})
+	}
+	assert.Equal(t, keyValues(q.OldestK(testCapacity)), entries)
+
+	var n int
+	for key := range q.All() {
+		q.Delete(key) // applied after the loop
+		if n++; n == 3 {
+			break
+		}
+	}
+	assert.Equal(t, testCapacity-3, q.Len())
+	assert.Equal(t, "5", q.Keys()[0])
+}
+
+func TestCapQueue_ByPriority(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity+1; i++ {
+		q.Add(fmt.Sprint(i), (i*3)%testCapacity)
+	}
+
+	var entries []Entry[string, int]
+	for key, value := range q.ByPriority() {
+		entries = append(entries, Entry[string, int]{Key: key, Value: value})
+		q.Delete(key) // does not affect the iteration
+	}
+	assert.Len(t, entries, testCapacity)
+	for i := 1; i < len(entries); i++ {
+		assert.Greater(t, entries[i-1].Value, entries[i].Value)
+	}
+	assert.Zero(t, q.Len())
+
+	// expired entries are not returned
+	q.Add("a", 1)
+	q.AddWithTTL("b", 2, testTTL)
+	time.Sleep(testTTL)
+	var keys []string
+	for key := range q.ByPriority() {
+		keys = append(keys, key)
+	}
+	assert.Equal(t, []string{"a"}, keys)
+}
+
+func TestSync_ByPriority(t *testing.T) {
+	q := NewSync[string, int](testCapacity)
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+
+	var keys []string
+	for key := range q.All() {
+		keys = append(keys, key)
+	}
+	assert.Equal(t, q.Keys(), keys)
+
+	expected := testCapacity
+	for key, value := range q.ByPriority() {
+		assert.Equal(t, expected, value)
+		q.Delete(key) // the lock is not held during the iteration
+		expected--
+	}
+	assert.Zero(t, q.Len())
+}
@@ -0,0 +1,35 @@
+package capqueue
+
+// Tombstones returns the number of deleted entries that have not yet been removed from the heap.
+// This is always 0, unless the queue was created using the WithLazyDeletion option.
+func (h *CapQueue[K, V]) Tombstones() int {
+	return h.tombstones
+}
+
+// Compact removes all tombstones from the heap and completes all deferred heap fix-ups in O(n).
+func (h *CapQueue[K, V]) Compact() {
+	if h.batchDepth > 0 {
+		h.pending = append(h.pending, h.Compact)
+		return
+	}
+	if h.tombstones > 0 || len(h.dirty) > 0 {
+		h.rebuild()
+	}
+}
+
+// compactionThreshold returns the fraction of tombstones in the heap above which the queue is compacted.
+func (h *CapQueue[K, V]) compactionThreshold() float64 {
+	if h.opts.compactAt == 0 {
+		return 0.5
+	}
+	return h.opts.compactAt
+}
+
+// pruneRoot removes tombstones from the top of the heap, so that the root is always a valid element.
+func (h *CapQueue[K, V]) pruneRoot() {
+	for len(h.heap) > 0 && h.heap[0].deleted {
+		it := h.heapPop()
+		h.tombstones--
+		h.release(it)
+	}
+}
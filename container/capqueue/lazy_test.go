@@ -0,0 +1,107 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestWithLazyDeletion(t *testing.T) {
+	q := New[string, int](testCapacity, WithLazyDeletion[string, int]())
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+
+	// deleting non-maximal entries creates tombstones
+	for i := 1; i <= testCapacity/2; i++ {
+		assert.True(t, q.Delete(fmt.Sprint(i)))
+	}
+	assert.Equal(t, testCapacity/2, q.Tombstones())
+	assert.Equal(t, testCapacity/2, q.Len())
+	assert.Zero(t, q.Value("1"))
+
+	// the maximum is never a tombstone
+	for i := testCapacity; i > testCapacity/2; i-- {
+		maxKey, maxValue := q.Max()
+		assert.Equal(t, i, maxValue)
+		value, ok := q.Remove(maxKey)
+		assert.True(t, ok)
+		assert.Equal(t, i, value)
+	}
+	assert.Zero(t, q.Len())
+	assert.Zero(t, q.Tombstones())
+}
+
+func TestCapQueue_Compact(t *testing.T) {
+	q := New[string, int](testCapacity, WithLazyDeletion[string, int]())
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	for i := 1; i <= testCapacity/2; i++ {
+		q.Delete(fmt.Sprint(i))
+	}
+
+	q.Compact()
+	assert.Zero(t, q.Tombstones())
+	assert.Equal(t, testCapacity/2, q.Len())
+
+	// the queue is fully functional after the compaction
+	for i := testCapacity + 1; i <= 2*testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	assert.Equal(t, testCapacity, q.Len())
+	for i := 2 * testCapacity; i > testCapacity; i-- {
+		maxKey, maxValue := q.Max()
+		assert.Equal(t, i, maxValue)
+		q.Delete(maxKey)
+	}
+}
+
+func TestWithLazyDeletionEviction(t *testing.T) {
+	q := New[string, int](testCapacity, WithLazyDeletion[string, int]())
+	q.Add("max", testCapacity)
+	for i := 1; i < testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	q.Delete(fmt.Sprint(testCapacity - 1))
+
+	// replacing the root by a small value must not expose a tombstone
+	q.Add("a", 0)
+	q.Add("b", 0)
+	maxKey, maxValue := q.Max()
+	assert.Equal(t, fmt.Sprint(testCapacity-2), maxKey)
+	assert.Equal(t, testCapacity-2, maxValue)
+}
+
+func TestWithCompactionThreshold(t *testing.T) {
+	const capacity = 100
+	for _, tt := range []struct {
+		opts       []Option[string, int]
+		tombstones int
+	}{
+		{nil, capacity / 2},
+		{[]Option[string, int]{WithCompactionThreshold[string, int](0.25)}, capacity / 4},
+		{[]Option[string, int]{WithCompactionThreshold[string, int](1)}, capacity - 1},
+	} {
+		q := New[string, int](capacity, append(tt.opts, WithLazyDeletion[string, int]())...)
+		for i := 1; i <= capacity; i++ {
+			q.Add(fmt.Sprint(i), i)
+		}
+
+		// delete all entries except the maximum, the queue is compacted once the threshold is exceeded
+		for i := 1; i < capacity; i++ {
+			q.Delete(fmt.Sprint(i))
+			if i <= tt.tombstones {
+				assert.Equal(t, i, q.Tombstones())
+			} else {
+				assert.Less(t, q.Tombstones(), i)
+			}
+		}
+		assert.Equal(t, 1, q.Len())
+	}
+
+	assert.Panics(t, func() { WithCompactionThreshold[string, int](0) })
+	assert.Panics(t, func() { WithCompactionThreshold[string, int](1.5) })
+}
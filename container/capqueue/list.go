@@ -0,0 +1,60 @@
+package capqueue
+
+import (
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// itemList is an intrusive doubly-linked list of items.
+// In contrast to container/list, the links are stored in the items themselves, so that no additional list element
+// needs to be allocated when an item is inserted.
+type itemList[K comparable, V ordering.Ordered] struct {
+	root item[K, V] // sentinel item, root.next is the front and root.prev the back of the list
+}
+
+// init initializes or clears the list.
+func (l *itemList[K, V]) init() {
+	l.root.next = &l.root
+	l.root.prev = &l.root
+}
+
+// front returns the first item of the list or nil if the list is empty.
+func (l *itemList[K, V]) front() *item[K, V] {
+	return l.next(&l.root)
+}
+
+// back returns the last item of the list or nil if the list is empty.
+func (l *itemList[K, V]) back() *item[K, V] {
+	return l.prev(&l.root)
+}
+
+// next returns the item following it or nil if it is the last item.
+func (l *itemList[K, V]) next(it *item[K, V]) *item[K, V] {
+	if it.next == &l.root {
+		return nil
+	}
+	return it.next
+}
+
+// prev returns the item preceding it or nil if it is the first item.
+func (l *itemList[K, V]) prev(it *item[K, V]) *item[K, V] {
+	if it.prev == &l.root {
+		return nil
+	}
+	return it.prev
+}
+
+// pushBack inserts it at the back of the list.
+func (l *itemList[K, V]) pushBack(it *item[K, V]) {
+	it.prev = l.root.prev
+	it.next = &l.root
+	it.prev.next = it
+	l.root.prev = it
+}
+
+// remove removes it from the list.
+func (l *itemList[K, V]) remove(it *item[K, V]) {
+	it.prev.next = it.next
+	it.next.prev = it.prev
+	it.next = nil // avoid memory leaks
+	it.prev = nil
+}
@@ -0,0 +1,121 @@
+package capqueue
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// medianSet maintains the median of the values of the items using two heaps: The lower half of the values is kept
+// in a max-heap and the upper half in a min-heap, so that the median is always the root of the lower heap.
+type medianSet[K comparable, V ordering.Ordered] struct {
+	low, high medianHeap[K, V]
+}
+
+// medianHeap is a heap of the items of one half of a medianSet.
+type medianHeap[K comparable, V ordering.Ordered] struct {
+	items []*item[K, V]
+	high  bool // whether this is the min-heap of the upper half
+}
+
+func newMedianSet[K comparable, V ordering.Ordered]() *medianSet[K, V] {
+	return &medianSet[K, V]{high: medianHeap[K, V]{high: true}}
+}
+
+// Median returns the median of the values of all entries, which is the lower one of the two middle values, if the
+// number of entries is even. The median only depends on the values, not on the ordering of the queue.
+// With WithMedian, this takes O(1) time. Otherwise, the values of all entries are sorted in O(n log n).
+// The second return value is false, if the queue is empty.
+func (h *CapQueue[K, V]) Median() (V, bool) {
+	h.expire()
+	if m := h.median; m != nil {
+		if len(m.low.items) == 0 {
+			var zero V
+			return zero, false
+		}
+		return m.low.items[0].value, true
+	}
+	if h.size() == 0 {
+		var zero V
+		return zero, false
+	}
+	values := make([]V, 0, h.size())
+	for it := h.order.front(); it != nil; it = h.order.next(it) {
+		values = append(values, it.value)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return values[(len(values)-1)/2], true
+}
+
+func (m *medianSet[K, V]) add(it *item[K, V]) {
+	if len(m.low.items) == 0 || it.value <= m.low.items[0].value {
+		heap.Push(&m.low, it)
+	} else {
+		heap.Push(&m.high, it)
+	}
+	m.balance()
+}
+
+func (m *medianSet[K, V]) remove(it *item[K, V]) {
+	if it.medianHigh {
+		heap.Remove(&m.high, it.medianIndex)
+	} else {
+		heap.Remove(&m.low, it.medianIndex)
+	}
+	m.balance()
+}
+
+// balance restores the invariant that the lower half contains as many items as the upper half or one more.
+func (m *medianSet[K, V]) balance() {
+	if len(m.low.items) > len(m.high.items)+1 {
+		heap.Push(&m.high, heap.Pop(&m.low))
+	} else if len(m.high.items) > len(m.low.items) {
+		heap.Push(&m.low, heap.Pop(&m.high))
+	}
+}
+
+// clear removes all items.
+func (m *medianSet[K, V]) clear() {
+	m.low.clear()
+	m.high.clear()
+}
+
+func (h *medianHeap[K, V]) clear() {
+	for i := range h.items {
+		h.items[i] = nil
+	}
+	h.items = h.items[:0]
+}
+
+func (h *medianHeap[K, V]) Len() int {
+	return len(h.items)
+}
+
+func (h *medianHeap[K, V]) Less(i, j int) bool {
+	if h.high {
+		return h.items[i].value < h.items[j].value
+	}
+	return h.items[i].value > h.items[j].value
+}
+
+func (h *medianHeap[K, V]) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].medianIndex = i
+	h.items[j].medianIndex = j
+}
+
+func (h *medianHeap[K, V]) Push(x interface{}) {
+	it := x.(*item[K, V])
+	it.medianIndex = len(h.items)
+	it.medianHigh = h.high
+	h.items = append(h.items, it)
+}
+
+func (h *medianHeap[K, V]) Pop() interface{} {
+	n := len(h.items)
+	it := h.items[n-1]
+	h.items[n-1] = nil // avoid memory leak
+	h.items = h.items[:n-1]
+	return it
+}
@@ -0,0 +1,70 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestCapQueue_Median(t *testing.T) {
+	q := New[string, int](testCapacity)
+	_, ok := q.Median()
+	assert.False(t, ok)
+
+	for i, v := range []int{5, 1, 4, 2} {
+		q.Add(fmt.Sprint(i), v)
+	}
+	median, ok := q.Median()
+	assert.True(t, ok)
+	assert.Equal(t, 2, median)
+	q.Add("4", 3)
+	median, _ = q.Median()
+	assert.Equal(t, 3, median)
+}
+
+func TestWithMedian(t *testing.T) {
+	const capacity = 100
+	q := New[string, int](capacity, WithMedian[string, int](), WithLazyDeletion[string, int]())
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 20*capacity; i++ {
+		key := fmt.Sprint(r.Intn(2 * capacity))
+		switch r.Intn(4) {
+		case 0:
+			q.Delete(key)
+		case 1:
+			q.Update(key, r.Intn(capacity))
+		default:
+			q.Add(key, r.Intn(capacity))
+		}
+		median, ok := q.Median()
+		require.Equal(t, q.Len() > 0, ok)
+		if ok {
+			require.Equal(t, medianValue(q.Entries()), median)
+		}
+	}
+
+	c := q.Clone()
+	q.Clear()
+	_, ok := q.Median()
+	assert.False(t, ok)
+	for c.Len() > 0 {
+		median, _ := c.Median()
+		require.Equal(t, medianValue(c.Entries()), median)
+		c.PopMax()
+	}
+}
+
+// medianValue returns the lower median of the values of the given entries.
+func medianValue(entries []Entry[string, int]) int {
+	values := make([]int, len(entries))
+	for i, e := range entries {
+		values[i] = e.Value
+	}
+	sort.Ints(values)
+	return values[(len(values)-1)/2]
+}
@@ -0,0 +1,120 @@
+package capqueue
+
+import (
+	"time"
+)
+
+// MergeMap applies all key-value pairs of m to the queue with upsert semantics:
+// Keys already contained in the queue get their value replaced and become the newest entries, afterwards all other
+// pairs are added like with Add, evicting the oldest entries when the queue is full. The order in which the new
+// pairs are added is unspecified. With WithAdmission, every pair is recorded and new pairs are only added to a full
+// queue, if the policy admits them.
+// Expired entries are removed first, so that they do not cause evictions. When m is large relative to the queue, the
+// heap is rebuilt only once in O(n) instead of being fixed for every single pair. With WithEvictionBatch, a full
+// queue evicts a batch of entries at once like Add.
+// This will panic if a key is longer than the maximum key length of a preallocated queue.
+func (h *CapQueue[K, V]) MergeMap(m map[K]V) {
+	if h.maxKeyLen > 0 {
+		for key := range m {
+			if keyLen(h.normalize(key)) > h.maxKeyLen {
+				panic(ErrKeyTooLong)
+			}
+		}
+	}
+
+	if h.batchDepth > 0 {
+		// copy the map, as it might be modified before the batch ends
+		c := make(map[K]V, len(m))
+		for key, value := range m {
+			c[key] = value
+		}
+		h.pending = append(h.pending, func() { h.MergeMap(c) })
+		return
+	}
+	h.expire() // expired entries must not cause evictions
+
+	now := time.Now()
+	// the victims of EvictLowest depend on the heap ordering, which is only restored at the end of a bulk merge, the
+	// bulk merge only limits the number of entries, not their cost, and it does not consult the admission policy
+	if len(m)*4 < h.size() || h.opts.eviction == EvictLowest || h.costFn != nil || h.admission != nil {
+		// update the existing keys first, so that they do not get evicted by the new keys
+		for key, value := range m {
+			if it, ok := h.lookup(key); ok {
+				if h.admission != nil {
+					h.admission.Record(it.key)
+				}
+				h.touch(it, now)
+				h.update(it, value)
+			}
+		}
+		for key, value := range m {
+			if _, ok := h.lookup(key); !ok {
+				_ = h.add(Entry[K, V]{Key: key, Value: value, AddedAt: now})
+			}
+		}
+		return
+	}
+	h.bulkMerge(m, now)
+}
+
+// bulkMerge applies all pairs of m without maintaining the heap ordering and rebuilds the heap afterwards.
+func (h *CapQueue[K, V]) bulkMerge(m map[K]V, now time.Time) {
+	defer h.trackMax()()
+
+	n := h.size()
+	softLimitReached := n > h.softLimit()
+	added := make(map[K]V, len(m))
+	for key, value := range m {
+		key = h.normalize(key)
+		if it, ok := h.find(key); ok {
+			h.setValue(it, value)
+			h.touch(it, now)
+			continue
+		}
+		added[key] = value
+	}
+	for key, value := range added {
+		h.countAdd()
+		if h.cap > 0 && n == h.cap {
+			// evict a whole batch with WithEvictionBatch, like insert does
+			k := 1
+			if h.opts.evictBatch > 1 {
+				k = min(h.opts.evictBatch, n)
+			}
+			for ; k > 0; k-- {
+				it := h.victim()
+				h.evicted(it)
+				h.unlink(it)
+				it.index = -1 // mark as removed
+				n--
+			}
+		}
+		it := h.newItem()
+		it.set(Entry[K, V]{Key: key, Value: value, AddedAt: now})
+		it.index = len(h.heap)
+		h.heap = append(h.heap, it)
+		h.link(it)
+		n++
+	}
+	h.rebuild()
+
+	if !softLimitReached && n > h.softLimit() && h.opts.onSoftLimit != nil {
+		h.opts.onSoftLimit(n)
+	}
+}
+
+// touch makes the given item the newest entry of the queue.
+func (h *CapQueue[K, V]) touch(it *item[K, V], now time.Time) {
+	if h.batchDepth > 0 {
+		key := it.key
+		h.pending = append(h.pending, func() {
+			if it, ok := h.find(key); ok {
+				h.touch(it, now)
+			}
+		})
+		return
+	}
+	h.detach(it)
+	it.addedAt = now
+	h.attach(it)
+}
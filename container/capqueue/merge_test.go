@@ -0,0 +1,107 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestCapQueue_MergeMap(t *testing.T) {
+	for _, size := range []int{1, testCapacity} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			q := New[string, int](testCapacity)
+			for i := 1; i <= testCapacity; i++ {
+				q.Add(fmt.Sprint(i), i)
+			}
+
+			// update the first key and add size-1 new keys
+			m := map[string]int{"1": 2 * testCapacity}
+			for i := 1; i < size; i++ {
+				m[fmt.Sprint(-i)] = -i
+			}
+			q.MergeMap(m)
+
+			assert.Equal(t, testCapacity, q.Len())
+			for key, value := range m {
+				assert.Equal(t, value, q.Value(key))
+			}
+			maxKey, maxValue := q.Max()
+			assert.Equal(t, "1", maxKey)
+			assert.Equal(t, 2*testCapacity, maxValue)
+
+			// the updated key is newer than the remaining old keys
+			if firstKey, _ := q.First(); size < testCapacity {
+				assert.Equal(t, fmt.Sprint(size+1), firstKey)
+			}
+			assert.EqualValues(t, testCapacity+size-1, q.Stats().Adds)
+
+			// the heap ordering is valid
+			last := maxValue
+			for q.Len() > 0 {
+				key, value := q.Max()
+				assert.LessOrEqual(t, value, last)
+				last = value
+				q.Delete(key)
+			}
+		})
+	}
+}
+
+func TestCapQueue_MergeMapAdmission(t *testing.T) {
+	p := NewTinyLFU[string, int](testCapacity)
+	q := New[string, int](testCapacity, WithAdmission[string, int](p))
+	for i := 0; i < testCapacity; i++ {
+		// make the initial keys frequent
+		p.Record(fmt.Sprint(i))
+		q.Add(fmt.Sprint(i), i)
+	}
+
+	// the new keys are less frequent than the victims and get rejected
+	m := map[string]int{"0": 2 * testCapacity}
+	for i := 1; i < testCapacity; i++ {
+		m[fmt.Sprint(-i)] = -i
+	}
+	q.MergeMap(m)
+	assert.Equal(t, testCapacity, q.Len())
+	assert.Equal(t, 2*testCapacity, q.Value("0"))
+	assert.False(t, q.Contains("-1"))
+	assert.EqualValues(t, testCapacity-1, q.Stats().Rejections)
+
+	// all keys have been recorded
+	assert.Equal(t, 3, p.Frequency("0"))
+	assert.Equal(t, 1, p.Frequency("-1"))
+}
+
+func TestCapQueue_MergeMapTTL(t *testing.T) {
+	var evicted []string
+	q := New[string, int](4, WithEvictCallback[string, int](func(key string, _ int) { evicted = append(evicted, key) }))
+	for _, key := range []string{"a", "b", "c", "d"} {
+		q.AddWithTTL(key, 1, testTTL)
+	}
+	time.Sleep(testTTL)
+
+	q.MergeMap(map[string]int{"e": 1, "f": 2, "g": 3, "h": 4})
+	assert.Equal(t, 4, q.Len())
+	assert.Empty(t, evicted)
+	stats := q.Stats()
+	assert.Zero(t, stats.Evictions)
+	assert.EqualValues(t, 4, stats.Expirations)
+}
+
+func TestCapQueue_MergeMapEvictionBatch(t *testing.T) {
+	q := New[string, int](testCapacity, WithEvictionBatch[string, int](testCapacity/2))
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	q.MergeMap(map[string]int{"a": 1, "b": 2, "c": 3})
+
+	// the first new key evicts a batch, which leaves room for the others
+	assert.Equal(t, testCapacity-testCapacity/2+3, q.Len())
+	assert.EqualValues(t, testCapacity/2, q.Stats().Evictions)
+	for i := 1; i <= testCapacity/2; i++ {
+		assert.False(t, q.Contains(fmt.Sprint(i)))
+	}
+}
@@ -0,0 +1,325 @@
+package capqueue
+
+import (
+	"sort"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// An Option configures a CapQueue with key type K and value type V.
+// Options are generic over the types of the queue, so that an option created for different types, e.g. a sentinel
+// of the wrong value type, does not compile. As the types cannot be inferred from the arguments of all options, they
+// are usually instantiated explicitly, e.g. New[string, int](10, WithSeed[string, int](1)).
+type Option[K comparable, V ordering.Ordered] interface {
+	apply(*options[K, V])
+}
+
+// optionFunc wraps a func so it satisfies the Option interface.
+type optionFunc[K comparable, V ordering.Ordered] func(*options[K, V])
+
+func (f optionFunc[K, V]) apply(o *options[K, V]) {
+	f(o)
+}
+
+// options holds the configuration of a CapQueue.
+type options[K comparable, V ordering.Ordered] struct {
+	headroom     int
+	onSoftLimit  func(n int)
+	missingValue V
+	seed         *int64
+	evictBatch   int
+	lazyDeletion bool
+	compactAt    float64
+	onMaxChange  func()
+	normalizeKey func(K) K
+	maxHistory   int
+	bandBounds   []V
+	admission    AdmissionPolicy[K, V]
+	valueIndex   bool
+	median       bool
+	compactIndex bool
+	arity        int
+	fixupBudget  int
+	rates        bool
+	shard        ShardFunc[K]
+	overflow     *CapQueue[K, V]
+	onEvict      func(K, V)
+	less         func(a, b Entry[K, V]) bool
+	minOrder     bool
+	stableOrder  bool
+	eviction     EvictionPolicy
+}
+
+// WithCapacityHeadroom configures a soft limit of headroom entries below the capacity of the queue.
+// Whenever an addition makes the number of entries cross the soft limit, f is called with the new length.
+// This can be used to trigger an asynchronous cleanup before the queue reaches its capacity (the hard limit),
+// which is the only point where entries get evicted synchronously.
+func WithCapacityHeadroom[K comparable, V ordering.Ordered](headroom int, f func(n int)) Option[K, V] {
+	if headroom < 0 {
+		panic("negative headroom")
+	}
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.headroom = headroom
+		o.onSoftLimit = f
+	})
+}
+
+// WithZeroValueSentinel configures the value that is returned by the deprecated Value for keys not contained in the
+// queue.
+// By default, this is the zero value of the value type, which is ambiguous when it is a valid priority.
+func WithZeroValueSentinel[K comparable, V ordering.Ordered](value V) Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.missingValue = value
+	})
+}
+
+// WithSeed configures the seed of the random source used by randomized operations like Sample.
+// This makes the behavior of the queue reproducible. If no seed is provided, a random seed is chosen which
+// can be queried using Stats.
+func WithSeed[K comparable, V ordering.Ordered](seed int64) Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.seed = &seed
+	})
+}
+
+// WithEvictionBatch configures the queue to evict the k oldest entries at once when a new entry is added to a full
+// queue. The heap is then rebuilt only once per batch instead of being fixed for every single eviction, which
+// reduces the latency jitter of Add under sustained overload.
+func WithEvictionBatch[K comparable, V ordering.Ordered](k int) Option[K, V] {
+	if k < 1 {
+		panic("non-positive eviction batch")
+	}
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.evictBatch = k
+	})
+}
+
+// WithLazyDeletion configures the queue to only mark deleted entries as tombstones instead of removing them from the
+// heap right away. Tombstones are removed, once they reach the top of the heap or when the queue is compacted.
+// The queue is compacted automatically, once more than half of the heap are tombstones, see WithCompactionThreshold.
+// This avoids the O(log n) heap removal for entries that are deleted before they ever become the maximum.
+func WithLazyDeletion[K comparable, V ordering.Ordered]() Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.lazyDeletion = true
+	})
+}
+
+// WithCompactionThreshold configures the fraction of tombstones in the heap of a queue using WithLazyDeletion, above
+// which the queue is compacted automatically by the deletion creating the tombstone. Compacting takes O(n) time, so
+// a lower fraction bounds the memory held by tombstones more tightly at the cost of more frequent compactions. The
+// default fraction is 0.5, a fraction of 1 disables the automatic compaction.
+// This will panic if fraction is not in (0, 1].
+func WithCompactionThreshold[K comparable, V ordering.Ordered](fraction float64) Option[K, V] {
+	if !(fraction > 0 && fraction <= 1) {
+		panic("compaction threshold not in (0, 1]")
+	}
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.compactAt = fraction
+	})
+}
+
+// WithMaxChangeCallback configures a callback that is called whenever the entry with the highest value changes,
+// i.e. after a new maximum has been added or the previous maximum has been removed or modified.
+// The callback is invoked synchronously after the modification and may query the queue.
+func WithMaxChangeCallback[K comparable, V ordering.Ordered](f func()) Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.onMaxChange = f
+	})
+}
+
+// WithKeyNormalizer configures a function that is applied to every key passed to the queue, e.g. to lowercase or
+// trim keys. Keys that are normalized to the same value refer to the same entry, and all keys returned by the queue
+// are normalized.
+func WithKeyNormalizer[K comparable, V ordering.Ordered](f func(key K) K) Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.normalizeKey = f
+	})
+}
+
+// WithMaxHistory configures the queue to keep a history of the last n maxima, which can be queried using
+// MaxHistory.
+func WithMaxHistory[K comparable, V ordering.Ordered](n int) Option[K, V] {
+	if n < 1 {
+		panic("non-positive history size")
+	}
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.maxHistory = n
+	})
+}
+
+// WithPriorityBands partitions the values into priority bands separated by the given ascending bounds, i.e. n
+// bounds define n+1 bands where band i contains all values v with bounds[i-1] <= v < bounds[i].
+// The bands are used by PopFair to prevent starvation of entries in lower bands.
+func WithPriorityBands[K comparable, V ordering.Ordered](bounds ...V) Option[K, V] {
+	if len(bounds) == 0 || !sort.SliceIsSorted(bounds, func(i, j int) bool { return bounds[i] < bounds[j] }) {
+		panic("invalid band bounds")
+	}
+	bounds = append([]V(nil), bounds...)
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.bandBounds = bounds
+	})
+}
+
+// WithAdmission configures a policy that decides whether a new entry is added to a full queue. If the policy rejects
+// the entry, it is dropped and the oldest entry is kept. This prevents the queue from being churned by entries that
+// are less valuable than the ones they would evict, see TinyLFU.
+func WithAdmission[K comparable, V ordering.Ordered](policy AdmissionPolicy[K, V]) Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.admission = policy
+	})
+}
+
+// WithOverflowTo configures the queue to add every evicted entry to the other queue instead of dropping it, which
+// allows to build a hierarchy of queues with increasing retention, e.g. a small hot queue in front of a larger warm
+// queue. The entries keep their value, tiebreak and time of addition, and they are subject to the options of the other
+// queue, so they can in turn be evicted or cascaded further. Deleted and removed entries are not cascaded.
+// The other queue must not be accessed concurrently and must not cascade back into the queue.
+func WithOverflowTo[K comparable, V ordering.Ordered](other *CapQueue[K, V]) Option[K, V] {
+	if other == nil {
+		panic("nil overflow queue")
+	}
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.overflow = other
+	})
+}
+
+// WithEvictCallback configures a callback that is called for every entry that gets evicted to make room for new
+// entries or because the capacity was reduced, e.g. to record or recycle the evicted entries. It is not called for
+// entries that are deleted, removed or replaced by an addition with the same key.
+// The callback is invoked synchronously during the modification and must not access the queue.
+func WithEvictCallback[K comparable, V ordering.Ordered](f func(key K, value V)) Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.onEvict = f
+	})
+}
+
+// WithLess configures the ordering of the entries, where less reports whether a has a lower priority than b, e.g. to
+// order by value and then by the time of addition. By default, the entries are ordered by (value, tiebreak). The
+// ordering is used by the heap and thus by Max, Min, PopMax, PopFair and Entries, but the priority bands of
+// WithPriorityBands and the ranges of ValuesBetween still refer to the values. Group and Selector compare the maxima
+// of different queues by their values.
+// The ordering must be a strict weak ordering that only depends on the fields of the entries and does not change
+// while they are contained in the queue.
+func WithLess[K comparable, V ordering.Ordered](less func(a, b Entry[K, V]) bool) Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.less = less
+	})
+}
+
+// WithMinOrder reverses the ordering of the entries, so that Max and PopMax return the entry with the lowest value,
+// e.g. when the priority is a cost rather than a score. Entries with the same value are ordered by ascending tiebreak.
+// When combined with WithLess, the custom ordering is reversed. As with WithLess, Group and Selector still compare
+// the maxima of different queues by their values.
+func WithMinOrder[K comparable, V ordering.Ordered]() Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.minOrder = true
+	})
+}
+
+// WithStableOrder configures the queue to break ties between entries of the same priority by their insertion order,
+// so that the oldest of them is returned first by Max, PopMax and Entries. This makes the selection deterministic
+// and reproducible across runs, as it does not depend on the layout of the heap anymore. Entries whose key is added
+// again become the newest entry, while Update keeps their position.
+func WithStableOrder[K comparable, V ordering.Ordered]() Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.stableOrder = true
+	})
+}
+
+// WithEviction configures the policy that determines which entry is evicted when a new entry is added to a full
+// queue. By default, the oldest entry is evicted.
+func WithEviction[K comparable, V ordering.Ordered](policy EvictionPolicy) Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.eviction = policy
+	})
+}
+
+// WithValueIndex configures the queue to maintain an ordered index over the values of its entries in addition to the
+// heap. This allows ValuesBetween to answer range queries efficiently, at the cost of additional memory and
+// O(log n) work for every modification.
+func WithValueIndex[K comparable, V ordering.Ordered]() Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.valueIndex = true
+	})
+}
+
+// WithMedian configures the queue to maintain the median of the values of its entries in two heaps holding the
+// lower and the upper half of the values. This allows Median to return the median in O(1) time, at the cost of
+// additional memory and O(log n) work for every modification.
+func WithMedian[K comparable, V ordering.Ordered]() Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.median = true
+	})
+}
+
+// WithCompactIndex configures the queue to index its keys using a compact open-addressing hash table instead of a
+// map. The table does not duplicate the keys stored in the entries and is sized for the capacity of the queue up front,
+// which reduces the memory usage of large queues and the latency of lookups. Only keys of a string type are supported.
+// This will panic in New if the keys are not strings.
+func WithCompactIndex[K comparable, V ordering.Ordered]() Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.compactIndex = true
+	})
+}
+
+// WithArity configures the number of children of each node of the heap of the queue, which is 2 by default.
+// A higher arity makes the heap shallower, so that additions perform fewer comparisons and the items compared while
+// moving an item towards the leaves are adjacent in memory, which improves the cache behavior of large queues at the
+// cost of more comparisons per level. An arity of 4 is a good choice for queues with more than 100k entries.
+// This will panic if d is less than 2.
+func WithArity[K comparable, V ordering.Ordered](d int) Option[K, V] {
+	if d < 2 {
+		panic("arity less than 2")
+	}
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.arity = d
+	})
+}
+
+// WithFixupBudget configures the queue to perform at most budget heap swaps per modification, which bounds the
+// worst-case latency of Add, Remove and updates independently of the queue size. Fix-ups that exceed the budget are
+// deferred to subsequent modifications.
+//
+// Max and the other accessors of the maximum remain exact, but need O(d) time for d entries with a deferred fix-up,
+// see Pending. The order of all other entries within the heap is only restored eventually, or immediately by
+// calling Compact. A budget of at least twice the height of the heap keeps the number of deferred entries small.
+// This option cannot be combined with WithLazyDeletion.
+func WithFixupBudget[K comparable, V ordering.Ordered](budget int) Option[K, V] {
+	if budget < 1 {
+		panic("non-positive fix-up budget")
+	}
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.fixupBudget = budget
+	})
+}
+
+// WithRates configures the queue to track the rates of additions, evictions and the hit ratio of Get and Value over
+// sliding windows of one second, ten seconds and one minute, which are reported by Stats. This makes capacity
+// pressure visible as a rate instead of only as cumulative counters.
+func WithRates[K comparable, V ordering.Ordered]() Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.rates = true
+	})
+}
+
+// WithShardFunc configures the function that assigns keys to the shards of a Sharded queue, e.g. to isolate hot keys
+// in a dedicated shard. The function receives the normalized key and must be deterministic. This option is ignored
+// by all other queues.
+func WithShardFunc[K comparable, V ordering.Ordered](f ShardFunc[K]) Option[K, V] {
+	return optionFunc[K, V](func(o *options[K, V]) {
+		o.shard = f
+	})
+}
+
+// lessOption returns the ordering configured by WithLess and WithMinOrder or nil for the default ordering.
+func lessOption[K comparable, V ordering.Ordered](o *options[K, V]) func(a, b Entry[K, V]) bool {
+	less := o.less
+	if o.minOrder {
+		if less == nil {
+			less = lessEntry[K, V]
+		}
+		reversed := less
+		less = func(a, b Entry[K, V]) bool { return reversed(b, a) }
+	}
+	return less
+}
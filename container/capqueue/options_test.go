@@ -0,0 +1,278 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestWithCapacityHeadroom(t *testing.T) {
+	const headroom = 3
+
+	var calls []int
+	q := New[string, int](testCapacity, WithCapacityHeadroom[string, int](headroom, func(n int) { calls = append(calls, n) }))
+	for i := 1; i <= 2*testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	assert.Equal(t, []int{testCapacity - headroom + 1}, calls)
+
+	// dropping below the soft limit re-arms the callback
+	for i := testCapacity + 1; i <= testCapacity+headroom; i++ {
+		q.Delete(fmt.Sprint(i))
+	}
+	q.Add("new", 0)
+	assert.Equal(t, []int{testCapacity - headroom + 1, testCapacity - headroom + 1}, calls)
+}
+
+func TestWithCapacityHeadroomInvalid(t *testing.T) {
+	assert.Panics(t, func() { WithCapacityHeadroom[string, int](-1, nil) })
+}
+
+func TestWithZeroValueSentinel(t *testing.T) {
+	q := New[string, int](testCapacity, WithZeroValueSentinel[string, int](-1))
+	q.Add("0", 0)
+	assert.Equal(t, 0, q.Value("0"))
+	assert.Equal(t, -1, q.Value("not contained"))
+}
+
+func TestWithZeroValueSentinelType(t *testing.T) {
+	// the untyped constant is converted to the value type of the queue
+	q := New[string, float64](testCapacity, WithZeroValueSentinel[string, float64](-1))
+	assert.Equal(t, -1.0, q.Value("1"))
+}
+
+func TestWithEvictionBatch(t *testing.T) {
+	const batch = 3
+
+	q := New[string, int](testCapacity, WithEvictionBatch[string, int](batch))
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	q.Add("new", 0)
+	assert.Equal(t, testCapacity-batch+1, q.Len())
+	assert.EqualValues(t, batch, q.Stats().Evictions)
+	for i := 1; i <= batch; i++ {
+		assert.Zero(t, q.Value(fmt.Sprint(i)))
+	}
+
+	firstKey, _ := q.First()
+	assert.Equal(t, fmt.Sprint(batch+1), firstKey)
+	_, max := q.Max()
+	assert.Equal(t, testCapacity, max)
+
+	assert.Panics(t, func() { WithEvictionBatch[string, int](0) })
+}
+
+func TestWithMaxChangeCallback(t *testing.T) {
+	var calls int
+	q := New[string, int](testCapacity, WithMaxChangeCallback[string, int](func() { calls++ }))
+	q.Add("1", 1)
+	q.Add("2", 2)
+	q.Add("0", 0)
+	assert.Equal(t, 2, calls)
+	q.Delete("0")
+	assert.Equal(t, 2, calls)
+	q.Delete("2")
+	assert.Equal(t, 3, calls)
+}
+
+func TestWithKeyNormalizer(t *testing.T) {
+	q := New[string, int](testCapacity, WithKeyNormalizer[string, int](strings.ToLower))
+	q.Add("Key", 1)
+	assert.Equal(t, 1, q.Value("KEY"))
+
+	maxKey, _ := q.Max()
+	assert.Equal(t, "key", maxKey)
+
+	value, ok := q.Remove("kEy")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	assert.Zero(t, q.Len())
+}
+
+func TestWithAdmission(t *testing.T) {
+	p := NewTinyLFU[string, int](testCapacity)
+	q := New[string, int](testCapacity, WithAdmission[string, int](p))
+	for i := 0; i < testCapacity; i++ {
+		// make the initial keys frequent
+		p.Record(fmt.Sprint(i))
+		q.Add(fmt.Sprint(i), i)
+	}
+	q.Add("new", 0)
+	assert.Equal(t, 0, q.Value("new"))
+	key, _ := q.First()
+	assert.Equal(t, "0", key)
+
+	// once the new key is more frequent than the victim, it is admitted
+	for i := 0; i < 2; i++ {
+		q.Add("new", 1)
+	}
+	assert.Equal(t, 1, q.Value("new"))
+	key, _ = q.First()
+	assert.Equal(t, "1", key)
+	assert.EqualValues(t, 2, q.Stats().Rejections)
+}
+
+func TestWithOverflowTo(t *testing.T) {
+	cold := New[string, int](2 * testCapacity)
+	hot := New[string, int](testCapacity, WithOverflowTo[string, int](cold))
+	for i := 0; i < 3*testCapacity; i++ {
+		hot.Add(fmt.Sprint(i), i)
+	}
+	assert.Equal(t, testCapacity, hot.Len())
+	assert.Equal(t, 2*testCapacity, cold.Len())
+	key, value := cold.First()
+	assert.Equal(t, "0", key)
+	assert.Equal(t, 0, value)
+	key, value = cold.Max()
+	assert.Equal(t, fmt.Sprint(2*testCapacity-1), key)
+	assert.Equal(t, 2*testCapacity-1, value)
+
+	// deleted entries are not cascaded
+	hot.Delete(fmt.Sprint(3*testCapacity - 1))
+	assert.Equal(t, 2*testCapacity, cold.Len())
+	assert.Equal(t, 0, cold.Value(fmt.Sprint(3*testCapacity-1)))
+
+	// evictions in batches are cascaded as well
+	batched := New[string, int](testCapacity)
+	q := New[string, int](testCapacity, WithEvictionBatch[string, int](testCapacity/2), WithOverflowTo[string, int](batched))
+	for i := 0; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	assert.Equal(t, testCapacity/2, batched.Len())
+
+	assert.Panics(t, func() { WithOverflowTo[string, int](nil) })
+}
+
+func TestWithEvictCallback(t *testing.T) {
+	var evicted []Entry[string, int]
+	q := New[string, int](testCapacity, WithEvictCallback[string, int](func(key string, value int) {
+		evicted = append(evicted, Entry[string, int]{Key: key, Value: value})
+	}))
+	for i := 0; i < testCapacity+2; i++ {
+		q.Add(fmt.Sprint(i), -i)
+	}
+	assert.Equal(t, []Entry[string, int]{{Key: "0", Value: 0}, {Key: "1", Value: -1}}, evicted)
+
+	// deletions and replacements are not reported
+	evicted = nil
+	q.Delete("2")
+	q.Add("3", 3)
+	q.Update("4", 4)
+	assert.Empty(t, evicted)
+
+	q.SetCap(testCapacity - 2)
+	assert.Equal(t, []Entry[string, int]{{Key: "4", Value: 4}}, evicted)
+}
+
+func TestWithLess(t *testing.T) {
+	// order by ascending value and then by ascending key
+	less := WithLess[string, int](func(a, b Entry[string, int]) bool {
+		if a.Value != b.Value {
+			return a.Value > b.Value
+		}
+		return a.Key > b.Key
+	})
+	for name, opts := range map[string][]Option[string, int]{
+		"default": {less},
+		"bands":   {less, WithPriorityBands[string, int](testCapacity / 2)},
+		"index":   {less, WithValueIndex[string, int]()},
+		"lazy":    {less, WithLazyDeletion[string, int]()},
+		"fixup":   {less, WithFixupBudget[string, int](1)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			q := New[string, int](testCapacity, opts...)
+			for i := 0; i < testCapacity; i++ {
+				q.Add(fmt.Sprint(i), (i*3)%(testCapacity/2))
+			}
+			q.Delete("0")
+
+			key, value := q.Max()
+			assert.Equal(t, "5", key)
+			assert.Equal(t, 0, value)
+			key, value = q.Min()
+			assert.Equal(t, "8", key)
+			assert.Equal(t, 4, value)
+
+			entries := q.Entries()
+			assert.Len(t, entries, testCapacity-1)
+			for i := 1; i < len(entries); i++ {
+				assert.LessOrEqual(t, entries[i-1].Value, entries[i].Value)
+			}
+			for _, e := range entries {
+				key, value := q.PopMax()
+				assert.Equal(t, e.Key, key)
+				assert.Equal(t, e.Value, value)
+			}
+		})
+	}
+}
+
+func TestWithMinOrder(t *testing.T) {
+	q := New[string, int](testCapacity, WithMinOrder[string, int]())
+	for i := 1; i <= testCapacity; i++ {
+		q.AddWithTiebreak(fmt.Sprint(i), i%(testCapacity/2), i)
+	}
+
+	key, value := q.Max()
+	assert.Equal(t, "5", key)
+	assert.Equal(t, 0, value)
+	key, value = q.Min()
+	assert.Equal(t, "9", key)
+	assert.Equal(t, 4, value)
+	for i := 0; i < testCapacity; i++ {
+		_, value := q.PopMax()
+		assert.Equal(t, i/2, value)
+	}
+
+	// the custom ordering is reversed
+	q = New[string, int](testCapacity, WithMinOrder[string, int](), WithLess[string, int](func(a, b Entry[string, int]) bool {
+		return a.Key < b.Key
+	}))
+	q.Add("b", 1)
+	q.Add("a", 2)
+	q.Add("c", 0)
+	key, _ = q.Max()
+	assert.Equal(t, "a", key)
+}
+
+func TestWithStableOrder(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"default": nil,
+		"bands":   {WithPriorityBands[string, int](1)},
+		"index":   {WithValueIndex[string, int]()},
+		"lazy":    {WithLazyDeletion[string, int]()},
+		"fixup":   {WithFixupBudget[string, int](1)},
+		"less": {WithLess[string, int](func(a, b Entry[string, int]) bool {
+			return a.Value < b.Value
+		})},
+	} {
+		t.Run(name, func(t *testing.T) {
+			q := New[string, int](2*testCapacity, append(opts, WithStableOrder[string, int]())...)
+			for i := 0; i < 2*testCapacity; i++ {
+				q.Add(fmt.Sprint(i), i%2)
+			}
+			q.Delete("1")
+			q.Add("3", 1) // becomes the newest entry
+			q.Update("5", 1)
+
+			key, _ := q.Min()
+			assert.Equal(t, "18", key)
+
+			var keys []string
+			for _, e := range q.Entries() {
+				keys = append(keys, e.Key)
+			}
+			assert.Equal(t, []string{"5", "7", "9", "11", "13", "15", "17", "19", "3"}, keys[:testCapacity-1])
+			assert.Equal(t, []string{"0", "2", "4", "6"}, keys[testCapacity-1:testCapacity+3])
+
+			for _, key := range keys {
+				k, _ := q.PopMax()
+				assert.Equal(t, key, k)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package capqueue
+
+import (
+	"time"
+)
+
+// AddWithPayload adds a new key-value pair to the queue like Add, which carries the given payload.
+// The queue owns the payload, so that no separate map from keys to the actual objects needs to be maintained; it can
+// be retrieved using Payload, MaxEntry, PopMaxEntry or any method returning entries. Adding the key again using Add
+// or any other method without a payload replaces the payload with nil, while Update and Touch keep it.
+// This will panic if the key is longer than the maximum key length of a preallocated queue.
+func (h *CapQueue[K, V]) AddWithPayload(key K, value V, payload interface{}) {
+	if err := h.add(Entry[K, V]{Key: key, Value: value, AddedAt: time.Now(), Payload: payload}); err != nil {
+		panic(err)
+	}
+}
+
+// Payload returns the payload of the given key.
+// The second return value is false, when no element with the given key exists.
+func (h *CapQueue[K, V]) Payload(key K) (interface{}, bool) {
+	h.expire()
+	it, ok := h.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	h.accessed(it)
+	return it.payload, true
+}
+
+// MaxEntry returns the entry with the highest value including its payload.
+// The second return value is false, if the queue is empty.
+func (h *CapQueue[K, V]) MaxEntry() (Entry[K, V], bool) {
+	h.expire()
+	if h.size() == 0 {
+		return Entry[K, V]{}, false
+	}
+	it := h.top()
+	h.accessed(it)
+	return it.entry(), true
+}
+
+// PopMaxEntry removes and returns the entry with the highest value including its payload.
+// The second return value is false, if the queue is empty.
+func (h *CapQueue[K, V]) PopMaxEntry() (Entry[K, V], bool) {
+	h.expire()
+	if h.size() == 0 {
+		return Entry[K, V]{}, false
+	}
+	it := h.top()
+	e := it.entry()
+	h.remove(it)
+	return e, true
+}
@@ -0,0 +1,67 @@
+package capqueue_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+type job struct {
+	name string
+}
+
+func TestCapQueue_AddWithPayload(t *testing.T) {
+	q := New[string, int](2)
+	q.AddWithPayload("a", 1, &job{"a"})
+	q.AddWithPayload("b", 2, &job{"b"})
+
+	p, ok := q.Payload("a")
+	require.True(t, ok)
+	assert.Equal(t, &job{"a"}, p)
+
+	e, ok := q.MaxEntry()
+	require.True(t, ok)
+	assert.Equal(t, "b", e.Key)
+	assert.Equal(t, &job{"b"}, e.Payload)
+
+	assert.True(t, q.Update("a", 3))
+	p, _ = q.Payload("a")
+	assert.Equal(t, &job{"a"}, p)
+
+	// evicting an entry drops its payload
+	q.AddWithPayload("c", 0, &job{"c"})
+	_, ok = q.Payload("a")
+	assert.False(t, ok)
+
+	e, ok = q.PopMaxEntry()
+	require.True(t, ok)
+	assert.Equal(t, Entry[string, int]{Key: "b", Value: 2, AddedAt: e.AddedAt, Payload: &job{"b"}}, e)
+	assert.Equal(t, 1, q.Len())
+
+	// adding the key again without a payload replaces it
+	q.Add("c", 1)
+	p, ok = q.Payload("c")
+	assert.True(t, ok)
+	assert.Nil(t, p)
+
+	q.Clear()
+	_, ok = q.MaxEntry()
+	assert.False(t, ok)
+	_, ok = q.PopMaxEntry()
+	assert.False(t, ok)
+}
+
+func TestSync_AddWithPayload(t *testing.T) {
+	q := NewSync[string, int](testCapacity)
+	q.AddWithPayload("a", 1, "payload")
+
+	p, ok := q.Payload("a")
+	assert.True(t, ok)
+	assert.Equal(t, "payload", p)
+	e, ok := q.PopMaxEntry()
+	assert.True(t, ok)
+	assert.Equal(t, "payload", e.Payload)
+	assert.Zero(t, q.Len())
+}
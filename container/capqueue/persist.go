@@ -0,0 +1,61 @@
+package capqueue
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// snapshotVersion is the version of the persistence format written by CapQueue.Snapshot.
+// Version 1 did not include the expiry times of entries, its data can still be restored.
+const snapshotVersion byte = 2
+
+// crcTable is used to compute the checksums of persisted snapshots.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Snapshot returns a self-contained binary representation of the capacity and the entries of the queue, which is
+// intended for crash-safe persistence and can be restored using Restore.
+// The data starts with a format version byte followed by the CBOR encoding of a Snapshot, see WriteTo, and ends with
+// a CRC-32C checksum over all preceding bytes, so that truncated or corrupted data is detected.
+// Entries added using AddWithTTL keep their expiry time.
+func (h *CapQueue[K, V]) Snapshot() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(snapshotVersion)
+	if _, err := h.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	var sum [crc32.Size]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.Checksum(buf.Bytes(), crcTable))
+	buf.Write(sum[:])
+	return buf.Bytes(), nil
+}
+
+// Restore creates a new CapQueue instance from data returned by CapQueue.Snapshot like NewFromSnapshot.
+// It returns an error wrapping ErrInvalidSnapshot, if the checksum does not match, the version is not supported or
+// the data is malformed.
+func Restore[K comparable, V ordering.Ordered](data []byte, opts ...Option[K, V]) (*CapQueue[K, V], error) {
+	if len(data) < 1+crc32.Size {
+		return nil, fmt.Errorf("%w: too short", ErrInvalidSnapshot)
+	}
+	payload, sum := data[:len(data)-crc32.Size], data[len(data)-crc32.Size:]
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(sum) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidSnapshot)
+	}
+	if payload[0] < 1 || payload[0] > snapshotVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, payload[0])
+	}
+
+	d := newCBORDecoder(bytes.NewReader(payload[1:]))
+	s, err := decodeSnapshot[K, V](d)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.readByte(); err != io.EOF {
+		return nil, fmt.Errorf("%w: trailing data", ErrInvalidSnapshot)
+	}
+	return NewFromSnapshot(s, opts...)
+}
@@ -0,0 +1,92 @@
+package capqueue_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestRestore(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity+1; i++ {
+		q.AddWithTiebreak(fmt.Sprint(i), i%3, i)
+	}
+
+	data, err := q.Snapshot()
+	require.NoError(t, err)
+	restored, err := Restore[string, int](data, WithStableOrder[string, int]())
+	require.NoError(t, err)
+	assert.Equal(t, q.Cap(), restored.Cap())
+	assertEntriesEqual(t, q.OldestK(testCapacity), restored.OldestK(testCapacity))
+
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"truncated": data[:len(data)-1],
+		"corrupted": append([]byte{data[0], data[1] ^ 1}, data[2:]...),
+		"version":   withChecksum(append([]byte{99}, data[1:len(data)-4]...)),
+		"trailing":  withChecksum(append(append([]byte{}, data[:len(data)-4]...), 0)),
+		"malformed": withChecksum([]byte{1, 0x80}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Restore[string, int](data)
+			assert.True(t, errors.Is(err, ErrInvalidSnapshot), err)
+		})
+	}
+}
+
+func TestRestoreTTL(t *testing.T) {
+	q := New[string, int](testCapacity)
+	q.AddWithTTL("a", 1, time.Hour)
+	q.Add("b", 2)
+	q.AddWithTTL("c", 3, testTTL)
+
+	data, err := q.Snapshot()
+	require.NoError(t, err)
+	restored, err := Restore[string, int](data)
+	require.NoError(t, err)
+	assertEntriesEqual(t, q.Export().Entries, restored.Export().Entries)
+
+	// the restored entries still expire
+	time.Sleep(testTTL)
+	assert.Equal(t, 1, restored.Expire())
+	assert.Equal(t, []string{"a", "b"}, restored.Keys())
+}
+
+func TestRestoreVersion1(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	data, err := q.Snapshot()
+	require.NoError(t, err)
+
+	// entries without an expiry time are encoded like in version 1
+	v1 := withChecksum(append([]byte{1}, data[1:len(data)-crc32.Size]...))
+	restored, err := Restore[string, int](v1)
+	require.NoError(t, err)
+	assertEntriesEqual(t, q.Export().Entries, restored.Export().Entries)
+}
+
+func TestSync_Snapshot(t *testing.T) {
+	q := NewSync[string, int](testCapacity)
+	q.Add("a", 1)
+	data, err := q.Snapshot()
+	require.NoError(t, err)
+	restored, err := Restore[string, int](data)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, restored.Keys())
+}
+
+// withChecksum appends a valid checksum to data.
+func withChecksum(data []byte) []byte {
+	var sum [crc32.Size]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+	return append(data, sum[:]...)
+}
@@ -0,0 +1,62 @@
+package capqueue
+
+import (
+	"reflect"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// NewPreallocated creates a new CapQueue instance for latency-critical applications.
+// All memory required by the queue is allocated up front, so that no subsequent operation of the queue allocates
+// memory or causes garbage collection pauses. As keys are retained by the queue, their length is limited to
+// maxKeyLen bytes to bound the memory held by the queue; adding longer keys fails with ErrKeyTooLong. Keys of other
+// types than strings have a fixed size and are not limited.
+// Options that install callbacks may cause allocations in the callbacks themselves.
+// This will panic if cap is not positive, as an unbounded queue cannot be preallocated.
+func NewPreallocated[K comparable, V ordering.Ordered](cap int, maxKeyLen int, opts ...Option[K, V]) *CapQueue[K, V] {
+	if cap <= 0 {
+		panic("non-positive capacity")
+	}
+	if maxKeyLen <= 0 {
+		panic("non-positive key length")
+	}
+	h := New[K, V](cap, opts...) // allocates the items up front
+	h.maxKeyLen = maxKeyLen
+	return h
+}
+
+// preallocate allocates n unused items in a single block.
+func (h *CapQueue[K, V]) preallocate(n int) {
+	items := make([]item[K, V], n)
+	for i := range items {
+		h.release(&items[i])
+	}
+}
+
+// newItem returns an unused item.
+func (h *CapQueue[K, V]) newItem() *item[K, V] {
+	if h.free == nil {
+		return &item[K, V]{}
+	}
+	it := h.free
+	h.free = it.next
+	it.next = nil
+	return it
+}
+
+// release marks the given item as unused, so that it is reused by newItem.
+func (h *CapQueue[K, V]) release(it *item[K, V]) {
+	*it = item[K, V]{next: h.free}
+	h.free = it
+}
+
+// keyLen returns the length of a string key in bytes or 0 for keys of other types.
+func keyLen[K comparable](key K) int {
+	if s, ok := interface{}(key).(string); ok {
+		return len(s)
+	}
+	if v := reflect.ValueOf(key); v.Kind() == reflect.String {
+		return v.Len()
+	}
+	return 0
+}
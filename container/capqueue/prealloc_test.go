@@ -0,0 +1,80 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestNewPreallocated(t *testing.T) {
+	q := NewPreallocated[string, int](testCapacity, 2)
+	for i := 1; i <= testCapacity+1; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	assert.Equal(t, testCapacity, q.Len())
+	for i := 2; i <= testCapacity+1; i++ {
+		assert.True(t, q.Delete(fmt.Sprint(i)))
+	}
+	assert.Zero(t, q.Len())
+
+	assert.Panics(t, func() { NewPreallocated[string, int](testCapacity, 0) })
+	assert.Panics(t, func() { NewPreallocated[string, int](0, 2) })
+	assert.Panics(t, func() { q.SetCap(0) })
+}
+
+func TestNewPreallocatedKeyTooLong(t *testing.T) {
+	q := NewPreallocated[string, int](testCapacity, 2)
+	assert.NoError(t, q.TryAdd("ab", 1))
+	assert.Equal(t, ErrKeyTooLong, q.TryAdd("abc", 1))
+	assert.PanicsWithValue(t, ErrKeyTooLong, func() { q.Add("abc", 1) })
+	assert.Equal(t, 1, q.Len())
+}
+
+func TestNewPreallocatedKeyTypes(t *testing.T) {
+	type name string
+	q := NewPreallocated[name, int](testCapacity, 2)
+	assert.Equal(t, ErrKeyTooLong, q.TryAdd("abc", 1))
+
+	// keys that are not strings are not limited
+	p := NewPreallocated[[32]byte, int](testCapacity, 2)
+	assert.NoError(t, p.TryAdd([32]byte{1}, 1))
+}
+
+func TestNewPreallocatedAllocs(t *testing.T) {
+	q := NewPreallocated[string, int](testCapacity, 2)
+	keys := make([]string, 2*testCapacity)
+	for i := range keys {
+		keys[i] = fmt.Sprint(i)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		for i, key := range keys {
+			q.Add(key, i)
+			_, _ = q.Max()
+			_ = q.Value(key)
+		}
+		for _, key := range keys {
+			q.Delete(key)
+		}
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestNewPreallocatedSetCap(t *testing.T) {
+	q := NewPreallocated[string, int](testCapacity, 2)
+	q.SetCap(2 * testCapacity)
+	keys := make([]string, 4*testCapacity)
+	for i := range keys {
+		keys[i] = fmt.Sprint(i)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		for i, key := range keys {
+			q.Add(key, i)
+		}
+	})
+	assert.Zero(t, allocs)
+	assert.Equal(t, 2*testCapacity, q.Len())
+}
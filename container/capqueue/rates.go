@@ -0,0 +1,88 @@
+package capqueue
+
+import (
+	"time"
+
+	"github.com/wollac/pkg/container/slidingcounter"
+)
+
+// windows of the reported rates
+const (
+	rateResolution = 100 * time.Millisecond
+	rateWindow     = time.Minute
+)
+
+// Rates contains a windowed rate over the last second, ten seconds and minute.
+type Rates struct {
+	Second     float64
+	TenSeconds float64
+	Minute     float64
+}
+
+// rateSet counts the events needed to compute the windowed rates.
+type rateSet struct {
+	adds, evictions, hits, misses *slidingcounter.Counter
+}
+
+func newRateSet() *rateSet {
+	return &rateSet{
+		adds:      slidingcounter.New(rateWindow, rateResolution, nil),
+		evictions: slidingcounter.New(rateWindow, rateResolution, nil),
+		hits:      slidingcounter.New(rateWindow, rateResolution, nil),
+		misses:    slidingcounter.New(rateWindow, rateResolution, nil),
+	}
+}
+
+// perSecond returns the windowed rates of the events counted by c.
+func perSecond(c *slidingcounter.Counter) Rates {
+	return Rates{
+		Second:     c.Rate(time.Second),
+		TenSeconds: c.Rate(10 * time.Second),
+		Minute:     c.Rate(time.Minute),
+	}
+}
+
+// hitRatio returns the windowed ratio of hits to all lookups.
+func (r *rateSet) hitRatio() Rates {
+	ratio := func(d time.Duration) float64 {
+		hits, misses := r.hits.Sum(d), r.misses.Sum(d)
+		if hits+misses == 0 {
+			return 0
+		}
+		return float64(hits) / float64(hits+misses)
+	}
+	return Rates{
+		Second:     ratio(time.Second),
+		TenSeconds: ratio(10 * time.Second),
+		Minute:     ratio(time.Minute),
+	}
+}
+
+// countAdd counts the addition of an entry.
+func (h *CapQueue[K, V]) countAdd() {
+	h.adds++
+	if h.rates != nil {
+		h.rates.adds.Add(1)
+	}
+}
+
+// countEviction counts the eviction of an entry.
+func (h *CapQueue[K, V]) countEviction() {
+	h.evictions++
+	if h.rates != nil {
+		h.rates.evictions.Add(1)
+	}
+}
+
+// countLookup counts a lookup of a key by a public accessor.
+// This is safe for concurrent use, as Sync performs lookups while only holding the read lock.
+func (h *CapQueue[K, V]) countLookup(hit bool) {
+	if h.rates == nil {
+		return
+	}
+	if hit {
+		h.rates.hits.Add(1)
+	} else {
+		h.rates.misses.Add(1)
+	}
+}
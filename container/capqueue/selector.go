@@ -0,0 +1,155 @@
+package capqueue
+
+import (
+	"container/heap"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// Selector selects the entry with the highest value across several registered queues.
+// It maintains a heap over the maxima of all non-empty queues, which is updated automatically whenever the maximum
+// of a registered queue changes. This allows to query the global maximum in O(1) and to remove it in O(log n).
+type Selector[K comparable, V ordering.Ordered] struct {
+	heap   queueHeap[K, V]
+	queues map[string]*queueRoot[K, V]
+}
+
+// queueRoot represents a registered queue in the Selector.
+type queueRoot[K comparable, V ordering.Ordered] struct {
+	name  string
+	q     *CapQueue[K, V]
+	hook  *maxHook
+	value V   // current maximum of the queue
+	index int // index in the heap or -1 if the queue is empty
+}
+
+// queueHeap is a max-heap of the registered queues ordered by their maximum.
+type queueHeap[K comparable, V ordering.Ordered] []*queueRoot[K, V]
+
+// NewSelector creates a new Selector without any registered queues.
+func NewSelector[K comparable, V ordering.Ordered]() *Selector[K, V] {
+	return &Selector[K, V]{queues: make(map[string]*queueRoot[K, V])}
+}
+
+// Register adds the queue q with the given name to the selector.
+// If another queue is already registered under that name, it is replaced.
+func (s *Selector[K, V]) Register(name string, q *CapQueue[K, V]) {
+	s.Unregister(name)
+
+	r := &queueRoot[K, V]{name: name, q: q, index: -1}
+	r.hook = q.addMaxHook(func() { s.fix(r) })
+	s.queues[name] = r
+	s.fix(r)
+}
+
+// Unregister removes the queue with the given name from the selector.
+// It returns true, if a queue was removed or false when no queue with the given name is registered.
+func (s *Selector[K, V]) Unregister(name string) bool {
+	r, ok := s.queues[name]
+	if !ok {
+		return false
+	}
+	r.q.removeMaxHook(r.hook)
+	if r.index >= 0 {
+		heap.Remove(&s.heap, r.index)
+	}
+	delete(s.queues, name)
+	return true
+}
+
+// Queue returns the queue registered under the given name or nil if no such queue exists.
+func (s *Selector[K, V]) Queue(name string) *CapQueue[K, V] {
+	if r, ok := s.queues[name]; ok {
+		return r.q
+	}
+	return nil
+}
+
+// Len returns the number of registered queues.
+func (s *Selector[K, V]) Len() int {
+	return len(s.queues)
+}
+
+// GlobalMax returns the name of the queue and the key-value pair with the highest value among all queues.
+// This will panic if all registered queues are empty.
+func (s *Selector[K, V]) GlobalMax() (name string, key K, value V) {
+	name, key, value, err := s.TryGlobalMax()
+	if err != nil {
+		panic(err)
+	}
+	return name, key, value
+}
+
+// TryGlobalMax returns the name of the queue and the key-value pair with the highest value among all queues.
+// In contrast to GlobalMax, it returns ErrEmpty instead of panicking if all registered queues are empty.
+// The maxima of queues with expiring entries are only updated when they are queried, so a queue whose maximum has
+// expired in the meantime is repositioned and the queues are compared again.
+func (s *Selector[K, V]) TryGlobalMax() (name string, key K, value V, err error) {
+	for len(s.heap) > 0 {
+		r := s.heap[0]
+		key, value, err = r.q.TryMax()
+		if err != nil {
+			s.fix(r) // all entries of the queue have expired
+			continue
+		}
+		if s.heap[0] == r {
+			return r.name, key, value, nil
+		}
+		// the expired maximum of the queue has been replaced by a lower one
+	}
+	return "", key, value, ErrEmpty
+}
+
+// PopGlobalMax removes and returns the entry with the highest value among all queues together with the name of
+// the queue that contained it.
+// This will panic if all registered queues are empty.
+func (s *Selector[K, V]) PopGlobalMax() (name string, key K, value V) {
+	name, key, value = s.GlobalMax()
+	s.queues[name].q.Delete(key)
+	return name, key, value
+}
+
+// fix updates the position of the given queue in the heap after its maximum has changed.
+func (s *Selector[K, V]) fix(r *queueRoot[K, V]) {
+	_, value, ok := r.q.peekMax()
+	switch {
+	case !ok && r.index >= 0:
+		heap.Remove(&s.heap, r.index)
+	case ok && r.index < 0:
+		r.value = value
+		heap.Push(&s.heap, r)
+	case ok:
+		r.value = value
+		heap.Fix(&s.heap, r.index)
+	}
+}
+
+func (h queueHeap[K, V]) Len() int {
+	return len(h)
+}
+
+func (h queueHeap[K, V]) Less(i, j int) bool {
+	return h[i].value > h[j].value
+}
+
+func (h queueHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *queueHeap[K, V]) Push(x interface{}) {
+	r := x.(*queueRoot[K, V])
+	r.index = len(*h)
+	*h = append(*h, r)
+}
+
+func (h *queueHeap[K, V]) Pop() interface{} {
+	old := *h
+	n := len(old)
+	r := old[n-1]
+	old[n-1] = nil // avoid memory leak
+	r.index = -1
+	*h = old[0 : n-1]
+	return r
+}
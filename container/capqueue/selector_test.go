@@ -0,0 +1,103 @@
+package capqueue_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestSelector_GlobalMax(t *testing.T) {
+	s := NewSelector[string, int]()
+	assert.Panics(t, func() { _, _, _ = s.GlobalMax() })
+
+	a, b := New[string, int](testCapacity), New[string, int](testCapacity)
+	s.Register("a", a)
+	s.Register("b", b)
+	_, _, _, err := s.TryGlobalMax()
+	assert.Equal(t, ErrEmpty, err)
+
+	a.Add("1", 1)
+	b.Add("2", 2)
+	name, key, value := s.GlobalMax()
+	assert.Equal(t, "b", name)
+	assert.Equal(t, "2", key)
+	assert.Equal(t, 2, value)
+
+	// changes of the queues are reflected
+	a.Add("3", 3)
+	name, key, _ = s.GlobalMax()
+	assert.Equal(t, "a", name)
+	assert.Equal(t, "3", key)
+
+	b.Delete("2")
+	a.Delete("3")
+	name, key, _ = s.GlobalMax()
+	assert.Equal(t, "a", name)
+	assert.Equal(t, "1", key)
+}
+
+func TestSelector_PopGlobalMax(t *testing.T) {
+	s := NewSelector[string, int]()
+	a, b := New[string, int](testCapacity), New[string, int](testCapacity)
+	s.Register("a", a)
+	s.Register("b", b)
+	a.Add("1", 1)
+	a.Add("3", 3)
+	b.Add("2", 2)
+
+	for _, expected := range []struct{ name, key string }{{"a", "3"}, {"b", "2"}, {"a", "1"}} {
+		name, key, _ := s.PopGlobalMax()
+		assert.Equal(t, expected.name, name)
+		assert.Equal(t, expected.key, key)
+	}
+	assert.Zero(t, a.Len()+b.Len())
+	assert.Panics(t, func() { _, _, _ = s.PopGlobalMax() })
+}
+
+func TestSelector_GlobalMaxExpired(t *testing.T) {
+	s := NewSelector[string, int]()
+	a, b, c := New[string, int](testCapacity), New[string, int](testCapacity), New[string, int](testCapacity)
+	s.Register("a", a)
+	s.Register("b", b)
+	s.Register("c", c)
+	a.Add("1", 1)
+	b.Add("2", 2)
+	b.AddWithTTL("3", 3, testTTL)
+	c.AddWithTTL("4", 4, testTTL)
+	name, key, _ := s.GlobalMax()
+	assert.Equal(t, "c", name)
+	assert.Equal(t, "4", key)
+
+	// the expired maxima are skipped, even though the queues have not been modified
+	time.Sleep(testTTL)
+	for _, expected := range []struct{ name, key string }{{"b", "2"}, {"a", "1"}} {
+		name, key, _ := s.PopGlobalMax()
+		assert.Equal(t, expected.name, name)
+		assert.Equal(t, expected.key, key)
+	}
+	_, _, _, err := s.TryGlobalMax()
+	assert.Equal(t, ErrEmpty, err)
+}
+
+func TestSelector_Unregister(t *testing.T) {
+	s := NewSelector[string, int]()
+	a, b := New[string, int](testCapacity), New[string, int](testCapacity)
+	s.Register("a", a)
+	s.Register("b", b)
+	a.Add("1", 1)
+	b.Add("2", 2)
+
+	assert.False(t, s.Unregister("not contained"))
+	assert.True(t, s.Unregister("b"))
+	assert.Equal(t, 1, s.Len())
+	assert.Nil(t, s.Queue("b"))
+	assert.Equal(t, a, s.Queue("a"))
+
+	// modifications of unregistered queues are ignored
+	b.Add("3", 3)
+	name, key, _ := s.GlobalMax()
+	assert.Equal(t, "a", name)
+	assert.Equal(t, "1", key)
+}
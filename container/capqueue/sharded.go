@@ -0,0 +1,462 @@
+package capqueue
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// ShardFunc maps a key to one of n shards, i.e. it must return a value in [0, n).
+type ShardFunc[K comparable] func(key K, n int) int
+
+// Sharded is a concurrent queue that partitions its entries by key into several independent Sync queues.
+// Operations on different shards do not contend for the same lock, at the cost of evicting the oldest entry of
+// the shard instead of the oldest entry overall. Operations on the maximum, like Max and PopMax, merge the cached
+// maxima of all shards without locking them, unless a cached maximum has expired.
+type Sharded[K comparable, V ordering.Ordered] struct {
+	mu     sync.RWMutex // protects shards against concurrent rebalancing
+	shards []*Sync[K, V]
+	opts   func() []Option[K, V]
+
+	shard     ShardFunc[K]
+	normalize func(K) K
+	less      func(a, b Entry[K, V]) bool // ordering of the shards
+}
+
+// NewSharded creates a new Sharded instance consisting of n shards with the given capacity each.
+// The options are applied to every shard. By default, keys are assigned to shards by their hash, which can be
+// changed using WithShardFunc.
+// Options that refer to state or callbacks, i.e. WithAdmission, WithOverflowTo, WithEvictCallback,
+// WithMaxChangeCallback and WithCapacityHeadroom, would be shared by all shards and thus cause a panic. Use
+// NewShardedFunc to create separate instances for every shard instead.
+func NewSharded[K comparable, V ordering.Ordered](n int, shardCap int, opts ...Option[K, V]) *Sharded[K, V] {
+	var o options[K, V]
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if o.admission != nil || o.overflow != nil || o.onEvict != nil || o.onMaxChange != nil || o.onSoftLimit != nil {
+		panic("stateful option shared by all shards, use NewShardedFunc")
+	}
+	return NewShardedFunc[K, V](n, shardCap, func() []Option[K, V] { return opts })
+}
+
+// NewShardedFunc creates a new Sharded instance consisting of n shards with the given capacity each.
+// The function opts is called once for every shard, including the shards created by Rebalance, and must return new
+// instances of all stateful options, e.g. a separate TinyLFU for WithAdmission. The sharding and ordering of the queue
+// are configured by the result of an additional call.
+func NewShardedFunc[K comparable, V ordering.Ordered](n int, shardCap int, opts func() []Option[K, V]) *Sharded[K, V] {
+	if n < 1 {
+		panic("non-positive number of shards")
+	}
+	var o options[K, V]
+	for _, opt := range opts() {
+		opt.apply(&o)
+	}
+	s := &Sharded[K, V]{
+		shards: newShards[K, V](n, shardCap, opts),
+		opts:   opts,
+		shard:  hashShard[K],
+		less:   lessOption(&o),
+	}
+	if s.less == nil {
+		s.less = lessEntry[K, V]
+	}
+	if o.shard != nil {
+		s.shard = o.shard
+	}
+	s.normalize = o.normalizeKey
+	return s
+}
+
+func newShards[K comparable, V ordering.Ordered](n int, shardCap int, opts func() []Option[K, V]) []*Sync[K, V] {
+	shards := make([]*Sync[K, V], n)
+	for i := range shards {
+		shards[i] = NewSync[K, V](shardCap, opts()...)
+	}
+	return shards
+}
+
+// hashShard is the default ShardFunc assigning keys by their FNV-1a hash.
+func hashShard[K comparable](key K, n int) int {
+	return int(hashKey(key) % uint64(n))
+}
+
+// Add adds a new key-value pair to the shard of the key.
+// If the shard is already full, its oldest element gets removed.
+func (s *Sharded[K, V]) Add(key K, value V) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.shardOf(key).Add(key, value)
+}
+
+// TryAdd adds a new key-value pair to the shard of the key.
+// In contrast to Add, it returns ErrKeyTooLong instead of panicking if the key is too long.
+func (s *Sharded[K, V]) TryAdd(key K, value V) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shardOf(key).TryAdd(key, value)
+}
+
+// AddEvict adds a new key-value pair to the shard of the key and returns the key-value pair that was evicted from
+// the shard to make room for it. See CapQueue.AddEvict for details.
+func (s *Sharded[K, V]) AddEvict(key K, value V) (K, V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shardOf(key).AddEvict(key, value)
+}
+
+// AddOrUpdate adds a new key-value pair to the shard of the key or updates the existing entry of the key.
+// See CapQueue.AddOrUpdate for details.
+func (s *Sharded[K, V]) AddOrUpdate(key K, value V) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shardOf(key).AddOrUpdate(key, value)
+}
+
+// AddWithTiebreak adds a new key-value pair with a secondary priority to the shard of the key.
+// See CapQueue.AddWithTiebreak for details.
+func (s *Sharded[K, V]) AddWithTiebreak(key K, value V, tiebreak int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.shardOf(key).AddWithTiebreak(key, value, tiebreak)
+}
+
+// AddWithPayload adds a new key-value pair carrying the given payload to the shard of the key.
+// See CapQueue.AddWithPayload for details.
+func (s *Sharded[K, V]) AddWithPayload(key K, value V, payload interface{}) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.shardOf(key).AddWithPayload(key, value, payload)
+}
+
+// AddWithTTL adds a new key-value pair to the shard of the key, which expires after the given duration.
+// See CapQueue.AddWithTTL for details.
+func (s *Sharded[K, V]) AddWithTTL(key K, value V, ttl time.Duration) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.shardOf(key).AddWithTTL(key, value, ttl)
+}
+
+// AddIfBetter adds a new key-value pair to the shard of the key, unless the shard is full and the entry does not
+// have a higher priority than its minimum. See CapQueue.AddIfBetter for details.
+func (s *Sharded[K, V]) AddIfBetter(key K, value V) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shardOf(key).AddIfBetter(key, value)
+}
+
+// Delete removes the element with the given key.
+// It returns true, if an element was removed or false when no element with the given key exists.
+func (s *Sharded[K, V]) Delete(key K) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shardOf(key).Delete(key)
+}
+
+// Remove removes the element with the given key and returns its value.
+// The second return value is false, when no element with the given key exists.
+func (s *Sharded[K, V]) Remove(key K) (V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shardOf(key).Remove(key)
+}
+
+// Update changes the value of the given key.
+// It returns false, if the queue does not contain the key.
+func (s *Sharded[K, V]) Update(key K, value V) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shardOf(key).Update(key, value)
+}
+
+// Touch makes the entry with the given key the newest entry of its shard without changing its value.
+// It returns false, if the queue does not contain the key.
+func (s *Sharded[K, V]) Touch(key K) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shardOf(key).Touch(key)
+}
+
+// Contains returns whether the queue contains an element with the given key.
+func (s *Sharded[K, V]) Contains(key K) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shardOf(key).Contains(key)
+}
+
+// Get returns the value of the given key.
+// The second return value is false, when no element with the given key exists.
+func (s *Sharded[K, V]) Get(key K) (V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shardOf(key).Get(key)
+}
+
+// Payload returns the payload of the given key.
+// The second return value is false, when no element with the given key exists.
+func (s *Sharded[K, V]) Payload(key K) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shardOf(key).Payload(key)
+}
+
+// Value returns the value of the given key or the zero value sentinel if no such key exists.
+//
+// Deprecated: The value of a missing key cannot be distinguished from a stored value. Use Get instead.
+func (s *Sharded[K, V]) Value(key K) V {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shardOf(key).Value(key)
+}
+
+// Len returns the number of elements contained in all shards.
+func (s *Sharded[K, V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, q := range s.shards {
+		n += q.Len()
+	}
+	return n
+}
+
+// Cap returns the total capacity of all shards.
+func (s *Sharded[K, V]) Cap() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, q := range s.shards {
+		n += q.Cap()
+	}
+	return n
+}
+
+// Max returns the key-value pair with the highest value among all shards.
+// This will panic if all shards are empty.
+func (s *Sharded[K, V]) Max() (K, V) {
+	key, value, err := s.TryMax()
+	if err != nil {
+		panic(err)
+	}
+	return key, value
+}
+
+// TryMax returns the key-value pair with the highest value among all shards.
+// In contrast to Max, it returns ErrEmpty instead of panicking if all shards are empty.
+func (s *Sharded[K, V]) TryMax() (K, V, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, m := s.maxShard()
+	if m == nil {
+		var key K
+		var value V
+		return key, value, ErrEmpty
+	}
+	return m.key, m.value, nil
+}
+
+// PopMax removes and returns the key-value pair with the highest value among all shards.
+// This will panic if all shards are empty.
+func (s *Sharded[K, V]) PopMax() (K, V) {
+	key, value, err := s.TryPopMax()
+	if err != nil {
+		panic(err)
+	}
+	return key, value
+}
+
+// TryPopMax removes and returns the key-value pair with the highest value among all shards.
+// The maximum is removed from the shard that held it when the shards were compared, so that under concurrent
+// modifications, the removed entry may be superseded by a higher entry that has been added in the meantime.
+// In contrast to PopMax, it returns ErrEmpty instead of panicking if all shards are empty.
+func (s *Sharded[K, V]) TryPopMax() (K, V, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for {
+		q, m := s.maxShard()
+		if m == nil {
+			var key K
+			var value V
+			return key, value, ErrEmpty
+		}
+		if key, value, err := q.TryPopMax(); err == nil {
+			return key, value, nil
+		}
+		// the shard has been emptied concurrently, compare the shards again
+	}
+}
+
+// maxShard returns the shard containing the highest maximum and its cached maximum or nil if all shards are empty.
+// It must be called while holding the lock.
+func (s *Sharded[K, V]) maxShard() (*Sync[K, V], *maxEntry[K, V]) {
+	var shard *Sync[K, V]
+	var m *maxEntry[K, V]
+	for _, q := range s.shards {
+		if e := q.loadMax(); e != nil && (m == nil || s.less(m.entry(), e.entry())) {
+			shard, m = q, e
+		}
+	}
+	return shard, m
+}
+
+// Entries returns a snapshot of the entries of all shards ordered by descending priority.
+// The shards are not locked at the same time, so that the snapshot may not reflect a single point in time.
+func (s *Sharded[K, V]) Entries() []Entry[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var entries []Entry[K, V]
+	for _, q := range s.shards {
+		entries = append(entries, q.Entries()...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return s.less(entries[j], entries[i]) })
+	return entries
+}
+
+// TopK returns up to k entries with the highest priority among all shards ordered by descending priority.
+// The shards are not locked at the same time, so that the result may not reflect a single point in time.
+func (s *Sharded[K, V]) TopK(k int) []Entry[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var entries []Entry[K, V]
+	for _, q := range s.shards {
+		entries = append(entries, q.TopK(k)...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return s.less(entries[j], entries[i]) })
+	if len(entries) > k {
+		entries = entries[:k]
+	}
+	return entries
+}
+
+// KthMax returns the key-value pair with the k-th highest priority among all shards, where k = 1 refers to the
+// maximum. The last return value is false, if k is not positive or larger than the number of entries.
+func (s *Sharded[K, V]) KthMax(k int) (K, V, bool) {
+	if k < 1 {
+		var key K
+		var value V
+		return key, value, false
+	}
+	entries := s.TopK(k)
+	if len(entries) < k {
+		var key K
+		var value V
+		return key, value, false
+	}
+	return entries[k-1].Key, entries[k-1].Value, true
+}
+
+// CountAbove returns the number of entries with a value greater than v in all shards.
+func (s *Sharded[K, V]) CountAbove(v V) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, q := range s.shards {
+		n += q.CountAbove(v)
+	}
+	return n
+}
+
+// AllAbove returns all entries with a value greater than v from all shards in unspecified order.
+func (s *Sharded[K, V]) AllAbove(v V) []Entry[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var entries []Entry[K, V]
+	for _, q := range s.shards {
+		entries = append(entries, q.AllAbove(v)...)
+	}
+	return entries
+}
+
+// DrainSorted removes all entries from all shards and returns them ordered by descending priority.
+// The shards are drained one after the other, so entries added concurrently to an already drained shard remain.
+func (s *Sharded[K, V]) DrainSorted() []Entry[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var entries []Entry[K, V]
+	for _, q := range s.shards {
+		entries = append(entries, q.DrainSorted()...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return s.less(entries[j], entries[i]) })
+	return entries
+}
+
+// Clear removes all elements from all shards.
+func (s *Sharded[K, V]) Clear() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, q := range s.shards {
+		q.Clear()
+	}
+}
+
+// Shards returns the number of shards.
+func (s *Sharded[K, V]) Shards() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.shards)
+}
+
+// Shard returns the index of the shard the given key is assigned to.
+func (s *Sharded[K, V]) Shard(key K) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index(key)
+}
+
+// ShardStats returns the statistics of every shard, which allows to detect shards that are overloaded by hot keys.
+func (s *Sharded[K, V]) ShardStats() []Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats := make([]Stats, len(s.shards))
+	for i, q := range s.shards {
+		stats[i] = q.Stats()
+	}
+	return stats
+}
+
+// Rebalance replaces the shards with n new shards of the given capacity each and migrates all entries to the shards
+// assigned by the shard function. The entries keep their value, tiebreak and time of addition, and they are
+// migrated from oldest to newest, so that only the oldest entries get evicted, when a new shard overflows.
+// The statistics of the shards are reset. Rebalance blocks all other operations until the migration is complete.
+func (s *Sharded[K, V]) Rebalance(n int, shardCap int) {
+	if n < 1 {
+		panic("non-positive number of shards")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []Entry[K, V]
+	for _, q := range s.shards {
+		q.mu.RLock()
+		entries = append(entries, q.q.entries()...)
+		q.mu.RUnlock()
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].AddedAt.Before(entries[j].AddedAt) })
+
+	s.shards = newShards[K, V](n, shardCap, s.opts)
+	for _, e := range entries {
+		q := s.shardOf(e.Key)
+		q.mu.Lock()
+		_ = q.q.add(e) // the keys are already validated by the previous shards
+		q.mu.Unlock()
+	}
+}
+
+// index returns the index of the shard of the given key. It must be called while holding the lock.
+func (s *Sharded[K, V]) index(key K) int {
+	if s.normalize != nil {
+		key = s.normalize(key)
+	}
+	i := s.shard(key, len(s.shards))
+	if i < 0 || i >= len(s.shards) {
+		panic("shard index out of range")
+	}
+	return i
+}
+
+// shardOf returns the shard of the given key. It must be called while holding the lock.
+func (s *Sharded[K, V]) shardOf(key K) *Sync[K, V] {
+	return s.shards[s.index(key)]
+}
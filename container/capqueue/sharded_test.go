@@ -0,0 +1,213 @@
+package capqueue_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestSharded(t *testing.T) {
+	s := NewSharded[string, int](4, testCapacity)
+	assert.Panics(t, func() { s.Max() })
+
+	for i := 0; i < testCapacity; i++ {
+		s.Add(strconv.Itoa(i), i)
+	}
+	assert.Equal(t, testCapacity, s.Len())
+	assert.Equal(t, 5, s.Value("5"))
+	maxKey, maxValue := s.Max()
+	assert.Equal(t, strconv.Itoa(testCapacity-1), maxKey)
+	assert.Equal(t, testCapacity-1, maxValue)
+
+	assert.True(t, s.Delete(maxKey))
+	assert.False(t, s.Delete(maxKey))
+	_, maxValue = s.Max()
+	assert.Equal(t, testCapacity-2, maxValue)
+}
+
+func TestSharded_Concurrent(t *testing.T) {
+	s := NewSharded[string, int](4, testCapacity)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < testCapacity; i++ {
+				s.Add(strconv.Itoa(g*testCapacity+i), i)
+				_, _, _ = s.TryMax()
+			}
+		}(g)
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, s.Len(), 4*testCapacity)
+}
+
+func TestSharded_PopMax(t *testing.T) {
+	s := NewSharded[string, int](4, testCapacity)
+	_, _, err := s.TryPopMax()
+	assert.Equal(t, ErrEmpty, err)
+
+	for i := 0; i < testCapacity; i++ {
+		s.Add(strconv.Itoa(i), i)
+	}
+	assert.Equal(t, 4*testCapacity, s.Cap())
+	entries := s.Entries()
+	assert.Len(t, entries, testCapacity)
+	for i, e := range entries {
+		assert.Equal(t, testCapacity-1-i, e.Value)
+		key, value := s.PopMax()
+		assert.Equal(t, e.Key, key)
+		assert.Equal(t, e.Value, value)
+	}
+	assert.Zero(t, s.Len())
+
+	// the shards are compared using the ordering of the queue
+	s = NewSharded[string, int](4, testCapacity, WithMinOrder[string, int]())
+	for i := 0; i < testCapacity; i++ {
+		s.Add(strconv.Itoa(i), i)
+	}
+	key, _ := s.Max()
+	assert.Equal(t, "0", key)
+	key, _, ok := s.AddEvict("new", -1)
+	assert.False(t, ok)
+	assert.Empty(t, key)
+	key, _ = s.PopMax()
+	assert.Equal(t, "new", key)
+
+	s.Clear()
+	assert.Zero(t, s.Len())
+}
+
+func TestSharded_ConcurrentPopMax(t *testing.T) {
+	const producers = 16
+
+	s := NewSharded[string, int](producers, producers*testCapacity)
+	var wg sync.WaitGroup
+	wg.Add(producers)
+	for g := 0; g < producers; g++ {
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < testCapacity; i++ {
+				s.Add(strconv.Itoa(g*testCapacity+i), i)
+			}
+		}(g)
+	}
+	popped := make(chan int, producers*testCapacity)
+	for g := 0; g < producers/2; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < testCapacity; i++ {
+				if _, value, err := s.TryPopMax(); err == nil {
+					popped <- value
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(popped)
+	assert.Equal(t, producers*testCapacity, s.Len()+len(popped))
+}
+
+func TestWithShardFunc(t *testing.T) {
+	// isolate the hot key in shard 0 and distribute all other keys across the remaining shards
+	hot := func(key string, n int) int {
+		if key == "hot" {
+			return 0
+		}
+		i, _ := strconv.Atoi(key)
+		return 1 + i%(n-1)
+	}
+	s := NewSharded[string, int](3, testCapacity, WithShardFunc[string, int](hot), WithKeyNormalizer[string, int](func(key string) string {
+		if key == "HOT" {
+			return "hot"
+		}
+		return key
+	}))
+	s.Add("HOT", 1)
+	for i := 0; i < 4; i++ {
+		s.Add(strconv.Itoa(i), i)
+	}
+	assert.Equal(t, 0, s.Shard("HOT"))
+	assert.Equal(t, 1, s.Shard("2"))
+
+	stats := s.ShardStats()
+	if assert.Len(t, stats, 3) {
+		assert.Equal(t, 1, stats[0].Len)
+		assert.Equal(t, 2, stats[1].Len)
+		assert.Equal(t, 2, stats[2].Len)
+	}
+}
+
+func TestSharded_Rebalance(t *testing.T) {
+	s := NewSharded[string, int](2, testCapacity)
+	for i := 0; i < 2*testCapacity; i++ {
+		s.AddWithTiebreak(strconv.Itoa(i), i, -i)
+	}
+	assert.Equal(t, 2*testCapacity, s.Len())
+
+	s.Rebalance(4, testCapacity)
+	assert.Equal(t, 4, s.Shards())
+	assert.Equal(t, 2*testCapacity, s.Len())
+	for i := 0; i < 2*testCapacity; i++ {
+		assert.Equal(t, i, s.Value(strconv.Itoa(i)))
+	}
+	total := 0
+	for _, st := range s.ShardStats() {
+		total += st.Len
+	}
+	assert.Equal(t, 2*testCapacity, total)
+
+	// shrinking evicts the oldest entries of the new shard
+	s.Rebalance(1, testCapacity)
+	assert.Equal(t, testCapacity, s.Len())
+	assert.Equal(t, 0, s.Value("1"))
+	assert.Equal(t, 2*testCapacity-1, s.Value(strconv.Itoa(2*testCapacity-1)))
+
+	assert.Panics(t, func() { s.Rebalance(0, testCapacity) })
+}
+
+func TestNewSharded_StatefulOptions(t *testing.T) {
+	assert.Panics(t, func() {
+		NewSharded[string, int](2, testCapacity, WithAdmission[string, int](NewTinyLFU[string, int](testCapacity)))
+	})
+	assert.Panics(t, func() {
+		NewSharded[string, int](2, testCapacity, WithOverflowTo[string, int](New[string, int](testCapacity)))
+	})
+	assert.Panics(t, func() {
+		NewSharded[string, int](2, testCapacity, WithEvictCallback[string, int](func(string, int) {}))
+	})
+	assert.Panics(t, func() {
+		NewSharded[string, int](2, testCapacity, WithMaxChangeCallback[string, int](func() {}))
+	})
+	assert.Panics(t, func() {
+		NewSharded[string, int](2, testCapacity, WithCapacityHeadroom[string, int](1, func(int) {}))
+	})
+}
+
+func TestNewShardedFunc(t *testing.T) {
+	var overflows []*CapQueue[string, int]
+	opts := func() []Option[string, int] {
+		overflow := New[string, int](testCapacity)
+		overflows = append(overflows, overflow)
+		return []Option[string, int]{WithOverflowTo[string, int](overflow)}
+	}
+	s := NewShardedFunc[string, int](2, 1, opts)
+	// one call for the configuration of the Sharded instance and one for each shard
+	assert.Len(t, overflows, 3)
+
+	for i := 0; i < 4; i++ {
+		s.Add(strconv.Itoa(i), i)
+	}
+	assert.Equal(t, 2, s.Len())
+	assert.Zero(t, overflows[0].Len())
+	assert.Equal(t, 2, overflows[1].Len()+overflows[2].Len())
+
+	// rebalancing creates new instances for the new shards
+	s.Rebalance(4, 1)
+	assert.Len(t, overflows, 7)
+	assert.Equal(t, 2, s.Len())
+}
@@ -0,0 +1,176 @@
+package capqueue
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// Snapshot is a serializable representation of the content of a CapQueue.
+type Snapshot[K comparable, V ordering.Ordered] struct {
+	Cap     int           // capacity of the queue
+	Entries []Entry[K, V] // all entries ordered from oldest to newest
+}
+
+// A Codec encodes and decodes snapshots.
+type Codec[K comparable, V ordering.Ordered] interface {
+	// Encode writes the encoding of s to w.
+	Encode(w io.Writer, s Snapshot[K, V]) error
+	// Decode reads an encoded snapshot from r.
+	Decode(r io.Reader) (Snapshot[K, V], error)
+}
+
+// JSON returns the Codec encoding a Snapshot as JSON.
+func JSON[K comparable, V ordering.Ordered]() Codec[K, V] {
+	return jsonCodec[K, V]{}
+}
+
+// Gob returns the Codec encoding a Snapshot using encoding/gob.
+func Gob[K comparable, V ordering.Ordered]() Codec[K, V] {
+	return gobCodec[K, V]{}
+}
+
+// CBOR returns the Codec encoding a Snapshot as CBOR, which is the most compact of the supported encodings.
+func CBOR[K comparable, V ordering.Ordered]() Codec[K, V] {
+	return cborCodec[K, V]{}
+}
+
+// Export returns a snapshot of the capacity and the entries of the queue.
+func (h *CapQueue[K, V]) Export() Snapshot[K, V] {
+	return Snapshot[K, V]{Cap: h.cap, Entries: h.entries()}
+}
+
+// NewFromSnapshot creates a new CapQueue instance containing the entries of the given snapshot.
+// The entries are added in the order of the snapshot, including their original time of addition.
+// If the snapshot contains the same key more than once, the last entry wins.
+func NewFromSnapshot[K comparable, V ordering.Ordered](s Snapshot[K, V], opts ...Option[K, V]) (*CapQueue[K, V], error) {
+	if s.Cap < 0 {
+		return nil, fmt.Errorf("%w: negative capacity", ErrInvalidSnapshot)
+	}
+	h := New[K, V](s.Cap, opts...)
+	if err := h.addAll(s.Entries); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// FromEntries creates a new CapQueue instance containing the given entries, which are ordered from oldest to newest
+// like the entries of a Snapshot. If the entries contain the same key more than once, the last entry wins, and if
+// there are more than cap distinct keys, only the newest cap entries are kept.
+// In contrast to adding the entries one by one, the heap is built at once in O(n) time. The entries are neither
+// subject to an admission policy nor to an eviction policy other than the default one.
+// This will panic if cap is negative.
+func FromEntries[K comparable, V ordering.Ordered](cap int, entries []Entry[K, V], opts ...Option[K, V]) *CapQueue[K, V] {
+	h := New[K, V](cap, opts...)
+	defer h.trackMax()()
+
+	// select the newest entry of every key, starting with the newest one
+	seen := make(map[K]struct{}, len(entries))
+	selected := make([]Entry[K, V], 0, len(entries))
+	for i := len(entries) - 1; i >= 0 && (cap == 0 || len(selected) < cap); i-- {
+		e := entries[i]
+		e.Key = h.normalize(e.Key)
+		if _, ok := seen[e.Key]; ok {
+			continue
+		}
+		seen[e.Key] = struct{}{}
+		selected = append(selected, e)
+	}
+
+	for i := len(selected) - 1; i >= 0; i-- {
+		h.countAdd()
+		it := h.newItem()
+		it.set(selected[i])
+		it.index = len(h.heap)
+		h.heap = append(h.heap, it)
+		h.link(it)
+	}
+	h.heapInit()
+	return h
+}
+
+// MarshalJSON encodes the capacity and the entries of the queue in insertion order as the JSON encoding of a
+// Snapshot. It implements the json.Marshaler interface.
+func (h *CapQueue[K, V]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Export())
+}
+
+// UnmarshalJSON replaces the content of the queue with the JSON encoding of a Snapshot like NewFromSnapshot.
+// The queue keeps its options, but its capacity is changed to the one of the snapshot. A zero CapQueue, e.g. a field
+// of a struct that is being unmarshaled, is initialized like by New without any options.
+// It implements the json.Unmarshaler interface.
+func (h *CapQueue[K, V]) UnmarshalJSON(data []byte) error {
+	var s Snapshot[K, V]
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return h.restore(s)
+}
+
+// restore replaces the content of the queue with the given snapshot, initializing a zero queue first.
+func (h *CapQueue[K, V]) restore(s Snapshot[K, V]) error {
+	if s.Cap < 0 {
+		return fmt.Errorf("%w: negative capacity", ErrInvalidSnapshot)
+	}
+	if h.zero() {
+		h.init(s.Cap)
+	} else {
+		if s.Cap == 0 && h.maxKeyLen > 0 {
+			return fmt.Errorf("%w: preallocated queue cannot be unbounded", ErrInvalidSnapshot)
+		}
+		h.Clear()
+		if s.Cap != h.cap {
+			h.SetCap(s.Cap)
+		}
+	}
+	return h.addAll(s.Entries)
+}
+
+// zero returns whether h is a zero CapQueue, which has not been initialized yet.
+func (h *CapQueue[K, V]) zero() bool {
+	return h.index == nil && h.table == nil
+}
+
+// init initializes a zero queue with the given capacity.
+func (h *CapQueue[K, V]) init(cap int) {
+	*h = *New[K, V](cap)
+	h.order.init() // the sentinel must not refer to the copied queue
+}
+
+// addAll adds the given entries in order, replacing existing entries with the same key.
+func (h *CapQueue[K, V]) addAll(entries []Entry[K, V]) error {
+	for _, e := range entries {
+		h.Delete(e.Key)
+		if err := h.add(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonCodec[K comparable, V ordering.Ordered] struct{}
+
+func (jsonCodec[K, V]) Encode(w io.Writer, s Snapshot[K, V]) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+func (jsonCodec[K, V]) Decode(r io.Reader) (Snapshot[K, V], error) {
+	var s Snapshot[K, V]
+	err := json.NewDecoder(r).Decode(&s)
+	return s, err
+}
+
+type gobCodec[K comparable, V ordering.Ordered] struct{}
+
+func (gobCodec[K, V]) Encode(w io.Writer, s Snapshot[K, V]) error {
+	return gob.NewEncoder(w).Encode(s)
+}
+
+func (gobCodec[K, V]) Decode(r io.Reader) (Snapshot[K, V], error) {
+	var s Snapshot[K, V]
+	err := gob.NewDecoder(r).Decode(&s)
+	return s, err
+}
@@ -0,0 +1,127 @@
+package capqueue_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+// assertEntriesEqual asserts that both entry slices are equal, ignoring the monotonic clock reading.
+func assertEntriesEqual(t *testing.T, expected []Entry[string, int], actual []Entry[string, int]) {
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		assert.Equal(t, expected[i].Key, actual[i].Key)
+		assert.Equal(t, expected[i].Value, actual[i].Value)
+		assert.Equal(t, expected[i].Tiebreak, actual[i].Tiebreak)
+		assert.True(t, expected[i].AddedAt.Equal(actual[i].AddedAt))
+		assert.True(t, expected[i].ExpiresAt.Equal(actual[i].ExpiresAt))
+	}
+}
+
+func TestNewFromSnapshot(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity+1; i++ {
+		q.AddWithTiebreak(fmt.Sprint(i), i, -i)
+	}
+
+	s := q.Export()
+	assert.Equal(t, testCapacity, s.Cap)
+	assertEntriesEqual(t, q.OldestK(testCapacity), s.Entries)
+
+	restored, err := NewFromSnapshot(s)
+	require.NoError(t, err)
+	assert.Equal(t, q.Cap(), restored.Cap())
+	assertEntriesEqual(t, q.Entries(), restored.Entries())
+
+	_, err = NewFromSnapshot(Snapshot[string, int]{Cap: -1})
+	assert.True(t, errors.Is(err, ErrInvalidSnapshot))
+}
+
+func TestFromEntries(t *testing.T) {
+	now := time.Now()
+	var entries []Entry[string, int]
+	for i := 0; i < 4*testCapacity; i++ {
+		entries = append(entries, Entry[string, int]{Key: fmt.Sprint(i % 15), Value: i * 7 % 11, AddedAt: now})
+	}
+
+	for _, cap := range []int{testCapacity, 0} {
+		expected, err := NewFromSnapshot(Snapshot[string, int]{Cap: cap, Entries: entries})
+		require.NoError(t, err)
+		q := FromEntries(cap, entries, WithArity[string, int](3))
+		assert.Equal(t, cap, q.Cap())
+		assertEntriesEqual(t, expected.OldestK(expected.Len()), q.OldestK(q.Len()))
+		for expected.Len() > 0 {
+			_, expectedValue := expected.PopMax()
+			_, value := q.PopMax()
+			require.Equal(t, expectedValue, value)
+		}
+		assert.Zero(t, q.Len())
+	}
+
+	assert.Zero(t, FromEntries[string, int](testCapacity, nil).Len())
+}
+
+func TestCapQueue_MarshalJSON(t *testing.T) {
+	type state struct {
+		Name  string
+		Queue *CapQueue[string, int]
+		Value CapQueue[string, int]
+	}
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity+1; i++ {
+		q.AddWithTiebreak(fmt.Sprint(i), i%3, i)
+	}
+
+	data, err := json.Marshal(&state{Name: "test", Queue: q})
+	require.NoError(t, err)
+	var decoded state
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "test", decoded.Name)
+	assert.Equal(t, testCapacity, decoded.Queue.Cap())
+	assertEntriesEqual(t, q.OldestK(testCapacity), decoded.Queue.OldestK(testCapacity))
+	// the zero queue is initialized
+	assert.Zero(t, decoded.Value.Cap())
+	decoded.Value.Add("a", 1)
+	assert.Equal(t, []string{"a"}, decoded.Value.Keys())
+
+	// an existing queue keeps its options
+	p := NewPreallocated[string, int](2, 3)
+	p.Add("old", 0)
+	data, err = json.Marshal(q)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, p))
+	assert.Equal(t, testCapacity, p.Cap())
+	assertEntriesEqual(t, q.Entries(), p.Entries())
+
+	err = p.UnmarshalJSON([]byte(`{"Cap":0}`))
+	assert.True(t, errors.Is(err, ErrInvalidSnapshot))
+	err = p.UnmarshalJSON([]byte(`{"Cap":1,"Entries":[{"Key":"too long"}]}`))
+	assert.True(t, errors.Is(err, ErrKeyTooLong))
+}
+
+func TestCodecs(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity; i++ {
+		q.AddWithTiebreak(fmt.Sprint(i), i-testCapacity/2, i)
+	}
+	s := q.Export()
+	s.Entries = append(s.Entries, Entry[string, int]{Key: "unicode ✓", Value: -1 << 40})
+
+	for name, codec := range map[string]Codec[string, int]{"JSON": JSON[string, int](), "Gob": Gob[string, int](), "CBOR": CBOR[string, int]()} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, codec.Encode(&buf, s))
+			decoded, err := codec.Decode(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, s.Cap, decoded.Cap)
+			assertEntriesEqual(t, s.Entries, decoded.Entries)
+		})
+	}
+}
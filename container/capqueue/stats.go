@@ -0,0 +1,55 @@
+package capqueue
+
+// Stats contains statistics about a CapQueue.
+type Stats struct {
+	Len         int    // number of entries in the queue
+	Cap         int    // capacity of the queue
+	Adds        uint64 // total number of added entries
+	Evictions   uint64 // total number of entries that were removed to make room for new entries
+	Rejections  uint64 // total number of entries that were rejected by the admission or eviction policy
+	Expirations uint64 // total number of entries that were removed because their TTL elapsed
+	Seed        int64  // seed of the random source used by randomized operations
+
+	// windowed rates, only available with WithRates
+	AddRate      Rates // added entries per second
+	EvictionRate Rates // evicted entries per second
+	HitRatio     Rates // ratio of lookups using Get or Value that found the key
+}
+
+// Stats returns statistics about the queue.
+func (h *CapQueue[K, V]) Stats() Stats {
+	s := Stats{
+		Len:         h.size(),
+		Cap:         h.Cap(),
+		Adds:        h.adds,
+		Evictions:   h.evictions,
+		Rejections:  h.rejections,
+		Expirations: h.expirations,
+		Seed:        h.seed,
+	}
+	if h.rates != nil {
+		s.AddRate = perSecond(h.rates.adds)
+		s.EvictionRate = perSecond(h.rates.evictions)
+		s.HitRatio = h.rates.hitRatio()
+	}
+	return s
+}
+
+// Sample returns up to n entries chosen uniformly at random without removing them.
+// The entries are chosen using the random source of the queue, see WithSeed.
+func (h *CapQueue[K, V]) Sample(n int) []Entry[K, V] {
+	h.expire()
+	if n > h.size() {
+		n = h.size()
+	}
+	entries := make([]Entry[K, V], 0, n)
+	for _, j := range h.rand.Perm(len(h.heap)) {
+		if len(entries) == n {
+			break
+		}
+		if it := h.heap[j]; !it.deleted {
+			entries = append(entries, it.entry())
+		}
+	}
+	return entries
+}
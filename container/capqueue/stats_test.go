@@ -0,0 +1,69 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestCapQueue_Stats(t *testing.T) {
+	q := New[string, int](testCapacity, WithSeed[string, int](42))
+	for i := 1; i <= testCapacity+2; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+
+	assert.Equal(t, Stats{
+		Len:       testCapacity,
+		Cap:       testCapacity,
+		Adds:      testCapacity + 2,
+		Evictions: 2,
+		Seed:      42,
+	}, q.Stats())
+}
+
+func TestWithRates(t *testing.T) {
+	q := New[string, int](testCapacity, WithRates[string, int]())
+	for i := 1; i <= testCapacity+2; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	q.Value("1")
+	q.Value("3")
+
+	s := q.Stats()
+	assert.InDelta(t, testCapacity+2, s.AddRate.Second, 1e-9)
+	assert.InDelta(t, float64(testCapacity+2)/10, s.AddRate.TenSeconds, 1e-9)
+	assert.InDelta(t, float64(testCapacity+2)/60, s.AddRate.Minute, 1e-9)
+	assert.InDelta(t, 2, s.EvictionRate.Second, 1e-9)
+	assert.InDelta(t, 0.5, s.HitRatio.Minute, 1e-9)
+}
+
+func TestCapQueue_Sample(t *testing.T) {
+	newQueue := func(seed int64) *CapQueue[string, int] {
+		q := New[string, int](testCapacity, WithSeed[string, int](seed))
+		for i := 1; i <= testCapacity; i++ {
+			q.Add(fmt.Sprint(i), i)
+		}
+		return q
+	}
+
+	q := newQueue(1)
+	assert.Empty(t, q.Sample(0))
+	assert.ElementsMatch(t, q.Entries(), q.Sample(2*testCapacity))
+
+	samples := q.Sample(testCapacity / 2)
+	assert.Len(t, samples, testCapacity/2)
+	for _, e := range samples {
+		assert.Equal(t, e.Value, q.Value(e.Key))
+	}
+
+	// the same seed leads to the same samples
+	keys := func(entries []Entry[string, int]) (keys []string) {
+		for _, e := range entries {
+			keys = append(keys, e.Key)
+		}
+		return keys
+	}
+	assert.Equal(t, keys(newQueue(2).Sample(3)), keys(newQueue(2).Sample(3)))
+}
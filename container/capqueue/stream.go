@@ -0,0 +1,105 @@
+package capqueue
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// WriteTo writes the capacity and all entries of the queue to w using the CBOR encoding of a Snapshot.
+// In contrast to encoding the result of Export, the entries are streamed one by one without creating a copy of
+// the queue content in memory.
+// It returns the number of bytes written and implements the io.WriterTo interface.
+func (h *CapQueue[K, V]) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	e := newCBOREncoder(cw)
+	e.header(h.cap, h.size())
+	for it := h.order.front(); it != nil && e.err == nil; it = h.order.next(it) {
+		encodeEntry(e, it.entry())
+	}
+	err := e.flush()
+	return cw.n, err
+}
+
+// ReadFrom reads a CBOR encoded Snapshot from r and adds its entries one by one to the queue, so that the content
+// is never held in memory twice. The entries are added like with NewFromSnapshot, i.e. they keep their original
+// time of addition and the oldest entries get evicted, when the queue overflows. The capacity recorded in the
+// stream is ignored, unless h is a zero CapQueue, which is initialized with it like by UnmarshalBinary.
+// It returns the number of bytes read and implements the io.ReaderFrom interface. Exactly the bytes of the snapshot
+// are consumed, so that any data following it can still be read from r. If r does not implement io.ByteReader, it
+// is read in small chunks, so wrapping it in a bufio.Reader is faster when r is not needed afterwards.
+func (h *CapQueue[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: asByteReader(r)}
+	d := newCBORDecoder(cr)
+	cap, n := d.header()
+	if d.err == nil && h.zero() {
+		if cap < 0 {
+			return cr.n, fmt.Errorf("%w: negative capacity", ErrInvalidSnapshot)
+		}
+		h.init(cap)
+	}
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		e := decodeEntry[K, V](d)
+		if d.err != nil {
+			break
+		}
+		h.Delete(e.Key)
+		if err := h.add(e); err != nil {
+			return cr.n, err
+		}
+	}
+	return cr.n, d.err
+}
+
+// MarshalBinary encodes the capacity and the entries of the queue in insertion order using the compact CBOR encoding
+// of a Snapshot like WriteTo. It implements the encoding.BinaryMarshaler interface, which is also used by encoding/gob,
+// so that no registration is needed to gob encode a queue.
+func (h *CapQueue[K, V]) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := h.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the content of the queue with the CBOR encoding of a Snapshot like UnmarshalJSON.
+// It implements the encoding.BinaryUnmarshaler interface.
+func (h *CapQueue[K, V]) UnmarshalBinary(data []byte) error {
+	s, err := CBOR[K, V]().Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return h.restore(s)
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	r byteReader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
@@ -0,0 +1,144 @@
+package capqueue_test
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+var (
+	_ io.WriterTo                = (*CapQueue[string, int])(nil)
+	_ io.ReaderFrom              = (*CapQueue[string, int])(nil)
+	_ encoding.BinaryMarshaler   = (*CapQueue[string, int])(nil)
+	_ encoding.BinaryUnmarshaler = (*CapQueue[string, int])(nil)
+)
+
+func TestCapQueue_WriteTo(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity+1; i++ {
+		q.AddWithTiebreak(fmt.Sprint(i), i, -i)
+	}
+
+	var buf bytes.Buffer
+	n, err := q.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, buf.Len(), n)
+
+	// the stream is a valid CBOR snapshot
+	s, err := CBOR[string, int]().Decode(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, testCapacity, s.Cap)
+	assertEntriesEqual(t, q.OldestK(testCapacity), s.Entries)
+}
+
+func TestCapQueue_ReadFrom(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	var buf bytes.Buffer
+	_, err := q.WriteTo(&buf)
+	require.NoError(t, err)
+	size := buf.Len()
+
+	restored := New[string, int](testCapacity / 2)
+	n, err := restored.ReadFrom(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, size, n)
+	// only the newest entries fit into the smaller queue
+	assertEntriesEqual(t, q.Entries()[:testCapacity/2], restored.Entries())
+
+	// a zero queue gets the capacity of the stream
+	buf.Reset()
+	_, err = q.WriteTo(&buf)
+	require.NoError(t, err)
+	var zero CapQueue[string, int]
+	n, err = zero.ReadFrom(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, size, n)
+	assert.Equal(t, testCapacity, zero.Cap())
+	assertEntriesEqual(t, q.Entries(), zero.Entries())
+}
+
+func TestCapQueue_ReadFromTrailing(t *testing.T) {
+	q := New[string, int](testCapacity)
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	var buf bytes.Buffer
+	_, err := q.WriteTo(&buf)
+	require.NoError(t, err)
+	size := buf.Len()
+	trailer := []byte("trailer")
+
+	for name, wrap := range map[string]func(io.Reader) io.Reader{
+		"ByteReader": func(r io.Reader) io.Reader { return r },
+		"Reader":     func(r io.Reader) io.Reader { return struct{ io.Reader }{r} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := bytes.NewReader(append(append([]byte{}, buf.Bytes()...), trailer...))
+			restored := New[string, int](testCapacity)
+			n, err := restored.ReadFrom(wrap(r))
+			require.NoError(t, err)
+			assert.EqualValues(t, size, n)
+			assert.Equal(t, q.Keys(), restored.Keys())
+
+			// the data following the snapshot has not been consumed
+			rest, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, trailer, rest)
+		})
+	}
+}
+
+func TestCapQueue_ReadFromInvalid(t *testing.T) {
+	q := New[string, int](testCapacity)
+	_, err := q.ReadFrom(bytes.NewReader([]byte{0x82, 0x01, 0x81}))
+	assert.True(t, errors.Is(err, ErrInvalidSnapshot))
+	assert.Zero(t, q.Len())
+}
+
+func TestCapQueue_MarshalBinary(t *testing.T) {
+	q := New[string, float64](testCapacity)
+	for i := 1; i <= testCapacity+1; i++ {
+		q.AddWithTiebreak(fmt.Sprint(i), float64(i)/2, -i)
+	}
+
+	data, err := q.MarshalBinary()
+	require.NoError(t, err)
+	decoded := New[string, float64](1, WithStableOrder[string, float64]())
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, q.Cap(), decoded.Cap())
+	assert.Equal(t, q.Keys(), decoded.Keys())
+	assert.Equal(t, q.Export().Entries[0].Tiebreak, decoded.Export().Entries[0].Tiebreak)
+
+	err = decoded.UnmarshalBinary(data[:len(data)-1])
+	assert.True(t, errors.Is(err, ErrInvalidSnapshot))
+}
+
+func TestCapQueue_Gob(t *testing.T) {
+	type state struct {
+		Round int
+		Queue *CapQueue[int, int]
+	}
+	q := New[int, int](testCapacity)
+	for i := 0; i < testCapacity; i++ {
+		q.Add(i, -i)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&buf).Encode(state{Round: 1, Queue: q}))
+	var decoded state
+	require.NoError(t, gob.NewDecoder(&buf).Decode(&decoded))
+	assert.Equal(t, 1, decoded.Round)
+	assert.Equal(t, q.Keys(), decoded.Queue.Keys())
+	assert.Equal(t, q.Entries()[0].Value, decoded.Queue.Entries()[0].Value)
+}
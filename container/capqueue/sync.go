@@ -0,0 +1,585 @@
+package capqueue
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// Sync is a CapQueue that is safe for concurrent use by multiple goroutines.
+// It provides the same methods as CapQueue, except for batches, which are not needed as no other goroutine can
+// observe the queue during a single call. Methods that only read the queue acquire a read lock, so that they can
+// run in parallel. Reading the maximum using Max does not acquire any lock, as the current maximum is cached in an
+// atomic value that is updated whenever the maximum of the queue changes. Only an expired maximum has to be removed
+// under the write lock.
+type Sync[K comparable, V ordering.Ordered] struct {
+	mu sync.RWMutex
+	q  *CapQueue[K, V]
+
+	max      atomic.Value // *maxEntry containing the current maximum or nil when empty
+	nonEmpty *sync.Cond   // broadcast whenever the maximum changes to a non-empty queue, see Drain and PopMaxWait
+}
+
+// maxEntry is an immutable copy of the maximum of a queue.
+type maxEntry[K comparable, V ordering.Ordered] struct {
+	key       K
+	value     V
+	tiebreak  int
+	expiresAt time.Time
+}
+
+// entry returns the maximum as an entry, which can be compared using the ordering of the queue.
+func (m *maxEntry[K, V]) entry() Entry[K, V] {
+	return Entry[K, V]{Key: m.key, Value: m.value, Tiebreak: m.tiebreak}
+}
+
+// expired returns whether the maximum has expired at the given time.
+func (m *maxEntry[K, V]) expired(now time.Time) bool {
+	return !m.expiresAt.IsZero() && !m.expiresAt.After(now)
+}
+
+// NewSync creates a new Sync instance.
+func NewSync[K comparable, V ordering.Ordered](cap int, opts ...Option[K, V]) *Sync[K, V] {
+	s := &Sync[K, V]{q: New[K, V](cap, opts...)}
+	s.nonEmpty = sync.NewCond(&s.mu)
+	s.q.addMaxHook(s.storeMax)
+	s.storeMax()
+	return s
+}
+
+// Add adds a new key-value pair to the queue.
+// If the queue is already full, the oldest element gets removed.
+func (s *Sync[K, V]) Add(key K, value V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.q.Add(key, value)
+}
+
+// TryAdd adds a new key-value pair to the queue.
+// In contrast to Add, it returns ErrKeyTooLong instead of panicking if the key is too long.
+func (s *Sync[K, V]) TryAdd(key K, value V) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.TryAdd(key, value)
+}
+
+// AddWithTTL adds a new key-value pair to the queue, which expires after the given duration.
+// See CapQueue.AddWithTTL for details.
+func (s *Sync[K, V]) AddWithTTL(key K, value V, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.q.AddWithTTL(key, value, ttl)
+}
+
+// Expire removes all expired entries from the queue and returns their number.
+func (s *Sync[K, V]) Expire() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.Expire()
+}
+
+// AddOrUpdate adds a new key-value pair to the queue or updates the existing entry of the key.
+// See CapQueue.AddOrUpdate for details.
+func (s *Sync[K, V]) AddOrUpdate(key K, value V) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.AddOrUpdate(key, value)
+}
+
+// AddWithTiebreak adds a new key-value pair with a secondary priority to the queue.
+// See CapQueue.AddWithTiebreak for details.
+func (s *Sync[K, V]) AddWithTiebreak(key K, value V, tiebreak int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.q.AddWithTiebreak(key, value, tiebreak)
+}
+
+// AddWithPayload adds a new key-value pair carrying the given payload to the queue.
+// See CapQueue.AddWithPayload for details.
+func (s *Sync[K, V]) AddWithPayload(key K, value V, payload interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.q.AddWithPayload(key, value, payload)
+}
+
+// AddIfBetter adds a new key-value pair to the queue, unless it is full and the entry does not have a higher
+// priority than its minimum. See CapQueue.AddIfBetter for details.
+func (s *Sync[K, V]) AddIfBetter(key K, value V) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.AddIfBetter(key, value)
+}
+
+// AddEvict adds a new key-value pair to the queue and returns the key-value pair that was evicted to make room for it.
+// See CapQueue.AddEvict for details.
+func (s *Sync[K, V]) AddEvict(key K, value V) (K, V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.AddEvict(key, value)
+}
+
+// MergeMap applies all key-value pairs of m to the queue with upsert semantics.
+// See CapQueue.MergeMap for details.
+func (s *Sync[K, V]) MergeMap(m map[K]V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.q.MergeMap(m)
+}
+
+// Update changes the value of the given key.
+// It returns false, if the queue does not contain the key.
+func (s *Sync[K, V]) Update(key K, value V) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.Update(key, value)
+}
+
+// Delete removes the element with the given key.
+// It returns true, if an element was removed or false when no element with the given key exists.
+func (s *Sync[K, V]) Delete(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.Delete(key)
+}
+
+// Remove removes the element with the given key and returns its value.
+// The second return value is false, when no element with the given key exists.
+func (s *Sync[K, V]) Remove(key K) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.Remove(key)
+}
+
+// Get returns the value of the given key.
+// The second return value is false, when no element with the given key exists.
+func (s *Sync[K, V]) Get(key K) (V, bool) {
+	defer s.lockLookup(true)()
+	return s.q.Get(key)
+}
+
+// Payload returns the payload of the given key.
+// The second return value is false, when no element with the given key exists.
+func (s *Sync[K, V]) Payload(key K) (interface{}, bool) {
+	defer s.lockLookup(true)()
+	return s.q.Payload(key)
+}
+
+// Touch makes the entry with the given key the newest entry of the queue without changing its value.
+// It returns false, if the queue does not contain the key.
+func (s *Sync[K, V]) Touch(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.Touch(key)
+}
+
+// Contains returns whether the queue contains an element with the given key.
+func (s *Sync[K, V]) Contains(key K) bool {
+	defer s.lockLookup(false)()
+	return s.q.Contains(key)
+}
+
+// Value returns the value of the given key or 0 if no such key exists.
+//
+// Deprecated: The value of a missing key cannot be distinguished from a stored value. Use Get instead.
+func (s *Sync[K, V]) Value(key K) V {
+	defer s.lockLookup(true)()
+	return s.q.Value(key)
+}
+
+// Len returns the number of elements contained in the queue.
+func (s *Sync[K, V]) Len() int {
+	defer s.lockLookup(false)()
+	return s.q.Len()
+}
+
+// Cap returns the maximum capacity of the queue or zero if the queue is unbounded.
+func (s *Sync[K, V]) Cap() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.q.Cap()
+}
+
+// SetCap changes the capacity of the queue to n.
+// See CapQueue.SetCap for details.
+func (s *Sync[K, V]) SetCap(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.q.SetCap(n)
+}
+
+// Max returns the key-value pair with the highest value.
+// It reads the cached maximum without acquiring any lock, unless the cached maximum has expired. Then the write lock
+// is acquired to remove the expired entries, so Max may block on concurrent modifications. In contrast to
+// CapQueue.Max, it does not count as a use of the entry for EvictLRU.
+// This will panic if the queue is empty.
+func (s *Sync[K, V]) Max() (K, V) {
+	key, value, err := s.TryMax()
+	if err != nil {
+		panic(err)
+	}
+	return key, value
+}
+
+// TryMax returns the key-value pair with the highest value.
+// In contrast to Max, it returns ErrEmpty instead of panicking if the queue is empty.
+func (s *Sync[K, V]) TryMax() (K, V, error) {
+	m := s.loadMax()
+	if m == nil {
+		var key K
+		var value V
+		return key, value, ErrEmpty
+	}
+	return m.key, m.value, nil
+}
+
+// PopMax removes and returns the key-value pair with the highest value.
+// This will panic if the queue is empty.
+func (s *Sync[K, V]) PopMax() (K, V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.PopMax()
+}
+
+// TryPopMax removes and returns the key-value pair with the highest value.
+// In contrast to PopMax, it returns ErrEmpty instead of panicking if the queue is empty.
+func (s *Sync[K, V]) TryPopMax() (K, V, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.TryPopMax()
+}
+
+// MaxEntry returns the entry with the highest value including its payload.
+// The second return value is false, if the queue is empty.
+func (s *Sync[K, V]) MaxEntry() (Entry[K, V], bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.MaxEntry()
+}
+
+// PopMaxEntry removes and returns the entry with the highest value including its payload.
+// The second return value is false, if the queue is empty.
+func (s *Sync[K, V]) PopMaxEntry() (Entry[K, V], bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.PopMaxEntry()
+}
+
+// PopMaxWait removes and returns the key-value pair with the highest value like PopMax. If the queue is empty, it
+// blocks until an entry is added, so that worker goroutines can sleep instead of polling.
+// It returns ctx.Err(), if ctx is done before an entry is available.
+func (s *Sync[K, V]) PopMaxWait(ctx context.Context) (K, V, error) {
+	e, err := s.popWait(ctx, s.q.PopMaxEntry)
+	return e.Key, e.Value, err
+}
+
+// PopFair removes and returns the entry with the highest value of the next non-empty priority band.
+// See CapQueue.PopFair for details.
+// This will panic if the queue is empty.
+func (s *Sync[K, V]) PopFair() (K, V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.PopFair()
+}
+
+// TryPopFair removes and returns the entry with the highest value of the next non-empty priority band.
+// In contrast to PopFair, it returns ErrEmpty instead of panicking if the queue is empty.
+func (s *Sync[K, V]) TryPopFair() (K, V, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.TryPopFair()
+}
+
+// Min returns the key-value pair with the lowest value.
+// This will panic if the queue is empty.
+func (s *Sync[K, V]) Min() (K, V) {
+	defer s.lockLookup(false)()
+	return s.q.Min()
+}
+
+// TryMin returns the key-value pair with the lowest value.
+// In contrast to Min, it returns ErrEmpty instead of panicking if the queue is empty.
+func (s *Sync[K, V]) TryMin() (K, V, error) {
+	defer s.lockLookup(false)()
+	return s.q.TryMin()
+}
+
+// First returns the oldest key-value pair.
+// This will panic if the queue is empty.
+func (s *Sync[K, V]) First() (K, V) {
+	defer s.lockLookup(false)()
+	return s.q.First()
+}
+
+// TryFirst returns the oldest key-value pair.
+// In contrast to First, it returns ErrEmpty instead of panicking if the queue is empty.
+func (s *Sync[K, V]) TryFirst() (K, V, error) {
+	defer s.lockLookup(false)()
+	return s.q.TryFirst()
+}
+
+// Last returns the newest key-value pair.
+// This will panic if the queue is empty.
+func (s *Sync[K, V]) Last() (K, V) {
+	defer s.lockLookup(false)()
+	return s.q.Last()
+}
+
+// TryLast returns the newest key-value pair.
+// In contrast to Last, it returns ErrEmpty instead of panicking if the queue is empty.
+func (s *Sync[K, V]) TryLast() (K, V, error) {
+	defer s.lockLookup(false)()
+	return s.q.TryLast()
+}
+
+// PopFirst removes and returns the oldest key-value pair.
+// This will panic if the queue is empty.
+func (s *Sync[K, V]) PopFirst() (K, V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.PopFirst()
+}
+
+// TryPopFirst removes and returns the oldest key-value pair.
+// In contrast to PopFirst, it returns ErrEmpty instead of panicking if the queue is empty.
+func (s *Sync[K, V]) TryPopFirst() (K, V, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.TryPopFirst()
+}
+
+// PeekEvictee returns the key-value pair that would be evicted by the next addition of a new key.
+// The last return value is false, if the queue is not full and nothing would be evicted.
+func (s *Sync[K, V]) PeekEvictee() (K, V, bool) {
+	defer s.lockLookup(false)()
+	return s.q.PeekEvictee()
+}
+
+// Entries returns a snapshot of all entries contained in the queue ordered by descending priority.
+func (s *Sync[K, V]) Entries() []Entry[K, V] {
+	defer s.lockLookup(false)()
+	return s.q.Entries()
+}
+
+// TopK returns up to k entries with the highest priority ordered by descending priority.
+// See CapQueue.TopK for details.
+func (s *Sync[K, V]) TopK(k int) []Entry[K, V] {
+	defer s.lockLookup(false)()
+	return s.q.TopK(k)
+}
+
+// PeekN returns up to n entries with the highest priority without removing them.
+// See CapQueue.PeekN for details.
+func (s *Sync[K, V]) PeekN(n int) []Entry[K, V] {
+	return s.TopK(n)
+}
+
+// KthMax returns the key-value pair with the k-th highest priority.
+// See CapQueue.KthMax for details.
+func (s *Sync[K, V]) KthMax(k int) (K, V, bool) {
+	defer s.lockLookup(false)()
+	return s.q.KthMax(k)
+}
+
+// CountAbove returns the number of entries with a value greater than v.
+// See CapQueue.CountAbove for details.
+func (s *Sync[K, V]) CountAbove(v V) int {
+	defer s.lockLookup(false)()
+	return s.q.CountAbove(v)
+}
+
+// AllAbove returns all entries with a value greater than v in unspecified order.
+// See CapQueue.AllAbove for details.
+func (s *Sync[K, V]) AllAbove(v V) []Entry[K, V] {
+	defer s.lockLookup(false)()
+	return s.q.AllAbove(v)
+}
+
+// Keys returns a snapshot of the keys of all entries contained in the queue ordered from oldest to newest.
+func (s *Sync[K, V]) Keys() []K {
+	defer s.lockLookup(false)()
+	return s.q.Keys()
+}
+
+// ForEach calls f for each entry contained in the queue, ordered from oldest to newest, until f returns false.
+// In contrast to CapQueue.ForEach, f is called on a snapshot of the entries without holding the lock, so that f may
+// call the methods of s. Mutations are therefore applied immediately, but they do not affect the iteration.
+func (s *Sync[K, V]) ForEach(f func(key K, value V) bool) {
+	unlock := s.lockLookup(false)
+	s.q.expire()
+	entries := s.q.entries()
+	unlock()
+	for _, e := range entries {
+		if !f(e.Key, e.Value) {
+			return
+		}
+	}
+}
+
+// OldestK returns up to k of the oldest entries, starting with the oldest one.
+func (s *Sync[K, V]) OldestK(k int) []Entry[K, V] {
+	defer s.lockLookup(false)()
+	return s.q.OldestK(k)
+}
+
+// NewestK returns up to k of the most recently added entries, starting with the newest one.
+func (s *Sync[K, V]) NewestK(k int) []Entry[K, V] {
+	defer s.lockLookup(false)()
+	return s.q.NewestK(k)
+}
+
+// ValuesBetween returns all entries with lo <= value <= hi ordered by ascending value.
+// See CapQueue.ValuesBetween for details.
+func (s *Sync[K, V]) ValuesBetween(lo, hi V) []Entry[K, V] {
+	defer s.lockLookup(false)()
+	return s.q.ValuesBetween(lo, hi)
+}
+
+// Median returns the median of the values of all entries.
+// See CapQueue.Median for details.
+func (s *Sync[K, V]) Median() (V, bool) {
+	defer s.lockLookup(false)()
+	return s.q.Median()
+}
+
+// Sample returns up to n entries chosen uniformly at random without removing them.
+func (s *Sync[K, V]) Sample(n int) []Entry[K, V] {
+	s.mu.Lock() // the random source is not safe for concurrent use
+	defer s.mu.Unlock()
+	return s.q.Sample(n)
+}
+
+// MaxHistory returns the recorded maxima ordered from oldest to newest.
+// It returns nil, unless the queue was created using the WithMaxHistory option.
+func (s *Sync[K, V]) MaxHistory() []MaxRecord[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.q.MaxHistory()
+}
+
+// DrainSorted removes all entries from the queue and returns them ordered by descending priority.
+// See CapQueue.DrainSorted for details.
+func (s *Sync[K, V]) DrainSorted() []Entry[K, V] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.DrainSorted()
+}
+
+// Clear removes all elements from the queue.
+// See CapQueue.Clear for details.
+func (s *Sync[K, V]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.q.Clear()
+}
+
+// ShrinkToFit releases memory that is no longer needed after entries have been removed.
+// See CapQueue.ShrinkToFit for details.
+func (s *Sync[K, V]) ShrinkToFit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.q.ShrinkToFit()
+}
+
+// Pending returns the number of entries whose heap fix-up has been deferred to subsequent operations.
+func (s *Sync[K, V]) Pending() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.q.Pending()
+}
+
+// Tombstones returns the number of deleted entries that have not yet been removed from the heap.
+func (s *Sync[K, V]) Tombstones() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.q.Tombstones()
+}
+
+// Compact removes all tombstones from the heap and completes all deferred heap fix-ups.
+func (s *Sync[K, V]) Compact() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.q.Compact()
+}
+
+// Clone returns an independent copy of the underlying queue, which is not safe for concurrent use.
+// See CapQueue.Clone for details.
+func (s *Sync[K, V]) Clone() *CapQueue[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.q.Clone()
+}
+
+// Export returns a snapshot of the capacity and the entries of the queue.
+func (s *Sync[K, V]) Export() Snapshot[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.q.Export()
+}
+
+// WriteTo writes the capacity and all entries of the queue to w using the CBOR encoding of a Snapshot.
+// The read lock is held until all entries have been written.
+func (s *Sync[K, V]) WriteTo(w io.Writer) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.q.WriteTo(w)
+}
+
+// ReadFrom reads a CBOR encoded Snapshot from r and adds its entries one by one to the queue.
+// See CapQueue.ReadFrom for details.
+func (s *Sync[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.ReadFrom(r)
+}
+
+// Snapshot returns a versioned and checksummed binary representation of the queue for persistence.
+// See CapQueue.Snapshot for details.
+func (s *Sync[K, V]) Snapshot() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.q.Snapshot()
+}
+
+// Stats returns statistics about the queue.
+func (s *Sync[K, V]) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.q.Stats()
+}
+
+// storeMax updates the cached maximum. It must be called while holding the write lock.
+func (s *Sync[K, V]) storeMax() {
+	if s.q.size() == 0 {
+		s.max.Store((*maxEntry[K, V])(nil))
+		return
+	}
+	s.nonEmpty.Broadcast()
+	it := s.q.top()
+	s.max.Store(&maxEntry[K, V]{key: it.key, value: it.value, tiebreak: it.tiebreak, expiresAt: it.expiresAt})
+}
+
+// loadMax returns the cached maximum or nil when empty. An expired maximum is removed from the queue first.
+func (s *Sync[K, V]) loadMax() *maxEntry[K, V] {
+	m := s.max.Load().(*maxEntry[K, V])
+	if m != nil && m.expired(time.Now()) {
+		s.Expire()
+		m = s.max.Load().(*maxEntry[K, V])
+	}
+	return m
+}
+
+// lockLookup acquires the read lock for a lookup and returns the function releasing it. The write lock is acquired
+// instead, if the lookup removes expired entries or, if access is set, records the access for the eviction policy.
+func (s *Sync[K, V]) lockLookup(access bool) func() {
+	s.mu.RLock()
+	if !s.q.expiring() && !(access && s.q.tracksAccess()) {
+		return s.mu.RUnlock
+	}
+	s.mu.RUnlock()
+	s.mu.Lock()
+	return s.mu.Unlock
+}
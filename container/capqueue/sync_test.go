@@ -0,0 +1,157 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestSync_Max(t *testing.T) {
+	q := NewSync[string, int](testCapacity)
+	assert.Panics(t, func() { _, _ = q.Max() })
+
+	q.Add("1", 1)
+	q.Add("2", 2)
+	maxKey, maxValue := q.Max()
+	assert.Equal(t, "2", maxKey)
+	assert.Equal(t, 2, maxValue)
+
+	assert.True(t, q.Delete("2"))
+	maxKey, maxValue = q.Max()
+	assert.Equal(t, "1", maxKey)
+	assert.Equal(t, 1, maxValue)
+
+	value, ok := q.Remove("1")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	_, _, err := q.TryMax()
+	assert.Equal(t, ErrEmpty, err)
+}
+
+func TestSync_Parallel(t *testing.T) {
+	const parallelism = 4
+
+	q := NewSync[string, int](testCapacity)
+	var wg sync.WaitGroup
+	wg.Add(2 * parallelism)
+	for i := 0; i < parallelism; i++ {
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				q.Add(fmt.Sprint(i, j), j)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if key, value, err := q.TryMax(); err == nil {
+					assert.NotEmpty(t, key)
+					assert.GreaterOrEqual(t, value, 0)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, testCapacity, q.Len())
+	_, maxValue := q.Max()
+	assert.Equal(t, 99, maxValue)
+}
+
+func TestSync_Mutations(t *testing.T) {
+	q := NewSync[string, int](testCapacity)
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+
+	// every mutation keeps the cached maximum up to date
+	key, value := q.PopMax()
+	assert.Equal(t, fmt.Sprint(testCapacity), key)
+	assert.Equal(t, testCapacity, value)
+	assert.True(t, q.Update("1", 2*testCapacity))
+	key, _ = q.Max()
+	assert.Equal(t, "1", key)
+	key, _ = q.PopFirst()
+	assert.Equal(t, "1", key)
+	key, _ = q.Max()
+	assert.Equal(t, fmt.Sprint(testCapacity-1), key)
+
+	q.SetCap(2)
+	assert.Equal(t, 2, q.Cap())
+	assert.Equal(t, []string{fmt.Sprint(testCapacity - 2), fmt.Sprint(testCapacity - 1)}, q.Keys())
+	key, _, ok := q.AddEvict("new", 0)
+	assert.True(t, ok)
+	assert.Equal(t, fmt.Sprint(testCapacity-2), key)
+	key, _ = q.Min()
+	assert.Equal(t, "new", key)
+
+	q.Clear()
+	assert.Zero(t, q.Len())
+	_, _, err := q.TryMax()
+	assert.Equal(t, ErrEmpty, err)
+}
+
+func TestSync_ForEach(t *testing.T) {
+	q := NewSync[string, int](testCapacity)
+	for i := 1; i <= testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+
+	// f may call the methods of the queue
+	var n int
+	q.ForEach(func(key string, value int) bool {
+		assert.True(t, q.Contains(key))
+		q.Delete(key)
+		n++
+		return true
+	})
+	assert.Equal(t, testCapacity, n)
+	assert.Zero(t, q.Len())
+}
+
+func TestSync_ParallelReadWrite(t *testing.T) {
+	const parallelism = 4
+
+	q := NewSync[string, int](testCapacity, WithValueIndex[string, int]())
+	var wg sync.WaitGroup
+	wg.Add(2 * parallelism)
+	for i := 0; i < parallelism; i++ {
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprint(i, j%testCapacity)
+				q.Add(key, j)
+				q.Update(key, -j)
+				_, _, _ = q.TryPopMax()
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _, _ = q.TryMin()
+				_ = q.ValuesBetween(0, testCapacity)
+				_ = q.Entries()
+				_ = q.Sample(2)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, q.Len(), testCapacity)
+}
+
+func BenchmarkSync_Max(b *testing.B) {
+	q := NewSync[string, int](testCapacity)
+	for i := 0; i < testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = q.Max()
+		}
+	})
+}
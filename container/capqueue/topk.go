@@ -0,0 +1,177 @@
+package capqueue
+
+import (
+	"container/heap"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// TopK returns up to k entries with the highest priority, ordered by descending priority like Entries.
+// In contrast to Entries, only the top of the heap is traversed using an auxiliary heap, which takes O(k log k) time.
+// If the queue has deferred heap fix-ups, see WithFixupBudget, all entries are sorted partially in O(n + k log n).
+func (h *CapQueue[K, V]) TopK(k int) []Entry[K, V] {
+	h.expire()
+	if k > h.size() {
+		k = h.size()
+	}
+	if k <= 0 {
+		return nil
+	}
+	entries := make([]Entry[K, V], 0, k)
+	if len(h.dirty) > 0 {
+		// the heap ordering does not hold for dirty items
+		eh := h.newEntryHeap()
+		for len(entries) < k {
+			entries = append(entries, heap.Pop(eh).(Entry[K, V]))
+		}
+		return entries
+	}
+	d := h.descend(k)
+	for len(entries) < k {
+		entries = append(entries, d.next().entry())
+	}
+	return entries
+}
+
+// PeekN returns up to n entries with the highest priority without removing them, like n calls of PopMax would.
+// It is the same as TopK.
+func (h *CapQueue[K, V]) PeekN(n int) []Entry[K, V] {
+	return h.TopK(n)
+}
+
+// KthMax returns the key-value pair with the k-th highest priority, where k = 1 refers to the maximum, in
+// O(k log k) time like TopK, but without collecting the entries of higher priority.
+// The last return value is false, if k is not positive or larger than the number of entries.
+func (h *CapQueue[K, V]) KthMax(k int) (K, V, bool) {
+	h.expire()
+	if k < 1 || k > h.size() {
+		var key K
+		var value V
+		return key, value, false
+	}
+	if len(h.dirty) > 0 {
+		eh := h.newEntryHeap()
+		for i := 1; i < k; i++ {
+			heap.Pop(eh)
+		}
+		e := eh.entries[0]
+		return e.Key, e.Value, true
+	}
+	d := h.descend(k)
+	for i := 1; i < k; i++ {
+		d.next()
+	}
+	it := d.next()
+	return it.key, it.value, true
+}
+
+// CountAbove returns the number of entries with a value greater than v.
+// Only the subtrees of the heap whose roots have a value greater than v are traversed, which takes O(m) time for m
+// matching entries. With a custom ordering, see WithLess and WithMinOrder, or deferred heap fix-ups, all entries are
+// scanned in O(n).
+func (h *CapQueue[K, V]) CountAbove(v V) int {
+	h.expire()
+	n := 0
+	h.above(v, func(*item[K, V]) { n++ })
+	return n
+}
+
+// AllAbove returns all entries with a value greater than v in unspecified order.
+// See CountAbove for the time complexity.
+func (h *CapQueue[K, V]) AllAbove(v V) []Entry[K, V] {
+	h.expire()
+	var entries []Entry[K, V]
+	h.above(v, func(it *item[K, V]) { entries = append(entries, it.entry()) })
+	return entries
+}
+
+// above calls f for all items with a value greater than v.
+func (h *CapQueue[K, V]) above(v V, f func(*item[K, V])) {
+	if h.less != nil || len(h.dirty) > 0 {
+		// the heap is not ordered by value or the ordering does not hold for dirty items
+		for _, it := range h.heap {
+			if !it.deleted && it.value > v {
+				f(it)
+			}
+		}
+		return
+	}
+
+	// the value of an item is never less than the values of its descendants
+	var stack []int
+	if len(h.heap) > 0 {
+		stack = append(stack, 0)
+	}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		it := h.heap[i]
+		if !(it.value > v) {
+			continue
+		}
+		if !it.deleted {
+			f(it)
+		}
+		lo, hi := h.children(i)
+		for c := lo; c < hi; c++ {
+			stack = append(stack, c)
+		}
+	}
+}
+
+// descending iterates the items of a valid heap in descending order of priority by visiting only the top of the heap.
+// The frontier contains the heap indices of the items whose parents have already been returned, so the next item is
+// always the highest item of the frontier.
+type descending[K comparable, V ordering.Ordered] struct {
+	q        *CapQueue[K, V]
+	frontier []int
+}
+
+// descend returns an iterator over the heap, which preallocates space for visiting n items.
+func (h *CapQueue[K, V]) descend(n int) *descending[K, V] {
+	d := &descending[K, V]{q: h, frontier: make([]int, 0, n+1)}
+	if len(h.heap) > 0 {
+		d.frontier = append(d.frontier, 0)
+	}
+	return d
+}
+
+// next returns the item with the next lower priority or nil if all items have been returned.
+// Tombstones are skipped, but their children are visited.
+func (d *descending[K, V]) next() *item[K, V] {
+	for len(d.frontier) > 0 {
+		i := heap.Pop(d).(int)
+		lo, hi := d.q.children(i)
+		for c := lo; c < hi; c++ {
+			heap.Push(d, c)
+		}
+		if it := d.q.heap[i]; !it.deleted {
+			return it
+		}
+	}
+	return nil
+}
+
+func (d *descending[K, V]) Len() int {
+	return len(d.frontier)
+}
+
+func (d *descending[K, V]) Less(i, j int) bool {
+	return d.q.higher(d.q.heap[d.frontier[i]], d.q.heap[d.frontier[j]])
+}
+
+func (d *descending[K, V]) Swap(i, j int) {
+	d.frontier[i], d.frontier[j] = d.frontier[j], d.frontier[i]
+}
+
+func (d *descending[K, V]) Push(x interface{}) {
+	d.frontier = append(d.frontier, x.(int))
+}
+
+func (d *descending[K, V]) Pop() interface{} {
+	old := d.frontier
+	n := len(old)
+	i := old[n-1]
+	d.frontier = old[:n-1]
+	return i
+}
@@ -0,0 +1,131 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+	"github.com/wollac/pkg/container/ordering"
+)
+
+func TestCapQueue_TopK(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		opts []Option[string, int]
+	}{
+		{"default", nil},
+		{"lazy deletion", []Option[string, int]{WithLazyDeletion[string, int]()}},
+		{"fix-up budget", []Option[string, int]{WithFixupBudget[string, int](1)}},
+		{"stable order", []Option[string, int]{WithStableOrder[string, int]()}},
+		{"min order", []Option[string, int]{WithMinOrder[string, int]()}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			q := New[string, int](testCapacity*10, test.opts...)
+			for i := 0; i < testCapacity*20; i++ {
+				q.Add(fmt.Sprint(rand.Intn(testCapacity*20)), rand.Intn(testCapacity))
+				if i%3 == 0 {
+					q.Delete(fmt.Sprint(rand.Intn(testCapacity * 20)))
+				}
+			}
+
+			assert.Nil(t, q.TopK(0))
+			entries := q.Entries()
+			top := q.TopK(testCapacity)
+			// entries with the same priority are returned in unspecified order
+			assert.Equal(t, entryValues(entries[:testCapacity]), entryValues(top))
+			assert.Subset(t, entries, top)
+			assert.ElementsMatch(t, entries, q.TopK(q.Len()+1))
+			if test.name == "stable order" {
+				assert.Equal(t, entries[:testCapacity], top)
+			}
+		})
+	}
+}
+
+func TestSharded_TopK(t *testing.T) {
+	q := NewSharded[string, int](4, testCapacity)
+	for i := 0; i < 2*testCapacity; i++ {
+		q.Add(fmt.Sprint(i), i)
+	}
+	assert.Equal(t, q.Entries()[:3], q.TopK(3))
+	assert.Len(t, q.TopK(10*testCapacity), 2*testCapacity)
+}
+
+func entryValues[K comparable, V ordering.Ordered](entries []Entry[K, V]) []V {
+	values := make([]V, len(entries))
+	for i, e := range entries {
+		values[i] = e.Value
+	}
+	return values
+}
+
+func TestCapQueue_KthMax(t *testing.T) {
+	for _, opts := range [][]Option[int, int]{nil, {WithLazyDeletion[int, int]()}, {WithFixupBudget[int, int](1)}} {
+		q := New[int, int](testCapacity, opts...)
+		for i := 0; i < testCapacity; i++ {
+			q.Add(i, (i*7)%testCapacity)
+		}
+		q.Delete(0)
+
+		for k := 1; k < testCapacity; k++ {
+			_, value, ok := q.KthMax(k)
+			assert.True(t, ok)
+			assert.Equal(t, testCapacity-k, value)
+		}
+		_, _, ok := q.KthMax(0)
+		assert.False(t, ok)
+		_, _, ok = q.KthMax(testCapacity)
+		assert.False(t, ok)
+	}
+}
+
+func TestSharded_KthMax(t *testing.T) {
+	q := NewSharded[int, int](4, testCapacity)
+	for i := 0; i < 2*testCapacity; i++ {
+		q.Add(i, i)
+	}
+	key, _, ok := q.KthMax(3)
+	assert.True(t, ok)
+	assert.Equal(t, 2*testCapacity-3, key)
+	_, _, ok = q.KthMax(2*testCapacity + 1)
+	assert.False(t, ok)
+}
+
+func TestCapQueue_CountAbove(t *testing.T) {
+	for _, opts := range [][]Option[int, int]{nil, {WithLazyDeletion[int, int]()}, {WithFixupBudget[int, int](1)}, {WithMinOrder[int, int]()}} {
+		q := New[int, int](testCapacity*10, opts...)
+		for i := 0; i < testCapacity*10; i++ {
+			q.Add(i, rand.Intn(testCapacity))
+		}
+		for i := 0; i < testCapacity; i++ {
+			q.Delete(rand.Intn(testCapacity * 10))
+		}
+
+		for v := -1; v <= testCapacity; v++ {
+			var expected []Entry[int, int]
+			for _, e := range q.Entries() {
+				if e.Value > v {
+					expected = append(expected, e)
+				}
+			}
+			assert.Equal(t, len(expected), q.CountAbove(v))
+			assert.ElementsMatch(t, expected, q.AllAbove(v))
+		}
+	}
+}
+
+func TestCapQueue_PeekN(t *testing.T) {
+	q := New[int, int](testCapacity, WithStableOrder[int, int]())
+	for i := 0; i < testCapacity; i++ {
+		q.Add(i, i%3)
+	}
+	peeked := q.PeekN(4)
+	assert.Equal(t, testCapacity, q.Len())
+	for _, e := range peeked {
+		key, value := q.PopMax()
+		assert.Equal(t, e.Key, key)
+		assert.Equal(t, e.Value, value)
+	}
+}
@@ -0,0 +1,136 @@
+package capqueue
+
+import (
+	"context"
+	"time"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// AddWithTTL adds a new key-value pair to the queue like Add, which expires after the given duration.
+// Expired entries are removed lazily by additions and by all methods reading the entries, like Len, Max, Get, First
+// or Entries, or explicitly using Expire. Until then, they are still counted by Stats and included by Export and the
+// other encodings of the queue.
+// This will panic if ttl is not positive or if the key is longer than the maximum key length of a preallocated queue.
+func (h *CapQueue[K, V]) AddWithTTL(key K, value V, ttl time.Duration) {
+	if ttl <= 0 {
+		panic("non-positive TTL")
+	}
+	now := time.Now()
+	if err := h.add(Entry[K, V]{Key: key, Value: value, AddedAt: now, ExpiresAt: now.Add(ttl)}); err != nil {
+		panic(err)
+	}
+}
+
+// Expire removes all expired entries from the queue in O(k log n) and returns their number.
+// While a batch is active, no entries are removed.
+func (h *CapQueue[K, V]) Expire() int {
+	return h.expire()
+}
+
+// StartSweeper starts a goroutine that removes all expired entries every interval until ctx is done.
+// This keeps Len and the memory usage accurate when the queue is idle, without relying on the lazy expiry.
+// This will panic if interval is not positive.
+func (s *Sync[K, V]) StartSweeper(ctx context.Context, interval time.Duration) {
+	sweepEvery(ctx, interval, s.sweep)
+}
+
+// StartSweeper starts a goroutine that removes all expired entries from all shards every interval until ctx is done.
+// See Sync.StartSweeper for details.
+func (s *Sharded[K, V]) StartSweeper(ctx context.Context, interval time.Duration) {
+	sweepEvery(ctx, interval, func() {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		for _, q := range s.shards {
+			q.sweep()
+		}
+	})
+}
+
+// sweep removes all expired entries. The write lock is only acquired if there are any.
+func (s *Sync[K, V]) sweep() {
+	s.mu.RLock()
+	expired := s.q.hasExpired(time.Now())
+	s.mu.RUnlock()
+	if expired {
+		s.Expire()
+	}
+}
+
+// sweepEvery calls sweep every interval in a new goroutine until ctx is done.
+func sweepEvery(ctx context.Context, interval time.Duration, sweep func()) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				sweep()
+			}
+		}
+	}()
+}
+
+// expire removes all expired entries, if the queue contains any entries with an expiry time.
+func (h *CapQueue[K, V]) expire() int {
+	if len(h.expiries) == 0 || h.batchDepth > 0 {
+		return 0
+	}
+	now := time.Now()
+	n := 0
+	for h.hasExpired(now) {
+		h.remove(h.expiries[0])
+		h.expirations++
+		n++
+	}
+	return n
+}
+
+// hasExpired returns whether the queue contains entries that have expired at the given time.
+func (h *CapQueue[K, V]) hasExpired(now time.Time) bool {
+	return len(h.expiries) > 0 && h.expiries[0].expired(now)
+}
+
+// expired returns whether the item has expired at the given time.
+func (it *item[K, V]) expired(now time.Time) bool {
+	return !it.expiresAt.IsZero() && !it.expiresAt.After(now)
+}
+
+// expiring returns whether the queue contains entries that are removed lazily once they have expired.
+func (h *CapQueue[K, V]) expiring() bool {
+	return len(h.expiries) > 0
+}
+
+// expiryHeap is a min-heap of items ordered by their expiry time.
+type expiryHeap[K comparable, V ordering.Ordered] []*item[K, V]
+
+func (h expiryHeap[K, V]) Len() int {
+	return len(h)
+}
+
+func (h expiryHeap[K, V]) Less(i, j int) bool {
+	return h[i].expiresAt.Before(h[j].expiresAt)
+}
+
+func (h expiryHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].expiryIndex = i
+	h[j].expiryIndex = j
+}
+
+func (h *expiryHeap[K, V]) Push(x interface{}) {
+	it := x.(*item[K, V])
+	it.expiryIndex = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *expiryHeap[K, V]) Pop() interface{} {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil // avoid memory leak
+	*h = old[:n-1]
+	return it
+}
@@ -0,0 +1,215 @@
+package capqueue_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+const testTTL = 20 * time.Millisecond
+
+func TestCapQueue_AddWithTTL(t *testing.T) {
+	q := New[string, int](testCapacity)
+	q.Add("a", 1)
+	q.AddWithTTL("b", 3, testTTL)
+	q.AddWithTTL("c", 2, time.Hour)
+	assert.Equal(t, 3, q.Len())
+	key, _ := q.Max()
+	assert.Equal(t, "b", key)
+
+	time.Sleep(testTTL)
+	assert.Equal(t, 2, q.Len())
+	_, ok := q.Get("b")
+	assert.False(t, ok)
+	key, _ = q.Max()
+	assert.Equal(t, "c", key)
+	assert.EqualValues(t, 1, q.Stats().Expirations)
+
+	// adding without TTL removes the expiry
+	q.Add("c", 2)
+	q.AddWithTTL("a", 1, testTTL)
+	time.Sleep(testTTL)
+	assert.Equal(t, 1, q.Len())
+	assert.Equal(t, []string{"c"}, q.Keys())
+	assert.Equal(t, 0, q.Expire())
+
+	assert.Panics(t, func() { q.AddWithTTL("d", 0, 0) })
+}
+
+func TestCapQueue_AddWithTTLFull(t *testing.T) {
+	q := New[int, int](testCapacity)
+	q.Add(0, 0)
+	for i := 1; i < testCapacity; i++ {
+		q.AddWithTTL(i, i, testTTL)
+	}
+	c := q.Clone()
+
+	// expired entries are removed before any entry gets evicted
+	time.Sleep(testTTL)
+	q.Add(testCapacity, testCapacity)
+	assert.Equal(t, []int{0, testCapacity}, q.Keys())
+	assert.Zero(t, q.Stats().Evictions)
+
+	// the clone keeps the expiry times
+	assert.Equal(t, testCapacity-1, c.Expire())
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestCapQueue_AddWithTTLAccessors(t *testing.T) {
+	// the live entries a and b are surrounded by entries that expire
+	newQueue := func() *CapQueue[string, int] {
+		q := New[string, int](5)
+		q.AddWithTTL("old", -1, testTTL)
+		q.Add("a", 1)
+		q.Add("b", 2)
+		q.AddWithTTL("new", 10, testTTL)
+		q.AddWithTTL("newest", 11, testTTL)
+		return q
+	}
+	keys := func(entries []Entry[string, int]) []string {
+		var keys []string
+		for _, e := range entries {
+			keys = append(keys, e.Key)
+		}
+		return keys
+	}
+
+	tests := map[string]func(*testing.T, *CapQueue[string, int]){
+		"ValueBytes": func(t *testing.T, q *CapQueue[string, int]) {
+			assert.Zero(t, ValueBytes(q, []byte("old")))
+		},
+		"TryMin": func(t *testing.T, q *CapQueue[string, int]) {
+			key, _, err := q.TryMin()
+			require.NoError(t, err)
+			assert.Equal(t, "a", key)
+		},
+		"TryFirst": func(t *testing.T, q *CapQueue[string, int]) {
+			key, _, err := q.TryFirst()
+			require.NoError(t, err)
+			assert.Equal(t, "a", key)
+		},
+		"TryLast": func(t *testing.T, q *CapQueue[string, int]) {
+			key, _, err := q.TryLast()
+			require.NoError(t, err)
+			assert.Equal(t, "b", key)
+		},
+		"TryPopFirst": func(t *testing.T, q *CapQueue[string, int]) {
+			key, _, err := q.TryPopFirst()
+			require.NoError(t, err)
+			assert.Equal(t, "a", key)
+		},
+		"TryPopFair": func(t *testing.T, q *CapQueue[string, int]) {
+			key, _, err := q.TryPopFair()
+			require.NoError(t, err)
+			assert.Equal(t, "b", key)
+		},
+		"PeekEvictee": func(t *testing.T, q *CapQueue[string, int]) {
+			_, _, ok := q.PeekEvictee()
+			assert.False(t, ok)
+		},
+		"Entries": func(t *testing.T, q *CapQueue[string, int]) {
+			assert.Equal(t, []string{"b", "a"}, keys(q.Entries()))
+		},
+		"Keys": func(t *testing.T, q *CapQueue[string, int]) {
+			assert.Equal(t, []string{"a", "b"}, q.Keys())
+		},
+		"ForEach": func(t *testing.T, q *CapQueue[string, int]) {
+			var keys []string
+			q.ForEach(func(key string, _ int) bool {
+				keys = append(keys, key)
+				return true
+			})
+			assert.Equal(t, []string{"a", "b"}, keys)
+		},
+		"OldestK": func(t *testing.T, q *CapQueue[string, int]) {
+			assert.Equal(t, []string{"a", "b"}, keys(q.OldestK(5)))
+		},
+		"NewestK": func(t *testing.T, q *CapQueue[string, int]) {
+			assert.Equal(t, []string{"b", "a"}, keys(q.NewestK(5)))
+		},
+		"TopK": func(t *testing.T, q *CapQueue[string, int]) {
+			assert.Equal(t, []string{"b", "a"}, keys(q.TopK(5)))
+		},
+		"KthMax": func(t *testing.T, q *CapQueue[string, int]) {
+			key, _, ok := q.KthMax(1)
+			require.True(t, ok)
+			assert.Equal(t, "b", key)
+		},
+		"CountAbove": func(t *testing.T, q *CapQueue[string, int]) {
+			assert.Equal(t, 2, q.CountAbove(0))
+		},
+		"AllAbove": func(t *testing.T, q *CapQueue[string, int]) {
+			assert.ElementsMatch(t, []string{"a", "b"}, keys(q.AllAbove(0)))
+		},
+		"ValuesBetween": func(t *testing.T, q *CapQueue[string, int]) {
+			assert.Equal(t, []string{"a", "b"}, keys(q.ValuesBetween(-10, 20)))
+		},
+		"Median": func(t *testing.T, q *CapQueue[string, int]) {
+			median, ok := q.Median()
+			require.True(t, ok)
+			assert.Equal(t, 1, median)
+		},
+		"Sample": func(t *testing.T, q *CapQueue[string, int]) {
+			assert.ElementsMatch(t, []string{"a", "b"}, keys(q.Sample(5)))
+		},
+	}
+	queues := make(map[string]*CapQueue[string, int], len(tests))
+	for name := range tests {
+		queues[name] = newQueue()
+	}
+	time.Sleep(testTTL)
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, queues[name])
+		})
+	}
+}
+
+func TestSync_AddWithTTL(t *testing.T) {
+	q := NewSync[string, int](testCapacity)
+	q.Add("a", 1)
+	q.AddWithTTL("b", 2, testTTL)
+	key, _ := q.Max()
+	require.Equal(t, "b", key)
+
+	time.Sleep(testTTL)
+	key, _ = q.Max()
+	assert.Equal(t, "a", key)
+	assert.Equal(t, 1, q.Len())
+	assert.False(t, q.Contains("b"))
+
+	// all read accessors remove the expired entries
+	q.AddWithTTL("c", 0, testTTL)
+	time.Sleep(testTTL)
+	key, _ = q.Last()
+	assert.Equal(t, "a", key)
+}
+
+func TestSync_StartSweeper(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := NewSync[string, int](testCapacity)
+	q.StartSweeper(ctx, testTTL/4)
+	q.Add("a", 1)
+	q.AddWithTTL("b", 2, testTTL)
+	assert.Eventually(t, func() bool { return q.Stats().Expirations == 1 }, time.Second, testTTL/4)
+	assert.Equal(t, []string{"a"}, q.Keys())
+}
+
+func TestSharded_StartSweeper(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := NewSharded[string, int](4, testCapacity)
+	q.StartSweeper(ctx, testTTL/4)
+	for i := 0; i < testCapacity; i++ {
+		q.AddWithTTL(fmt.Sprint(i), i, testTTL)
+	}
+	assert.Eventually(t, func() bool { return len(q.Entries()) == 0 }, time.Second, testTTL/4)
+}
@@ -0,0 +1,141 @@
+package capqueue
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// valueIndex is an ordered index over the values of the items.
+// It is implemented as a treap, which is a binary search tree ordered by (value, tiebreak, seq) and a heap ordered
+// by random priorities, providing O(log n) insertion and removal in expectation.
+type valueIndex[K comparable, V ordering.Ordered] struct {
+	root *valueNode[K, V]
+	rand *rand.Rand
+}
+
+// valueNode represents the node of an item in the valueIndex.
+type valueNode[K comparable, V ordering.Ordered] struct {
+	it          *item[K, V]
+	prio        uint32
+	left, right *valueNode[K, V]
+}
+
+func newValueIndex[K comparable, V ordering.Ordered](seed int64) *valueIndex[K, V] {
+	return &valueIndex[K, V]{rand: rand.New(rand.NewSource(seed))}
+}
+
+// ValuesBetween returns all entries with lo <= value <= hi ordered by ascending value.
+// Entries with equal values are ordered by tiebreak and then from oldest to newest. With WithValueIndex, this takes
+// O(log n + k) time for k returned entries, otherwise all entries are scanned and sorted.
+func (h *CapQueue[K, V]) ValuesBetween(lo, hi V) []Entry[K, V] {
+	h.expire()
+	var entries []Entry[K, V]
+	if h.values == nil {
+		for it := h.order.front(); it != nil; it = h.order.next(it) {
+			if lo <= it.value && it.value <= hi {
+				entries = append(entries, it.entry())
+			}
+		}
+		// the entries are ordered from oldest to newest, so a stable sort keeps that order for equal priorities
+		sort.SliceStable(entries, func(i, j int) bool {
+			a, b := entries[i], entries[j]
+			return valueLess(a.Value, a.Tiebreak, 0, b.Value, b.Tiebreak, 0)
+		})
+		return entries
+	}
+	h.values.between(h.values.root, lo, hi, func(it *item[K, V]) {
+		entries = append(entries, it.entry())
+	})
+	return entries
+}
+
+// between calls f for all items in the subtree of n with lo <= value <= hi in ascending order.
+func (x *valueIndex[K, V]) between(n *valueNode[K, V], lo, hi V, f func(*item[K, V])) {
+	for n != nil {
+		switch {
+		case n.it.value < lo:
+			n = n.right
+		case n.it.value > hi:
+			n = n.left
+		default:
+			x.between(n.left, lo, hi, f)
+			f(n.it)
+			n = n.right
+		}
+	}
+}
+
+func (x *valueIndex[K, V]) add(it *item[K, V]) {
+	it.node = &valueNode[K, V]{it: it, prio: x.rand.Uint32()}
+	l, r := splitValues(x.root, it)
+	x.root = mergeValues(mergeValues(l, it.node), r)
+}
+
+func (x *valueIndex[K, V]) remove(it *item[K, V]) {
+	x.root = removeValue(x.root, it)
+	it.node = nil
+}
+
+// valueLess returns whether the first item precedes the second in the valueIndex.
+func valueLess[V ordering.Ordered](v1 V, t1 int, s1 uint64, v2 V, t2 int, s2 uint64) bool {
+	if v1 != v2 {
+		return v1 < v2
+	}
+	if t1 != t2 {
+		return t1 < t2
+	}
+	return s1 < s2
+}
+
+// precedes returns whether the item a precedes the item b in the valueIndex.
+func precedes[K comparable, V ordering.Ordered](a, b *item[K, V]) bool {
+	return valueLess(a.value, a.tiebreak, a.seq, b.value, b.tiebreak, b.seq)
+}
+
+// splitValues splits the treap into the nodes preceding it and all other nodes.
+func splitValues[K comparable, V ordering.Ordered](n *valueNode[K, V], it *item[K, V]) (*valueNode[K, V], *valueNode[K, V]) {
+	if n == nil {
+		return nil, nil
+	}
+	if precedes(n.it, it) {
+		l, r := splitValues(n.right, it)
+		n.right = l
+		return n, r
+	}
+	l, r := splitValues(n.left, it)
+	n.left = r
+	return l, n
+}
+
+// mergeValues merges two treaps, where all nodes of l precede the nodes of r.
+func mergeValues[K comparable, V ordering.Ordered](l, r *valueNode[K, V]) *valueNode[K, V] {
+	switch {
+	case l == nil:
+		return r
+	case r == nil:
+		return l
+	case l.prio > r.prio:
+		l.right = mergeValues(l.right, r)
+		return l
+	default:
+		r.left = mergeValues(l, r.left)
+		return r
+	}
+}
+
+// removeValue removes the node of the given item from the treap.
+func removeValue[K comparable, V ordering.Ordered](n *valueNode[K, V], it *item[K, V]) *valueNode[K, V] {
+	switch {
+	case n == nil:
+		return nil
+	case n.it == it:
+		return mergeValues(n.left, n.right)
+	case precedes(it, n.it):
+		n.left = removeValue(n.left, it)
+	default:
+		n.right = removeValue(n.right, it)
+	}
+	return n
+}
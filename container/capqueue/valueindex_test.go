@@ -0,0 +1,61 @@
+package capqueue_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestCapQueue_ValuesBetween(t *testing.T) {
+	for _, opts := range [][]Option[string, int]{nil, {WithValueIndex[string, int]()}} {
+		q := New[string, int](testCapacity, opts...)
+		for i := 0; i < testCapacity; i++ {
+			q.Add(fmt.Sprint(i), i/2)
+		}
+		assert.Empty(t, q.ValuesBetween(testCapacity, 2*testCapacity))
+
+		var keys []string
+		for _, e := range q.ValuesBetween(1, 2) {
+			keys = append(keys, e.Key)
+		}
+		assert.Equal(t, []string{"2", "3", "4", "5"}, keys)
+	}
+}
+
+func TestWithValueIndex(t *testing.T) {
+	q := New[string, int](testCapacity, WithValueIndex[string, int]())
+	ref := New[string, int](testCapacity)
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprint(r.Intn(2 * testCapacity))
+		switch r.Intn(3) {
+		case 0:
+			q.Delete(key)
+			ref.Delete(key)
+		case 1:
+			m := map[string]int{key: r.Intn(100)}
+			q.MergeMap(m)
+			ref.MergeMap(m)
+		default:
+			if q.Value(key) == 0 {
+				value := r.Intn(100)
+				q.Add(key, value)
+				ref.Add(key, value)
+			}
+		}
+		lo := r.Intn(100)
+		assert.Equal(t, keyValues(ref.ValuesBetween(lo, lo+20)), keyValues(q.ValuesBetween(lo, lo+20)))
+	}
+}
+
+// keyValues returns the keys and values of the given entries.
+func keyValues(entries []Entry[string, int]) []Entry[string, int] {
+	kvs := make([]Entry[string, int], len(entries))
+	for i, e := range entries {
+		kvs[i] = Entry[string, int]{Key: e.Key, Value: e.Value}
+	}
+	return kvs
+}
@@ -0,0 +1,64 @@
+package capqueue
+
+import (
+	"github.com/wollac/pkg/container/ordering"
+)
+
+// NewWeighted creates a new CapQueue whose capacity is measured as the total cost of its entries instead of their
+// number. The cost of every entry is determined by calling cost when the entry is added or its value changes.
+// Whenever the total cost exceeds maxCost, entries are evicted according to the eviction policy, oldest first by
+// default, until the budget is satisfied again. Entries that cost more than maxCost on their own are rejected and
+// counted in Stats.Rejections.
+// The number of entries is not limited, unless a capacity is set using SetCap.
+// This will panic if maxCost is not positive or if cost returns a negative value.
+func NewWeighted[K comparable, V ordering.Ordered](maxCost int, cost func(key K, value V) int, opts ...Option[K, V]) *CapQueue[K, V] {
+	if maxCost <= 0 {
+		panic("non-positive maximum cost")
+	}
+	h := New[K, V](0, opts...)
+	h.maxCost = maxCost
+	h.costFn = cost
+	return h
+}
+
+// Cost returns the total cost of all entries contained in the queue.
+// This is always 0, unless the queue was created using NewWeighted.
+func (h *CapQueue[K, V]) Cost() int {
+	return h.totalCost
+}
+
+// MaxCost returns the maximum total cost of the entries or 0 if the queue was not created using NewWeighted.
+func (h *CapQueue[K, V]) MaxCost() int {
+	return h.maxCost
+}
+
+// costOf returns the cost of the given entry.
+func (h *CapQueue[K, V]) costOf(e Entry[K, V]) int {
+	if h.costFn == nil {
+		return 0
+	}
+	cost := h.costFn(e.Key, e.Value)
+	if cost < 0 {
+		panic("negative cost")
+	}
+	return cost
+}
+
+// exceedsCost returns whether adding an entry with the given cost requires an eviction.
+func (h *CapQueue[K, V]) exceedsCost(cost int) bool {
+	return h.costFn != nil && h.size() > 0 && h.totalCost+cost > h.maxCost
+}
+
+// fitCost evicts entries until an additional entry with the given cost fits into the budget.
+// It returns the entry that was evicted first, if any.
+func (h *CapQueue[K, V]) fitCost(cost int) (evicted Entry[K, V], ok bool) {
+	for h.exceedsCost(cost) {
+		it := h.victim()
+		if !ok {
+			evicted, ok = it.entry(), true
+		}
+		h.evicted(it)
+		h.remove(it)
+	}
+	return evicted, ok
+}
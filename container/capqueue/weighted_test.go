@@ -0,0 +1,60 @@
+package capqueue_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/capqueue"
+)
+
+func TestNewWeighted(t *testing.T) {
+	q := NewWeighted[string, int](10, func(key string, _ int) int { return len(key) })
+	q.Add("aaa", 1)
+	q.Add("bbbb", 2)
+	q.Add("cc", 3)
+	assert.Equal(t, 9, q.Cost())
+	assert.Equal(t, 10, q.MaxCost())
+
+	// the two oldest entries are evicted to make room
+	key, _, ok := q.AddEvict("dddddd", 4)
+	assert.True(t, ok)
+	assert.Equal(t, "aaa", key)
+	assert.Equal(t, []string{"cc", "dddddd"}, q.Keys())
+	assert.Equal(t, 8, q.Cost())
+	assert.EqualValues(t, 2, q.Stats().Evictions)
+
+	// entries exceeding the budget on their own are rejected
+	q.Add("eeeeeeeeeee", 5)
+	assert.False(t, q.Contains("eeeeeeeeeee"))
+	assert.EqualValues(t, 1, q.Stats().Rejections)
+
+	q.Delete("dddddd")
+	assert.Equal(t, 2, q.Cost())
+	q.Clear()
+	assert.Zero(t, q.Cost())
+
+	assert.Panics(t, func() { NewWeighted[string, int](0, func(string, int) int { return 1 }) })
+}
+
+func TestNewWeightedValueCost(t *testing.T) {
+	q := NewWeighted[string, int](10, func(_ string, value int) int { return value })
+	q.Add("a", 2)
+	q.Add("b", 3)
+	q.Add("c", 4)
+
+	// a changed value changes the cost
+	q.Update("b", 5)
+	assert.Equal(t, []string{"b", "c"}, q.Keys())
+	assert.Equal(t, 9, q.Cost())
+	q.Add("c", 1)
+	assert.Equal(t, 6, q.Cost())
+
+	q.MergeMap(map[string]int{"d": 3, "e": 2})
+	assert.LessOrEqual(t, q.Cost(), q.MaxCost())
+	assert.NotContains(t, q.Keys(), "b")
+
+	c := q.Clone()
+	c.Add("f", 10)
+	assert.Equal(t, []string{"f"}, c.Keys())
+	assert.Equal(t, 6, q.Cost())
+}
@@ -0,0 +1,135 @@
+/*
+Package countingbloom implements a counting Bloom filter.
+
+In contrast to a classic Bloom filter, every position holds a counter instead of a single bit, which allows keys to
+be removed again. The counters saturate at their maximum value; saturated counters are never decremented, as their
+true value is unknown, so that removals can never introduce false negatives.
+
+Only keys that have actually been added may be removed. Removing other keys can cause false negatives for keys that
+share their positions.
+*/
+package countingbloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+)
+
+// ErrInvalidData is returned when unmarshaling malformed data.
+var ErrInvalidData = errors.New("invalid data")
+
+const headerSize = 8 // number of hash functions and counters
+
+// Filter represents a counting Bloom filter.
+// It is not safe for concurrent use.
+type Filter struct {
+	hashes   int
+	counters []uint8
+}
+
+// New creates a new Filter for the given number of expected keys and false positive rate.
+func New(expected int, fpRate float64) *Filter {
+	if expected < 1 {
+		panic("invalid number of expected keys")
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		panic("invalid false positive rate")
+	}
+	m := math.Ceil(-float64(expected) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := int(math.Max(1, math.Round(m/float64(expected)*math.Ln2)))
+	return &Filter{
+		hashes:   k,
+		counters: make([]uint8, int(m)),
+	}
+}
+
+// Add adds the given key to the filter.
+func (f *Filter) Add(key string) {
+	f.each(key, func(c *uint8) bool {
+		if *c < math.MaxUint8 {
+			*c++
+		}
+		return true
+	})
+}
+
+// Remove removes one occurrence of the given key from the filter.
+// It returns false, if the key is not contained and nothing was changed.
+func (f *Filter) Remove(key string) bool {
+	if !f.Test(key) {
+		return false
+	}
+	f.each(key, func(c *uint8) bool {
+		if *c < math.MaxUint8 {
+			*c--
+		}
+		return true
+	})
+	return true
+}
+
+// Test returns whether the given key is contained in the filter.
+// It may return true for keys that have never been added with the configured false positive rate.
+func (f *Filter) Test(key string) bool {
+	return f.Count(key) > 0
+}
+
+// Count returns an upper bound for the number of times the given key has been added.
+func (f *Filter) Count(key string) int {
+	count := math.MaxUint8
+	f.each(key, func(c *uint8) bool {
+		if int(*c) < count {
+			count = int(*c)
+		}
+		return count > 0
+	})
+	return count
+}
+
+// Reset removes all keys from the filter.
+func (f *Filter) Reset() {
+	for i := range f.counters {
+		f.counters[i] = 0
+	}
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (f *Filter) MarshalBinary() ([]byte, error) {
+	data := make([]byte, headerSize, headerSize+len(f.counters))
+	binary.BigEndian.PutUint32(data, uint32(f.hashes))
+	binary.BigEndian.PutUint32(data[4:], uint32(len(f.counters)))
+	return append(data, f.counters...), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (f *Filter) UnmarshalBinary(data []byte) error {
+	if len(data) < headerSize {
+		return ErrInvalidData
+	}
+	k := binary.BigEndian.Uint32(data)
+	m := binary.BigEndian.Uint32(data[4:])
+	if k == 0 || m == 0 || uint64(m) != uint64(len(data)-headerSize) {
+		return ErrInvalidData
+	}
+	f.hashes = int(k)
+	f.counters = append([]uint8(nil), data[headerSize:]...)
+	return nil
+}
+
+// each calls fn for the counter of every hash function of the given key until fn returns false.
+func (f *Filter) each(key string, fn func(*uint8) bool) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum64()
+
+	// derive the positions using double hashing
+	h1, h2 := sum&math.MaxUint32, sum>>32|1
+	m := uint64(len(f.counters))
+	for i := 0; i < f.hashes; i++ {
+		if !fn(&f.counters[(h1+uint64(i)*h2)%m]) {
+			return
+		}
+	}
+}
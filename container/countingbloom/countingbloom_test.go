@@ -0,0 +1,78 @@
+package countingbloom_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/countingbloom"
+)
+
+const (
+	testExpected = 1000
+	testFPRate   = 0.01
+)
+
+func TestFilter_Remove(t *testing.T) {
+	f := New(testExpected, testFPRate)
+	assert.False(t, f.Test("a"))
+	assert.False(t, f.Remove("a"))
+
+	f.Add("a")
+	f.Add("a")
+	assert.Equal(t, 2, f.Count("a"))
+	assert.True(t, f.Remove("a"))
+	assert.True(t, f.Test("a"))
+	assert.True(t, f.Remove("a"))
+	assert.False(t, f.Test("a"))
+}
+
+func TestFilter_Saturation(t *testing.T) {
+	f := New(testExpected, testFPRate)
+	for i := 0; i < 2*math.MaxUint8; i++ {
+		f.Add("a")
+	}
+	assert.Equal(t, math.MaxUint8, f.Count("a"))
+
+	// saturated counters are never decremented
+	for i := 0; i < 2*math.MaxUint8; i++ {
+		require.True(t, f.Remove("a"))
+	}
+	assert.True(t, f.Test("a"))
+}
+
+func TestFilter_FalsePositiveRate(t *testing.T) {
+	f := New(testExpected, testFPRate)
+	for i := 0; i < testExpected; i++ {
+		f.Add(fmt.Sprint(i))
+	}
+	for i := 0; i < testExpected; i++ {
+		assert.True(t, f.Test(fmt.Sprint(i)))
+	}
+
+	var positives int
+	const n = 100000
+	for i := testExpected; i < testExpected+n; i++ {
+		if f.Test(fmt.Sprint(i)) {
+			positives++
+		}
+	}
+	assert.Less(t, float64(positives)/n, 2*testFPRate)
+}
+
+func TestFilter_MarshalBinary(t *testing.T) {
+	f := New(testExpected, testFPRate)
+	f.Add("a")
+	data, err := f.MarshalBinary()
+	require.NoError(t, err)
+
+	var g Filter
+	require.NoError(t, g.UnmarshalBinary(data))
+	assert.Equal(t, f, &g)
+	assert.True(t, g.Test("a"))
+
+	assert.Equal(t, ErrInvalidData, g.UnmarshalBinary(data[:len(data)-1]))
+	assert.Equal(t, ErrInvalidData, g.UnmarshalBinary(nil))
+}
@@ -0,0 +1,319 @@
+/*
+Package disklru implements a disk-backed key-value cache that limits the total size of the stored values using a
+least recently used (LRU) eviction policy.
+
+Every entry is stored in a separate file inside the cache directory. Files are written atomically by writing and
+syncing a temporary file first, renaming it afterwards and finally syncing the directory, so that a crash never leaves
+a partially written entry behind and a stored entry survives a crash.
+Each file contains the key and a checksum of its content, which allows Open to recover the index from the files in
+the directory, discarding corrupted entries. The recency of the entries is persisted using the modification time of
+their files.
+*/
+package disklru
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wollac/pkg/container/cache"
+)
+
+const (
+	fileExt    = ".entry"
+	tempPrefix = ".tmp-"
+	headerSize = 12 // magic, key length and checksum
+)
+
+var magic = [4]byte{'d', 'l', 'r', 'u'}
+
+// ErrCorrupted is returned when the file of an entry is corrupted.
+var ErrCorrupted = errors.New("corrupted entry")
+
+// Cache represents a disk-backed LRU cache with a limited total size.
+// It is not safe for concurrent use.
+type Cache struct {
+	dir      string
+	maxBytes int64
+	size     int64 // total size of all values
+
+	index map[string]*list.Element
+	order *list.List // most recently used first
+
+	err error // last error encountered by a method of the cache.Cache interface
+}
+
+// entry represents one entry of the Cache.
+type entry struct {
+	key  string
+	size int64
+}
+
+var _ cache.Cache[string, []byte] = (*Cache)(nil)
+
+// Open opens the cache in the given directory, creating it if necessary, and recovers all valid entries stored in
+// it. If the recovered entries exceed maxBytes, the least recently used ones are removed.
+func Open(dir string, maxBytes int64) (*Cache, error) {
+	if maxBytes < 0 {
+		panic("negative size")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		index:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+	if err := c.recover(); err != nil {
+		return nil, err
+	}
+	if err := c.evict(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Load returns the value stored for the given key and marks the entry as recently used.
+// It returns os.ErrNotExist, if no such key exists.
+func (c *Cache) Load(key string) ([]byte, error) {
+	e, ok := c.index[key]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	_, value, err := readFile(c.path(key))
+	if err != nil {
+		// the entry cannot be used anymore
+		_ = c.Remove(key)
+		return nil, err
+	}
+	c.order.MoveToFront(e)
+	now := time.Now()
+	return value, os.Chtimes(c.path(key), now, now)
+}
+
+// Store atomically stores the value for the given key and marks the entry as recently used.
+// Afterwards, the least recently used entries are removed until the total size fits the limit again.
+// If the value exceeds the size of the cache, an error is returned and any previous value of the key is removed.
+func (c *Cache) Store(key string, value []byte) error {
+	size := int64(len(value))
+	if size > c.maxBytes {
+		// the previous value must not be served anymore
+		if err := c.Remove(key); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return fmt.Errorf("value of %d bytes exceeds cache size", size)
+	}
+	if err := c.writeFile(key, value); err != nil {
+		return err
+	}
+	if e, ok := c.index[key]; ok {
+		ent := e.Value.(*entry)
+		c.size += size - ent.size
+		ent.size = size
+		c.order.MoveToFront(e)
+	} else {
+		c.index[key] = c.order.PushFront(&entry{key: key, size: size})
+		c.size += size
+	}
+	return c.evict()
+}
+
+// Remove removes the given key from the cache.
+// It returns os.ErrNotExist, if no such key exists.
+func (c *Cache) Remove(key string) error {
+	e, ok := c.index[key]
+	if !ok {
+		return os.ErrNotExist
+	}
+	c.order.Remove(e)
+	delete(c.index, key)
+	c.size -= e.Value.(*entry).size
+	return os.Remove(c.path(key))
+}
+
+// Get returns the value stored for the given key and implements the cache.Cache interface.
+// The second return value is false, when no such key exists or the value could not be read.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	value, err := c.Load(key)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.err = err
+	}
+	return value, err == nil
+}
+
+// Set stores the value for the given key and implements the cache.Cache interface.
+// Errors are not returned, but can be queried using Err.
+func (c *Cache) Set(key string, value []byte) {
+	if err := c.Store(key, value); err != nil {
+		c.err = err
+	}
+}
+
+// Delete removes the given key and implements the cache.Cache interface.
+// It returns true, if an entry was removed or false when no such key exists.
+func (c *Cache) Delete(key string) bool {
+	err := c.Remove(key)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.err = err
+	}
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// Err returns the last error encountered by Get, Set or Delete.
+func (c *Cache) Err() error {
+	return c.err
+}
+
+// Len returns the number of entries contained in the cache.
+func (c *Cache) Len() int {
+	return len(c.index)
+}
+
+// Size returns the total size of all values in bytes.
+func (c *Cache) Size() int64 {
+	return c.size
+}
+
+// evict removes the least recently used entries until the total size fits the limit.
+func (c *Cache) evict() error {
+	for c.size > c.maxBytes {
+		if err := c.Remove(c.order.Back().Value.(*entry).key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recover rebuilds the index from the files in the cache directory.
+func (c *Cache) recover() error {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	type recovered struct {
+		entry
+		modTime time.Time
+	}
+	var entries []recovered
+	for _, f := range files {
+		name := f.Name()
+		path := filepath.Join(c.dir, name)
+		switch {
+		case strings.HasPrefix(name, tempPrefix):
+			// leftover of an interrupted write
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			continue
+		case f.IsDir() || !strings.HasSuffix(name, fileExt):
+			continue
+		}
+
+		key, value, err := readFile(path)
+		if err != nil || c.path(key) != path {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, recovered{entry{key: key, size: int64(len(value))}, info.ModTime()})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+	for i := range entries {
+		e := entries[i].entry
+		c.index[e.key] = c.order.PushFront(&e)
+		c.size += e.size
+	}
+	return nil
+}
+
+// path returns the file path of the given key.
+func (c *Cache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+fileExt)
+}
+
+// writeFile atomically writes the file of the given entry.
+func (c *Cache) writeFile(key string, value []byte) error {
+	f, err := os.CreateTemp(c.dir, tempPrefix)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op after a successful rename
+
+	if _, err := f.Write(encode(key, value)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path(key)); err != nil {
+		return err
+	}
+	return syncDir(c.dir)
+}
+
+// syncDir commits the directory entries of the given directory, e.g. a rename, to stable storage.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+// encode returns the file content for the given entry: magic, key length, CRC-32 of key and value, key, value.
+func encode(key string, value []byte) []byte {
+	buf := make([]byte, headerSize, headerSize+len(key)+len(value))
+	copy(buf, magic[:])
+	binary.BigEndian.PutUint32(buf[4:], uint32(len(key)))
+	buf = append(buf, key...)
+	buf = append(buf, value...)
+	binary.BigEndian.PutUint32(buf[8:], crc32.ChecksumIEEE(buf[headerSize:]))
+	return buf
+}
+
+// readFile reads and verifies the file of an entry.
+func readFile(path string) (string, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) < headerSize || !bytes.Equal(data[:4], magic[:]) {
+		return "", nil, ErrCorrupted
+	}
+	keyLen := binary.BigEndian.Uint32(data[4:])
+	if uint64(keyLen) > uint64(len(data)-headerSize) ||
+		binary.BigEndian.Uint32(data[8:]) != crc32.ChecksumIEEE(data[headerSize:]) {
+		return "", nil, ErrCorrupted
+	}
+	content := data[headerSize:]
+	return string(content[:keyLen]), content[keyLen:], nil
+}
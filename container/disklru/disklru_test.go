@@ -0,0 +1,130 @@
+package disklru_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/disklru"
+)
+
+const testSize = 100
+
+func TestCache_Store(t *testing.T) {
+	c, err := Open(t.TempDir(), testSize)
+	require.NoError(t, err)
+
+	_, err = c.Load("not contained")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	require.NoError(t, c.Store("key", []byte("value")))
+	value, err := c.Load("key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+	assert.EqualValues(t, 5, c.Size())
+
+	// replacing the value updates the size
+	require.NoError(t, c.Store("key", []byte("v")))
+	assert.Equal(t, 1, c.Len())
+	assert.EqualValues(t, 1, c.Size())
+
+	assert.Error(t, c.Store("too large", make([]byte, testSize+1)))
+
+	// a value that is too large removes the previous value
+	c.Set("key", make([]byte, testSize+1))
+	assert.Error(t, c.Err())
+	_, ok := c.Get("key")
+	assert.False(t, ok)
+	assert.Zero(t, c.Len())
+	assert.Zero(t, c.Size())
+}
+
+func TestCache_Evict(t *testing.T) {
+	c, err := Open(t.TempDir(), testSize)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, c.Store(fmt.Sprint(i), make([]byte, testSize/10)))
+	}
+	// access the oldest entry, so that the second oldest gets evicted
+	_, err = c.Load("0")
+	require.NoError(t, err)
+	require.NoError(t, c.Store("new", make([]byte, testSize/10)))
+
+	assert.Equal(t, 10, c.Len())
+	assert.EqualValues(t, testSize, c.Size())
+	_, ok := c.Get("0")
+	assert.True(t, ok)
+	_, ok = c.Get("1")
+	assert.False(t, ok)
+}
+
+func TestCache_Delete(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(dir, testSize)
+	require.NoError(t, err)
+
+	c.Set("key", []byte("value"))
+	assert.False(t, c.Delete("not contained"))
+	assert.True(t, c.Delete("key"))
+	assert.NoError(t, c.Err())
+	assert.Zero(t, c.Len())
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestOpen_Recover(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(dir, testSize)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, c.Store(fmt.Sprint(i), []byte(fmt.Sprint("value", i))))
+		// assure distinct modification times
+		path := filepath.Join(dir, entryFile(t, dir, i))
+		mtime := time.Now().Add(time.Duration(i) * time.Second)
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	// simulate a crash during a write and a corrupted entry
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".tmp-123"), []byte("partial"), 0o644))
+	corrupted := filepath.Join(dir, entryFile(t, dir, 4))
+	require.NoError(t, os.WriteFile(corrupted, []byte("garbage"), 0o644))
+
+	// reopen with a smaller size, so that the oldest entry does not fit
+	c, err = Open(dir, 4*6-1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, c.Len())
+	for i := 1; i < 4; i++ {
+		value, ok := c.Get(fmt.Sprint(i))
+		assert.True(t, ok)
+		assert.Equal(t, []byte(fmt.Sprint("value", i)), value)
+	}
+	_, ok := c.Get("0")
+	assert.False(t, ok)
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+}
+
+// entryFile returns the name of the file of the i-th entry, which is the only file containing its value.
+func entryFile(t *testing.T, dir string, i int) string {
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		require.NoError(t, err)
+		if string(data[len(data)-6:]) == fmt.Sprint("value", i) {
+			return f.Name()
+		}
+	}
+	t.Fatalf("no file for entry %d", i)
+	return ""
+}
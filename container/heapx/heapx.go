@@ -0,0 +1,108 @@
+/*
+Package heapx provides generic heap operations on slices.
+
+In contrast to container/heap, the functions operate directly on a []T ordered by a less function, which avoids the
+interface conversions and the boilerplate of implementing heap.Interface. The minimum element according to less is
+always at index 0.
+
+Functions that change the length of the heap return the updated slice, similar to the built-in append.
+*/
+package heapx
+
+// Heapify establishes the heap invariants for h.
+// The complexity is O(n) where n = len(h).
+func Heapify[T any](h []T, less func(a, b T) bool) {
+	n := len(h)
+	for i := n/2 - 1; i >= 0; i-- {
+		down(h, i, n, less)
+	}
+}
+
+// Push pushes the element x onto the heap and returns the updated heap.
+// The complexity is O(log n) where n = len(h).
+func Push[T any](h []T, x T, less func(a, b T) bool) []T {
+	h = append(h, x)
+	up(h, len(h)-1, less)
+	return h
+}
+
+// Pop removes the minimum element from the heap and returns it together with the updated heap.
+// It panics if h is empty. The complexity is O(log n) where n = len(h).
+func Pop[T any](h []T, less func(a, b T) bool) (T, []T) {
+	return Remove(h, 0, less)
+}
+
+// Remove removes the element at index i from the heap and returns it together with the updated heap.
+// The complexity is O(log n) where n = len(h).
+func Remove[T any](h []T, i int, less func(a, b T) bool) (T, []T) {
+	n := len(h) - 1
+	if n != i {
+		h[i], h[n] = h[n], h[i]
+		if !down(h, i, n, less) {
+			up(h, i, less)
+		}
+	}
+	x := h[n]
+	var zero T
+	h[n] = zero // allow the element to be garbage collected
+	return x, h[:n]
+}
+
+// Fix re-establishes the heap ordering after the element at index i has changed its value.
+// The complexity is O(log n) where n = len(h).
+func Fix[T any](h []T, i int, less func(a, b T) bool) {
+	if !down(h, i, len(h), less) {
+		up(h, i, less)
+	}
+}
+
+// PushPop pushes x onto the heap and then removes and returns the minimum element.
+// This is more efficient than Push followed by Pop and does not change the length of the heap.
+func PushPop[T any](h []T, x T, less func(a, b T) bool) T {
+	if len(h) > 0 && less(h[0], x) {
+		x, h[0] = h[0], x
+		down(h, 0, len(h), less)
+	}
+	return x
+}
+
+// Replace removes and returns the minimum element and then pushes x onto the heap.
+// This is more efficient than Pop followed by Push and does not change the length of the heap.
+// It panics if h is empty.
+func Replace[T any](h []T, x T, less func(a, b T) bool) T {
+	top := h[0]
+	h[0] = x
+	down(h, 0, len(h), less)
+	return top
+}
+
+func up[T any](h []T, j int, less func(a, b T) bool) {
+	for {
+		i := (j - 1) / 2 // parent
+		if i == j || !less(h[j], h[i]) {
+			break
+		}
+		h[i], h[j] = h[j], h[i]
+		j = i
+	}
+}
+
+func down[T any](h []T, i0, n int, less func(a, b T) bool) bool {
+	i := i0
+	for {
+		j1 := 2*i + 1
+		if j1 >= n || j1 < 0 { // j1 < 0 after int overflow
+			break
+		}
+		j := j1 // left child
+		if j2 := j1 + 1; j2 < n && less(h[j2], h[j1]) {
+			j = j2 // = 2*i + 2  // right child
+		}
+		if !less(h[j], h[i]) {
+			break
+		}
+		h[i], h[j] = h[j], h[i]
+		i = j
+	}
+	return i > i0
+}
@@ -0,0 +1,79 @@
+package heapx_test
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/heapx"
+)
+
+const testSize = 100
+
+func less(a, b int) bool { return a < b }
+
+// drain pops all elements of the heap.
+func drain(h []int) []int {
+	var sorted []int
+	for len(h) > 0 {
+		var x int
+		x, h = Pop(h, less)
+		sorted = append(sorted, x)
+	}
+	return sorted
+}
+
+func TestHeapify(t *testing.T) {
+	h := rand.Perm(testSize)
+	Heapify(h, less)
+	sorted := drain(h)
+	assert.True(t, sort.IntsAreSorted(sorted))
+	assert.Len(t, sorted, testSize)
+}
+
+func TestPush(t *testing.T) {
+	var h []int
+	for _, x := range rand.Perm(testSize) {
+		h = Push(h, x, less)
+	}
+	require.Zero(t, h[0])
+	assert.True(t, sort.IntsAreSorted(drain(h)))
+}
+
+func TestRemove(t *testing.T) {
+	h := rand.Perm(testSize)
+	Heapify(h, less)
+	for len(h) > testSize/2 {
+		_, h = Remove(h, rand.Intn(len(h)), less)
+	}
+	assert.True(t, sort.IntsAreSorted(drain(h)))
+}
+
+func TestFix(t *testing.T) {
+	h := rand.Perm(testSize)
+	Heapify(h, less)
+	for i := 0; i < testSize; i++ {
+		j := rand.Intn(len(h))
+		h[j] = rand.Intn(testSize)
+		Fix(h, j, less)
+	}
+	assert.True(t, sort.IntsAreSorted(drain(h)))
+}
+
+func TestPushPop(t *testing.T) {
+	h := []int{1, 2, 3}
+	Heapify(h, less)
+	assert.Equal(t, 0, PushPop(h, 0, less))
+	assert.Equal(t, 1, PushPop(h, 4, less))
+	assert.Equal(t, []int{2, 3, 4}, drain(h))
+}
+
+func TestReplace(t *testing.T) {
+	h := []int{1, 2, 3}
+	Heapify(h, less)
+	assert.Equal(t, 1, Replace(h, 0, less))
+	assert.Equal(t, []int{0, 2, 3}, drain(h))
+	assert.Panics(t, func() { Replace(nil, 0, less) })
+}
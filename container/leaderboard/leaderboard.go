@@ -0,0 +1,146 @@
+/*
+Package leaderboard implements a bounded leaderboard keeping the keys with the highest scores.
+
+The leaderboard holds at most a fixed number of keys. When it is full, a new key is only accepted if its score is
+higher than the lowest score on the board, which in turn evicts the key with the lowest score. Keys are ranked by
+descending score, and keys with equal scores by ascending key.
+
+Updating a score as well as querying the rank of a key are O(log n) operations, where n is the capacity.
+*/
+package leaderboard
+
+import (
+	"math/rand"
+)
+
+// Entry represents a key and its score.
+type Entry struct {
+	Key   string
+	Score int
+}
+
+// Board represents a bounded leaderboard.
+// It is not safe for concurrent use.
+type Board struct {
+	cap   int
+	root  *node
+	index map[string]*node
+	rand  *rand.Rand
+}
+
+// New creates a new empty Board holding at most cap keys.
+func New(cap int) *Board {
+	if cap < 1 {
+		panic("invalid capacity")
+	}
+	return &Board{
+		cap:   cap,
+		index: make(map[string]*node, cap),
+		rand:  rand.New(rand.NewSource(int64(cap))),
+	}
+}
+
+// Set sets the score of the given key, adding the key if necessary.
+// It returns false, if the board is full and the score is not high enough to be accepted.
+func (b *Board) Set(key string, score int) bool {
+	e := Entry{Key: key, Score: score}
+	if n, ok := b.index[key]; ok {
+		b.root = remove(b.root, n.entry)
+		n.entry = e
+		n.left, n.right = nil, nil
+		b.root = insert(b.root, n)
+		return true
+	}
+	if len(b.index) == b.cap {
+		lowest := last(b.root)
+		if !before(e, lowest.entry) {
+			return false
+		}
+		b.root = remove(b.root, lowest.entry)
+		delete(b.index, lowest.entry.Key)
+	}
+	n := &node{entry: e, prio: b.rand.Uint32()}
+	b.index[key] = n
+	b.root = insert(b.root, n)
+	return true
+}
+
+// Delete removes the given key from the board.
+// It returns true, if the key was removed or false when no such key exists.
+func (b *Board) Delete(key string) bool {
+	n, ok := b.index[key]
+	if !ok {
+		return false
+	}
+	b.root = remove(b.root, n.entry)
+	delete(b.index, key)
+	return true
+}
+
+// Score returns the score of the given key.
+// The second return value is false, if no such key exists.
+func (b *Board) Score(key string) (int, bool) {
+	n, ok := b.index[key]
+	if !ok {
+		return 0, false
+	}
+	return n.entry.Score, true
+}
+
+// Rank returns the zero-based rank of the given key, where rank 0 has the highest score.
+// The second return value is false, if no such key exists.
+func (b *Board) Rank(key string) (int, bool) {
+	n, ok := b.index[key]
+	if !ok {
+		return 0, false
+	}
+	return rank(b.root, n.entry), true
+}
+
+// At returns the entry with the given rank.
+// The second return value is false, if the rank is out of range.
+func (b *Board) At(rank int) (Entry, bool) {
+	if rank < 0 || rank >= len(b.index) {
+		return Entry{}, false
+	}
+	return at(b.root, rank).entry, true
+}
+
+// Range returns the entries with ranks in [from, to) in ascending rank order.
+// The range is clipped to the entries on the board.
+func (b *Board) Range(from, to int) []Entry {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(b.index) {
+		to = len(b.index)
+	}
+	if from >= to {
+		return nil
+	}
+	entries := make([]Entry, 0, to-from)
+	for r := from; r < to; r++ {
+		entries = append(entries, at(b.root, r).entry)
+	}
+	return entries
+}
+
+// Around returns the entries ranked at most n ranks before or after the given key, including the key itself.
+// It returns nil, if no such key exists.
+func (b *Board) Around(key string, n int) []Entry {
+	r, ok := b.Rank(key)
+	if !ok {
+		return nil
+	}
+	return b.Range(r-n, r+n+1)
+}
+
+// Len returns the number of keys on the board.
+func (b *Board) Len() int {
+	return len(b.index)
+}
+
+// Cap returns the capacity of the board.
+func (b *Board) Cap() int {
+	return b.cap
+}
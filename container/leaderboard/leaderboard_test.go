@@ -0,0 +1,92 @@
+package leaderboard_test
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/leaderboard"
+)
+
+const testCapacity = 10
+
+func TestBoard_Set(t *testing.T) {
+	b := New(testCapacity)
+	for i := 0; i < testCapacity; i++ {
+		require.True(t, b.Set(fmt.Sprint(i), i))
+	}
+	assert.Equal(t, testCapacity, b.Len())
+
+	// a low score is rejected, a high one evicts the lowest
+	assert.False(t, b.Set("low", 0))
+	assert.True(t, b.Set("high", testCapacity))
+	_, ok := b.Score("0")
+	assert.False(t, ok)
+
+	r, ok := b.Rank("high")
+	assert.True(t, ok)
+	assert.Zero(t, r)
+
+	// updating a score changes the rank
+	assert.True(t, b.Set("high", 1))
+	r, _ = b.Rank("high")
+	assert.Equal(t, testCapacity-1, r)
+	score, _ := b.Score("high")
+	assert.Equal(t, 1, score)
+}
+
+func TestBoard_Range(t *testing.T) {
+	b := New(testCapacity)
+	for i := 0; i < testCapacity; i++ {
+		b.Set(fmt.Sprint(i), i/2) // pairs of equal scores
+	}
+	assert.Equal(t, []Entry{{"8", 4}, {"9", 4}, {"6", 3}}, b.Range(-1, 3))
+	assert.Equal(t, []Entry{{"1", 0}}, b.Range(testCapacity-1, testCapacity+1))
+	assert.Empty(t, b.Range(3, 3))
+
+	assert.Equal(t, []Entry{{"9", 4}, {"6", 3}, {"7", 3}}, b.Around("6", 1))
+	assert.Nil(t, b.Around("unknown", 1))
+
+	e, ok := b.At(1)
+	assert.True(t, ok)
+	assert.Equal(t, Entry{"9", 4}, e)
+	_, ok = b.At(testCapacity)
+	assert.False(t, ok)
+}
+
+func TestBoard_Random(t *testing.T) {
+	const n = 1000
+	b := New(n)
+	scores := make(map[string]int)
+	for i := 0; i < 10*n; i++ {
+		key := fmt.Sprint(rand.Intn(n))
+		if rand.Intn(4) == 0 {
+			b.Delete(key)
+			delete(scores, key)
+			continue
+		}
+		score := rand.Intn(n)
+		b.Set(key, score)
+		scores[key] = score
+	}
+
+	var expected []Entry
+	for k, s := range scores {
+		expected = append(expected, Entry{k, s})
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		if expected[i].Score != expected[j].Score {
+			return expected[i].Score > expected[j].Score
+		}
+		return expected[i].Key < expected[j].Key
+	})
+	assert.Equal(t, expected, b.Range(0, b.Len()))
+	for i, e := range expected {
+		r, ok := b.Rank(e.Key)
+		require.True(t, ok)
+		assert.Equal(t, i, r)
+	}
+}
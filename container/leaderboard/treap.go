@@ -0,0 +1,121 @@
+package leaderboard
+
+// node represents a node of a treap, which is a binary search tree ordered by rank and a heap ordered by prio.
+// Every node stores the size of its subtree, which allows rank queries in O(log n).
+type node struct {
+	entry       Entry
+	prio        uint32
+	size        int
+	left, right *node
+}
+
+func size(n *node) int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *node) update() *node {
+	n.size = 1 + size(n.left) + size(n.right)
+	return n
+}
+
+// before returns whether a is ranked before b.
+func before(a, b Entry) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.Key < b.Key
+}
+
+// split splits the treap into the nodes ranked before e and all other nodes.
+func split(n *node, e Entry) (*node, *node) {
+	if n == nil {
+		return nil, nil
+	}
+	if before(n.entry, e) {
+		l, r := split(n.right, e)
+		n.right = l
+		return n.update(), r
+	}
+	l, r := split(n.left, e)
+	n.left = r
+	return l, n.update()
+}
+
+// merge merges two treaps, where all nodes of l are ranked before the nodes of r.
+func merge(l, r *node) *node {
+	switch {
+	case l == nil:
+		return r
+	case r == nil:
+		return l
+	case l.prio > r.prio:
+		l.right = merge(l.right, r)
+		return l.update()
+	default:
+		r.left = merge(l, r.left)
+		return r.update()
+	}
+}
+
+// insert inserts the node n into the treap.
+func insert(t, n *node) *node {
+	l, r := split(t, n.entry)
+	return merge(merge(l, n.update()), r)
+}
+
+// remove removes the node with the given entry from the treap.
+func remove(t *node, e Entry) *node {
+	if t == nil {
+		return nil
+	}
+	switch {
+	case t.entry == e:
+		return merge(t.left, t.right)
+	case before(e, t.entry):
+		t.left = remove(t.left, e)
+	default:
+		t.right = remove(t.right, e)
+	}
+	return t.update()
+}
+
+// rank returns the number of nodes ranked before e.
+func rank(t *node, e Entry) int {
+	var r int
+	for t != nil {
+		if before(t.entry, e) {
+			r += size(t.left) + 1
+			t = t.right
+		} else {
+			t = t.left
+		}
+	}
+	return r
+}
+
+// at returns the node with the given rank.
+func at(t *node, r int) *node {
+	for t != nil {
+		switch s := size(t.left); {
+		case r < s:
+			t = t.left
+		case r == s:
+			return t
+		default:
+			r -= s + 1
+			t = t.right
+		}
+	}
+	return nil
+}
+
+// last returns the node ranked last.
+func last(t *node) *node {
+	for t != nil && t.right != nil {
+		t = t.right
+	}
+	return t
+}
@@ -0,0 +1,157 @@
+/*
+Package lru implements a key-value cache with limited number of entries using a least recently used (LRU) eviction
+policy. When a new entry is added to a full cache, the entry that has not been accessed for the longest time gets
+deleted.
+
+Optionally, the cache can be segmented (SLRU) into a probationary and a protected segment: New entries are added to
+the probationary segment and only move to the protected segment when they are accessed again. Entries are evicted
+from the probationary segment first, so that a scan over many keys that are accessed only once cannot flush the
+frequently used entries from the cache.
+
+All operations have O(1) complexity.
+*/
+package lru
+
+import (
+	"container/list"
+
+	"github.com/wollac/pkg/container/cache"
+)
+
+// Cache represents a LRU cache with limited number of entries.
+type Cache[K comparable, V any] struct {
+	cap          int
+	protectedCap int
+
+	index     map[K]*list.Element
+	probation *list.List // entries accessed once, most recently used first
+	protected *list.List // entries accessed more than once, most recently used first
+}
+
+// entry represents one entry of the Cache.
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	protected bool // whether the entry is in the protected segment
+}
+
+var _ cache.Cache[string, int] = (*Cache[string, int])(nil)
+
+// New creates a new Cache instance.
+func New[K comparable, V any](cap int, opts ...Option) *Cache[K, V] {
+	if cap < 1 {
+		panic("non-positive capacity")
+	}
+	var o options
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	protectedCap := int(o.protectedRatio * float64(cap))
+	if o.protectedRatio > 0 && protectedCap == 0 {
+		protectedCap = 1 // the segmentation must not be disabled for small caches
+	}
+	return &Cache[K, V]{
+		cap:          cap,
+		protectedCap: protectedCap,
+		index:        make(map[K]*list.Element, cap),
+		probation:    list.New(),
+		protected:    list.New(),
+	}
+}
+
+// Get returns the value of the given key and marks the entry as recently used.
+// The second return value is false, when no such key exists.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	e, ok := c.index[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.hit(e)
+	return e.Value.(*entry[K, V]).value, true
+}
+
+// Peek returns the value of the given key without marking the entry as recently used.
+// The second return value is false, when no such key exists.
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	e, ok := c.index[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return e.Value.(*entry[K, V]).value, true
+}
+
+// Set stores the value for the given key and marks the entry as recently used.
+// If the cache is already full, the least recently used entry gets removed.
+func (c *Cache[K, V]) Set(key K, value V) {
+	if e, ok := c.index[key]; ok {
+		e.Value.(*entry[K, V]).value = value
+		c.hit(e)
+		return
+	}
+	if c.Len() == c.cap {
+		c.evict()
+	}
+	c.index[key] = c.probation.PushFront(&entry[K, V]{key: key, value: value})
+}
+
+// Delete removes the given key.
+// It returns true, if an entry was removed or false when no such key exists.
+func (c *Cache[K, V]) Delete(key K) bool {
+	e, ok := c.index[key]
+	if !ok {
+		return false
+	}
+	c.segment(e).Remove(e)
+	delete(c.index, key)
+	return true
+}
+
+// Len returns the number of entries contained in the cache.
+func (c *Cache[K, V]) Len() int {
+	return len(c.index)
+}
+
+// Cap returns the maximum capacity of the cache.
+func (c *Cache[K, V]) Cap() int {
+	return c.cap
+}
+
+// hit marks the given element as recently used.
+func (c *Cache[K, V]) hit(e *list.Element) {
+	ent := e.Value.(*entry[K, V])
+	if ent.protected || c.protectedCap == 0 {
+		c.segment(e).MoveToFront(e)
+		return
+	}
+	// promote the entry into the protected segment
+	c.probation.Remove(e)
+	ent.protected = true
+	c.index[ent.key] = c.protected.PushFront(ent)
+	if c.protected.Len() > c.protectedCap {
+		// demote the least recently used protected entry
+		last := c.protected.Back()
+		demoted := c.protected.Remove(last).(*entry[K, V])
+		demoted.protected = false
+		c.index[demoted.key] = c.probation.PushFront(demoted)
+	}
+}
+
+// evict removes the least recently used entry, preferably from the probationary segment.
+func (c *Cache[K, V]) evict() {
+	l := c.probation
+	if l.Len() == 0 {
+		l = c.protected
+	}
+	ent := l.Remove(l.Back()).(*entry[K, V])
+	delete(c.index, ent.key)
+}
+
+// segment returns the list containing the given element.
+func (c *Cache[K, V]) segment(e *list.Element) *list.List {
+	if e.Value.(*entry[K, V]).protected {
+		return c.protected
+	}
+	return c.probation
+}
@@ -0,0 +1,147 @@
+package lru_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/lru"
+)
+
+const testCapacity = 10
+
+func TestNew(t *testing.T) {
+	c := New[string, int](testCapacity)
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, testCapacity, c.Cap())
+	assert.Panics(t, func() { New[string, int](0) })
+}
+
+func TestCache_Get(t *testing.T) {
+	c := New[string, int](testCapacity)
+	_, ok := c.Get("not contained")
+	assert.False(t, ok)
+
+	for i := 0; i < testCapacity; i++ {
+		c.Set(fmt.Sprint(i), i)
+	}
+	for i := 0; i < testCapacity; i++ {
+		value, ok := c.Get(fmt.Sprint(i))
+		assert.True(t, ok)
+		assert.Equal(t, i, value)
+	}
+}
+
+func TestCache_Set(t *testing.T) {
+	c := New[string, int](testCapacity)
+	for i := 0; i < testCapacity; i++ {
+		c.Set(fmt.Sprint(i), i)
+	}
+	// access the oldest entry, so that the second oldest gets evicted
+	c.Get("0")
+	c.Set("new", -1)
+	assert.Equal(t, testCapacity, c.Len())
+
+	_, ok := c.Peek("0")
+	assert.True(t, ok)
+	_, ok = c.Peek("1")
+	assert.False(t, ok)
+
+	// replacing a value does not evict
+	c.Set("new", -2)
+	assert.Equal(t, testCapacity, c.Len())
+	value, _ := c.Peek("new")
+	assert.Equal(t, -2, value)
+}
+
+func TestCache_Peek(t *testing.T) {
+	c := New[string, int](testCapacity)
+	for i := 0; i < testCapacity; i++ {
+		c.Set(fmt.Sprint(i), i)
+	}
+	// peeking does not count as an access
+	c.Peek("0")
+	c.Set("new", -1)
+	_, ok := c.Peek("0")
+	assert.False(t, ok)
+}
+
+func TestCache_Delete(t *testing.T) {
+	c := New[string, int](testCapacity, WithSegmentation(0.5))
+	for i := 0; i < testCapacity; i++ {
+		c.Set(fmt.Sprint(i), i)
+		c.Get(fmt.Sprint(i))
+	}
+
+	assert.False(t, c.Delete("not contained"))
+	for i := 0; i < testCapacity; i++ {
+		assert.True(t, c.Delete(fmt.Sprint(i)))
+		assert.Equal(t, testCapacity-i-1, c.Len())
+	}
+}
+
+func TestWithSegmentation(t *testing.T) {
+	c := New[string, int](testCapacity, WithSegmentation(0.5))
+	// make half of the entries frequently used
+	for i := 0; i < testCapacity/2; i++ {
+		c.Set(fmt.Sprint(i), i)
+		c.Get(fmt.Sprint(i))
+	}
+	// a scan over many keys does not flush the protected entries
+	for i := 0; i < 10*testCapacity; i++ {
+		c.Set(fmt.Sprint("scan", i), i)
+	}
+	assert.Equal(t, testCapacity, c.Len())
+	for i := 0; i < testCapacity/2; i++ {
+		_, ok := c.Peek(fmt.Sprint(i))
+		assert.True(t, ok)
+	}
+
+	assert.Panics(t, func() { WithSegmentation(1) })
+	assert.Panics(t, func() { WithSegmentation(-0.1) })
+}
+
+func TestWithSegmentationSmall(t *testing.T) {
+	// the ratio of the capacity is less than one entry, but the segmentation is still used
+	c := New[string, int](testCapacity, WithSegmentation(0.05))
+	c.Set("frequent", 0)
+	c.Get("frequent")
+	for i := 0; i < 10*testCapacity; i++ {
+		c.Set(fmt.Sprint("scan", i), i)
+	}
+	_, ok := c.Peek("frequent")
+	assert.True(t, ok)
+}
+
+func TestWithSegmentationDemotion(t *testing.T) {
+	c := New[string, int](4, WithSegmentation(0.5))
+	for i := 0; i < 3; i++ {
+		c.Set(fmt.Sprint(i), i)
+		c.Get(fmt.Sprint(i))
+	}
+	// "0" got demoted to the probationary segment and is evicted first
+	c.Set("3", 3)
+	c.Set("4", 4)
+	_, ok := c.Peek("0")
+	assert.False(t, ok)
+	for _, key := range []string{"1", "2", "3", "4"} {
+		_, ok := c.Peek(key)
+		assert.True(t, ok)
+	}
+}
+
+func BenchmarkCache_Set(b *testing.B) {
+	c := New[int, int](1000, WithSegmentation(0.8))
+	data := make([]int, b.N)
+	for i := range data {
+		data[i] = rand.Intn(2000)
+	}
+	b.ResetTimer()
+
+	for _, key := range data {
+		if _, ok := c.Get(key); !ok {
+			c.Set(key, key)
+		}
+	}
+}
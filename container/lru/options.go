@@ -0,0 +1,32 @@
+package lru
+
+// An Option configures a Cache.
+type Option interface {
+	apply(*options)
+}
+
+// optionFunc wraps a func so it satisfies the Option interface.
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+// options holds the configuration of a Cache.
+type options struct {
+	protectedRatio float64
+}
+
+// WithSegmentation configures a segmented LRU cache (SLRU), where the given ratio of the capacity is reserved for
+// the protected segment. Entries are promoted from the probationary to the protected segment when they are
+// accessed again after being added. The ratio must be in the interval [0, 1), a ratio of 0 disables the
+// segmentation. For a positive ratio, the protected segment holds at least one entry, even if the ratio of the
+// capacity is less than one.
+func WithSegmentation(protectedRatio float64) Option {
+	if protectedRatio < 0 || protectedRatio >= 1 {
+		panic("invalid protected ratio")
+	}
+	return optionFunc(func(o *options) {
+		o.protectedRatio = protectedRatio
+	})
+}
@@ -0,0 +1,70 @@
+/*
+Package ordering provides composable comparators defining total orders.
+
+A Comparator returns a negative number, zero or a positive number when its first argument is less than, equal to or
+greater than its second argument. Comparators can be reversed and chained, and converted to the less functions used
+by the containers of this repository.
+*/
+package ordering
+
+// Ordered is a constraint that permits any type supporting the operators < <= >= >.
+type Ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64 |
+		~string
+}
+
+// Comparator compares two values, returning -1 if a < b, 0 if a == b and +1 if a > b.
+type Comparator[T any] func(a, b T) int
+
+// Less reports whether a is less than b.
+func (c Comparator[T]) Less(a, b T) bool {
+	return c(a, b) < 0
+}
+
+// Natural returns the comparator of the natural order of T.
+// For floating-point types, NaN is considered less than any other value and equal to NaN.
+func Natural[T Ordered]() Comparator[T] {
+	return compare[T]
+}
+
+// Reverse returns the comparator of the reverse order of c.
+func Reverse[T any](c Comparator[T]) Comparator[T] {
+	return func(a, b T) int {
+		return c(b, a)
+	}
+}
+
+// By returns the comparator ordering values by the natural order of the key returned by the given function.
+func By[T any, K Ordered](key func(T) K) Comparator[T] {
+	return func(a, b T) int {
+		return compare(key(a), key(b))
+	}
+}
+
+// Lexicographic returns the comparator that orders values by the first of the given comparators that does not
+// consider them equal.
+func Lexicographic[T any](cs ...Comparator[T]) Comparator[T] {
+	return func(a, b T) int {
+		for _, c := range cs {
+			if r := c(a, b); r != 0 {
+				return r
+			}
+		}
+		return 0
+	}
+}
+
+func compare[T Ordered](a, b T) int {
+	aNaN, bNaN := a != a, b != b // only NaN is not equal to itself
+	switch {
+	case aNaN && bNaN:
+		return 0
+	case aNaN || a < b:
+		return -1
+	case bNaN || a > b:
+		return +1
+	}
+	return 0
+}
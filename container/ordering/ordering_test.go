@@ -0,0 +1,46 @@
+package ordering_test
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/ordering"
+)
+
+type person struct {
+	name string
+	age  int
+}
+
+func TestNatural(t *testing.T) {
+	c := Natural[int]()
+	assert.Equal(t, -1, c(1, 2))
+	assert.Equal(t, 0, c(2, 2))
+	assert.Equal(t, +1, c(3, 2))
+	assert.True(t, c.Less(1, 2))
+
+	f := Natural[float64]()
+	nan := math.NaN()
+	assert.Equal(t, 0, f(nan, nan))
+	assert.Equal(t, -1, f(nan, math.Inf(-1)))
+	assert.Equal(t, +1, f(math.Inf(-1), nan))
+}
+
+func TestReverse(t *testing.T) {
+	s := []string{"a", "c", "b"}
+	sort.Slice(s, func(i, j int) bool { return Reverse(Natural[string]()).Less(s[i], s[j]) })
+	assert.Equal(t, []string{"c", "b", "a"}, s)
+}
+
+func TestLexicographic(t *testing.T) {
+	people := []person{{"b", 30}, {"a", 30}, {"c", 20}}
+	c := Lexicographic(
+		Reverse(By(func(p person) int { return p.age })),
+		By(func(p person) string { return p.name }),
+	)
+	sort.Slice(people, func(i, j int) bool { return c.Less(people[i], people[j]) })
+	assert.Equal(t, []person{{"a", 30}, {"b", 30}, {"c", 20}}, people)
+	assert.Equal(t, 0, c(person{"a", 1}, person{"a", 1}))
+}
@@ -0,0 +1,26 @@
+package quotatracker
+
+// An Option configures a Quota.
+type Option interface {
+	apply(*options)
+}
+
+// optionFunc wraps a func so it satisfies the Option interface.
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+// options holds the configuration of a Quota.
+type options struct {
+	onOverflow func(q *Quota, n int64)
+}
+
+// WithOverflowCallback configures a callback that is called whenever consuming n units fails, because it would
+// exceed the limit of the quota. The callback is called without holding any lock of the tree.
+func WithOverflowCallback(f func(q *Quota, n int64)) Option {
+	return optionFunc(func(o *options) {
+		o.onOverflow = f
+	})
+}
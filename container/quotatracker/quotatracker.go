@@ -0,0 +1,156 @@
+/*
+Package quotatracker implements hierarchical accounting of resource budgets.
+
+Quotas form a tree: Every unit consumed from a child quota is also consumed from all its ancestors, so that a parent
+limits the total usage of its children while each child can be limited individually. Consuming succeeds for all
+quotas on the path to the root or for none of them.
+*/
+package quotatracker
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrQuotaExceeded is returned when consuming would exceed the limit of a quota.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota represents a node in the tree of quotas.
+// It is safe for concurrent use by multiple goroutines.
+type Quota struct {
+	name   string
+	parent *Quota
+	mu     *sync.Mutex // shared by the entire tree
+	opts   options
+
+	limit    int64
+	used     int64
+	children map[string]*Quota
+}
+
+// New creates the root of a new tree of quotas with the given limit.
+func New(name string, limit int64, opts ...Option) *Quota {
+	return newQuota(name, limit, nil, &sync.Mutex{}, opts)
+}
+
+func newQuota(name string, limit int64, parent *Quota, mu *sync.Mutex, opts []Option) *Quota {
+	if limit < 0 {
+		panic("negative limit")
+	}
+	q := &Quota{
+		name:     name,
+		parent:   parent,
+		mu:       mu,
+		limit:    limit,
+		children: make(map[string]*Quota),
+	}
+	for _, opt := range opts {
+		opt.apply(&q.opts)
+	}
+	return q
+}
+
+// Child returns the child quota with the given name, creating it with the given limit and options if it does not
+// exist. The limit of an existing child is not changed.
+func (q *Quota) Child(name string, limit int64, opts ...Option) *Quota {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if c, ok := q.children[name]; ok {
+		return c
+	}
+	c := newQuota(name, limit, q, q.mu, opts)
+	q.children[name] = c
+	return c
+}
+
+// Name returns the name of the quota.
+func (q *Quota) Name() string {
+	return q.name
+}
+
+// Parent returns the parent of the quota or nil for the root.
+func (q *Quota) Parent() *Quota {
+	return q.parent
+}
+
+// Limit returns the limit of the quota.
+func (q *Quota) Limit() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.limit
+}
+
+// SetLimit changes the limit of the quota.
+// Lowering the limit below the current usage does not release anything, but prevents further consumption.
+func (q *Quota) SetLimit(limit int64) {
+	if limit < 0 {
+		panic("negative limit")
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.limit = limit
+}
+
+// Used returns the number of units consumed from the quota and all its descendants.
+func (q *Quota) Used() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.used
+}
+
+// Available returns the number of units that can currently be consumed from the quota, taking the limits of all
+// ancestors into account.
+func (q *Quota) Available() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	avail := q.limit - q.used
+	for p := q.parent; p != nil; p = p.parent {
+		if a := p.limit - p.used; a < avail {
+			avail = a
+		}
+	}
+	if avail < 0 {
+		return 0
+	}
+	return avail
+}
+
+// Consume consumes n units from the quota and all its ancestors.
+// It returns ErrQuotaExceeded, if this would exceed any of their limits. In this case, nothing is consumed and the
+// overflow callback of the exceeded quota is called.
+func (q *Quota) Consume(n int64) error {
+	if n < 0 {
+		panic("negative units")
+	}
+	q.mu.Lock()
+	for p := q; p != nil; p = p.parent {
+		if p.used+n > p.limit {
+			q.mu.Unlock()
+			if p.opts.onOverflow != nil {
+				p.opts.onOverflow(p, n)
+			}
+			return ErrQuotaExceeded
+		}
+	}
+	for p := q; p != nil; p = p.parent {
+		p.used += n
+	}
+	q.mu.Unlock()
+	return nil
+}
+
+// Release releases n previously consumed units from the quota and all its ancestors.
+// It panics, if more units are released than have been consumed.
+func (q *Quota) Release(n int64) {
+	if n < 0 {
+		panic("negative units")
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n > q.used {
+		panic("released more than consumed")
+	}
+	for p := q; p != nil; p = p.parent {
+		p.used -= n
+	}
+}
@@ -0,0 +1,82 @@
+package quotatracker_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/quotatracker"
+)
+
+const testLimit = 10
+
+func TestQuota_Consume(t *testing.T) {
+	root := New("root", testLimit)
+	a := root.Child("a", testLimit/2)
+	b := root.Child("b", testLimit)
+	assert.Same(t, a, root.Child("a", 0))
+	assert.Same(t, root, a.Parent())
+
+	require.NoError(t, a.Consume(testLimit/2))
+	assert.Equal(t, ErrQuotaExceeded, a.Consume(1))
+	assert.EqualValues(t, testLimit/2, b.Available())
+
+	// the parent limits the total usage
+	assert.Equal(t, ErrQuotaExceeded, b.Consume(testLimit))
+	assert.Zero(t, b.Used())
+	require.NoError(t, b.Consume(testLimit/2))
+	assert.EqualValues(t, testLimit, root.Used())
+	assert.Zero(t, root.Available())
+
+	a.Release(testLimit / 2)
+	assert.EqualValues(t, testLimit/2, root.Used())
+	assert.EqualValues(t, testLimit/2, b.Available())
+	assert.Panics(t, func() { a.Release(1) })
+}
+
+func TestQuota_SetLimit(t *testing.T) {
+	q := New("root", testLimit)
+	require.NoError(t, q.Consume(testLimit))
+	q.SetLimit(testLimit / 2)
+	assert.Zero(t, q.Available())
+	q.Release(testLimit)
+	assert.EqualValues(t, testLimit/2, q.Available())
+}
+
+func TestWithOverflowCallback(t *testing.T) {
+	var overflows []string
+	cb := WithOverflowCallback(func(q *Quota, n int64) {
+		assert.EqualValues(t, testLimit+1, n)
+		overflows = append(overflows, q.Name())
+	})
+
+	root := New("root", testLimit, cb)
+	child := root.Child("child", 2*testLimit, cb)
+	assert.Error(t, child.Consume(testLimit+1))
+	assert.Error(t, root.Consume(testLimit+1))
+	assert.Equal(t, []string{"root", "root"}, overflows)
+}
+
+func TestQuota_Concurrent(t *testing.T) {
+	root := New("root", testLimit)
+	children := []*Quota{root.Child("a", testLimit), root.Child("b", testLimit)}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var consumed int
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(q *Quota) {
+			defer wg.Done()
+			if q.Consume(1) == nil {
+				mu.Lock()
+				consumed++
+				mu.Unlock()
+			}
+		}(children[i%2])
+	}
+	wg.Wait()
+	assert.Equal(t, testLimit, consumed)
+	assert.EqualValues(t, testLimit, root.Used())
+}
@@ -0,0 +1,41 @@
+package samplesort
+
+// An Option configures a Gate.
+type Option interface {
+	apply(*options)
+}
+
+// optionFunc wraps a func so it satisfies the Option interface.
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+// options holds the configuration of a Gate.
+type options struct {
+	rate   float64
+	warmup int
+}
+
+// WithRate configures how fast the gate adapts to changes in the distribution of the values. Larger rates forget
+// old values faster, but make the threshold noisier. The default is 0.01.
+func WithRate(rate float64) Option {
+	if rate <= 0 || rate > 1 {
+		panic("invalid rate")
+	}
+	return optionFunc(func(o *options) {
+		o.rate = rate
+	})
+}
+
+// WithWarmup configures the number of values that are admitted unconditionally, while the estimate of the threshold
+// is still inaccurate. The default is 100.
+func WithWarmup(n int) Option {
+	if n < 0 {
+		panic("negative warmup")
+	}
+	return optionFunc(func(o *options) {
+		o.warmup = n
+	})
+}
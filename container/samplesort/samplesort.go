@@ -0,0 +1,74 @@
+/*
+Package samplesort implements a streaming percentile gate.
+
+A Gate decides whether a value belongs to the top fraction of the recently observed values. Instead of storing and
+sorting the values, it maintains a running estimate of the corresponding quantile using stochastic approximation:
+Every observation moves the estimate up or down by a step proportional to the spread of the values, weighted such
+that the estimate settles where the desired fraction of the values lies above it. Both observations and decisions
+are O(1) in time and memory.
+
+A typical use is an admission filter in front of a bounded queue, rejecting candidates that would be evicted soon
+anyway.
+*/
+package samplesort
+
+import (
+	"math"
+)
+
+// Gate estimates whether values are among the top fraction of recent values.
+// It is not safe for concurrent use.
+type Gate struct {
+	top  float64 // fraction of the values to admit
+	opts options
+
+	n      int
+	q      float64 // estimate of the (1-top)-quantile
+	spread float64 // running mean absolute deviation from the estimate
+}
+
+// New creates a new Gate admitting the top fraction of the values, which must be in (0, 1).
+func New(top float64, opts ...Option) *Gate {
+	if top <= 0 || top >= 1 {
+		panic("invalid fraction")
+	}
+	g := &Gate{
+		top:  top,
+		opts: options{rate: 0.01, warmup: 100},
+	}
+	for _, opt := range opts {
+		opt.apply(&g.opts)
+	}
+	return g
+}
+
+// Observe records the given value and returns whether it is among the top fraction of the recent values.
+func (g *Gate) Observe(value int) bool {
+	admit := g.Admit(value)
+	x := float64(value)
+	if g.n == 0 {
+		g.q = x
+	}
+	g.n++
+	g.spread += g.opts.rate * (math.Abs(x-g.q) - g.spread)
+
+	// in equilibrium, the expected step is zero exactly at the quantile
+	step := g.opts.rate * math.Max(g.spread, 1)
+	if x > g.q {
+		g.q += step * (1 - g.top)
+	} else {
+		g.q -= step * g.top
+	}
+	return admit
+}
+
+// Admit returns whether the given value is among the top fraction of the recent values without recording it.
+// During the warmup, all values are admitted.
+func (g *Gate) Admit(value int) bool {
+	return g.n < g.opts.warmup || float64(value) > g.q
+}
+
+// Threshold returns the current estimate of the value above which values are admitted.
+func (g *Gate) Threshold() float64 {
+	return g.q
+}
@@ -0,0 +1,49 @@
+package samplesort_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/samplesort"
+)
+
+const testTop = 0.1
+
+func TestGate_Observe(t *testing.T) {
+	g := New(testTop)
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 10000; i++ {
+		g.Observe(r.Intn(1000))
+	}
+	assert.InDelta(t, 900, g.Threshold(), 50)
+
+	var admitted int
+	const n = 10000
+	for i := 0; i < n; i++ {
+		if g.Observe(r.Intn(1000)) {
+			admitted++
+		}
+	}
+	assert.InDelta(t, testTop, float64(admitted)/n, 0.05)
+}
+
+func TestGate_Adapt(t *testing.T) {
+	g := New(testTop, WithRate(0.05))
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 10000; i++ {
+		g.Observe(r.Intn(1000))
+	}
+	// the threshold follows a shift in the distribution
+	for i := 0; i < 10000; i++ {
+		g.Observe(10000 + r.Intn(1000))
+	}
+	assert.InDelta(t, 10900, g.Threshold(), 100)
+}
+
+func TestWithWarmup(t *testing.T) {
+	g := New(testTop, WithWarmup(2))
+	assert.True(t, g.Observe(10))
+	assert.True(t, g.Observe(0))
+	assert.False(t, g.Admit(0))
+}
@@ -0,0 +1,49 @@
+package sequencer
+
+import (
+	"time"
+)
+
+// An Option configures a Buffer.
+type Option interface {
+	apply(*options)
+}
+
+// optionFunc wraps a func so it satisfies the Option interface.
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+// options holds the configuration of a Buffer.
+type options struct {
+	timeout time.Duration
+	onGap   func(Gap)
+	now     func() time.Time
+}
+
+// WithTimeout configures how long buffered items wait for a missing predecessor. Once Expire is called after the
+// timeout has passed, the missing items are skipped. By default, items wait indefinitely.
+func WithTimeout(timeout time.Duration) Option {
+	if timeout <= 0 {
+		panic("non-positive timeout")
+	}
+	return optionFunc(func(o *options) {
+		o.timeout = timeout
+	})
+}
+
+// WithGapCallback configures a callback that is called for every gap that is skipped due to a timeout.
+func WithGapCallback(f func(Gap)) Option {
+	return optionFunc(func(o *options) {
+		o.onGap = f
+	})
+}
+
+// WithClock configures the function used to query the current time.
+func WithClock(now func() time.Time) Option {
+	return optionFunc(func(o *options) {
+		o.now = now
+	})
+}
@@ -0,0 +1,143 @@
+/*
+Package sequencer implements a reassembly buffer that restores the order of items tagged with sequence numbers.
+
+Items can be pushed in any order, but they are only released in a contiguous run starting with the next expected
+sequence number. To bound the memory usage, only items within a window following the next expected sequence number
+are accepted. Missing items are reported as gaps and can be skipped after a timeout.
+*/
+package sequencer
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrDuplicate is returned when an item has already been pushed or released.
+	ErrDuplicate = errors.New("duplicate sequence number")
+	// ErrOutOfWindow is returned when the sequence number of an item is too far ahead.
+	ErrOutOfWindow = errors.New("sequence number out of window")
+)
+
+// Gap represents the range [From, To) of missing sequence numbers.
+type Gap struct {
+	From, To uint64
+}
+
+// Buffer is an out-of-order to in-order reassembly buffer.
+// It is not safe for concurrent use.
+type Buffer[T any] struct {
+	next  uint64 // next expected sequence number
+	slots []slot[T]
+	count int // number of buffered items
+	opts  options
+}
+
+// slot holds a buffered item.
+type slot[T any] struct {
+	value   T
+	ok      bool
+	arrived time.Time
+}
+
+// New creates a new Buffer expecting the given sequence number next and accepting items up to window ahead of it.
+func New[T any](next uint64, window int, opts ...Option) *Buffer[T] {
+	if window < 1 {
+		panic("invalid window")
+	}
+	b := &Buffer[T]{
+		next:  next,
+		slots: make([]slot[T], window),
+		opts:  options{now: time.Now},
+	}
+	for _, opt := range opts {
+		opt.apply(&b.opts)
+	}
+	return b
+}
+
+// Push adds the item with the given sequence number and returns all items that can be released in order.
+// It returns ErrDuplicate or ErrOutOfWindow, if the item cannot be accepted.
+func (b *Buffer[T]) Push(seq uint64, value T) ([]T, error) {
+	if seq < b.next {
+		return nil, ErrDuplicate
+	}
+	if seq-b.next >= uint64(len(b.slots)) {
+		return nil, ErrOutOfWindow
+	}
+	s := b.slot(seq)
+	if s.ok {
+		return nil, ErrDuplicate
+	}
+	*s = slot[T]{value: value, ok: true, arrived: b.opts.now()}
+	b.count++
+	return b.release(nil), nil
+}
+
+// Next returns the next expected sequence number.
+func (b *Buffer[T]) Next() uint64 {
+	return b.next
+}
+
+// Len returns the number of buffered items waiting for a predecessor.
+func (b *Buffer[T]) Len() int {
+	return b.count
+}
+
+// Gaps returns the ranges of missing sequence numbers preceding buffered items.
+func (b *Buffer[T]) Gaps() []Gap {
+	var gaps []Gap
+	from, seen := b.next, 0
+	for seq := b.next; seen < b.count; seq++ {
+		if !b.slot(seq).ok {
+			continue
+		}
+		if from < seq {
+			gaps = append(gaps, Gap{from, seq})
+		}
+		from = seq + 1
+		seen++
+	}
+	return gaps
+}
+
+// Expire skips all gaps preceding items that have been waiting longer than the configured timeout and returns the
+// items that can be released afterwards. Without a timeout, Expire does nothing.
+func (b *Buffer[T]) Expire() []T {
+	if b.opts.timeout == 0 {
+		return nil
+	}
+	deadline := b.opts.now().Add(-b.opts.timeout)
+	var released []T
+	for b.count > 0 {
+		seq := b.next
+		for !b.slot(seq).ok {
+			seq++
+		}
+		if !b.slot(seq).arrived.Before(deadline) {
+			break
+		}
+		if b.opts.onGap != nil {
+			b.opts.onGap(Gap{b.next, seq})
+		}
+		b.next = seq
+		released = b.release(released)
+	}
+	return released
+}
+
+// release appends the contiguous run of items starting with the next expected sequence number to dst.
+func (b *Buffer[T]) release(dst []T) []T {
+	for s := b.slot(b.next); s.ok; s = b.slot(b.next) {
+		dst = append(dst, s.value)
+		*s = slot[T]{}
+		b.count--
+		b.next++
+	}
+	return dst
+}
+
+// slot returns the slot of the given sequence number.
+func (b *Buffer[T]) slot(seq uint64) *slot[T] {
+	return &b.slots[seq%uint64(len(b.slots))]
+}
@@ -0,0 +1,77 @@
+package sequencer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/sequencer"
+)
+
+const testWindow = 10
+
+func TestBuffer_Push(t *testing.T) {
+	b := New[string](1, testWindow)
+
+	released, err := b.Push(3, "c")
+	require.NoError(t, err)
+	assert.Empty(t, released)
+	released, err = b.Push(2, "b")
+	require.NoError(t, err)
+	assert.Empty(t, released)
+	assert.Equal(t, 2, b.Len())
+
+	released, err = b.Push(1, "a")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, released)
+	assert.EqualValues(t, 4, b.Next())
+	assert.Zero(t, b.Len())
+
+	_, err = b.Push(2, "b")
+	assert.Equal(t, ErrDuplicate, err)
+	_, err = b.Push(4+testWindow, "x")
+	assert.Equal(t, ErrOutOfWindow, err)
+	_, err = b.Push(4+testWindow-1, "x")
+	assert.NoError(t, err)
+	_, err = b.Push(4+testWindow-1, "x")
+	assert.Equal(t, ErrDuplicate, err)
+}
+
+func TestBuffer_Gaps(t *testing.T) {
+	b := New[int](0, testWindow)
+	assert.Empty(t, b.Gaps())
+
+	for _, seq := range []uint64{2, 3, 6} {
+		_, err := b.Push(seq, int(seq))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []Gap{{0, 2}, {4, 6}}, b.Gaps())
+}
+
+func TestBuffer_Expire(t *testing.T) {
+	now := time.Unix(0, 0)
+	var gaps []Gap
+	b := New[int](0, testWindow,
+		WithTimeout(time.Second),
+		WithClock(func() time.Time { return now }),
+		WithGapCallback(func(g Gap) { gaps = append(gaps, g) }),
+	)
+
+	for i, seq := range []uint64{1, 2, 4} {
+		now = time.Unix(0, 0).Add(time.Duration(i) * time.Second / 2)
+		_, err := b.Push(seq, int(seq))
+		require.NoError(t, err)
+	}
+	assert.Empty(t, b.Expire())
+
+	// only the items pushed more than a second ago may skip their gap
+	now = now.Add(time.Second / 4)
+	assert.Equal(t, []int{1, 2}, b.Expire())
+	assert.Equal(t, []Gap{{0, 1}}, gaps)
+
+	now = now.Add(time.Second)
+	assert.Equal(t, []int{4}, b.Expire())
+	assert.Equal(t, []Gap{{0, 1}, {3, 4}}, gaps)
+	assert.EqualValues(t, 5, b.Next())
+}
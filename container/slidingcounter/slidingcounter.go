@@ -0,0 +1,95 @@
+/*
+Package slidingcounter implements a counter of the events within a sliding time window.
+
+The window is divided into buckets of a fixed resolution. Events are counted in the bucket of the current time, and
+buckets are reset once they leave the window. Sums and rates can be queried for any duration up to the window; as
+the current bucket is only partially elapsed, the results are accurate up to the resolution.
+*/
+package slidingcounter
+
+import (
+	"sync"
+	"time"
+)
+
+// Counter counts events within a sliding time window.
+// It is safe for concurrent use by multiple goroutines.
+type Counter struct {
+	resolution time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex // protects the following fields
+	buckets []uint64
+	head    int       // bucket of the current time
+	start   time.Time // start of the head bucket
+}
+
+// New creates a new Counter for the given window, which is divided into buckets of the given resolution.
+// If now is nil, time.Now is used to query the current time.
+func New(window, resolution time.Duration, now func() time.Time) *Counter {
+	if resolution <= 0 || window < resolution {
+		panic("invalid resolution")
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &Counter{
+		resolution: resolution,
+		now:        now,
+		buckets:    make([]uint64, (window+resolution-1)/resolution),
+		start:      now(),
+	}
+}
+
+// Add counts n events at the current time.
+func (c *Counter) Add(n uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+	c.buckets[c.head] += n
+}
+
+// Sum returns the number of events within the last duration d, which is capped at the window.
+func (c *Counter) Sum(d time.Duration) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance()
+	k := int((d + c.resolution - 1) / c.resolution)
+	if k > len(c.buckets) {
+		k = len(c.buckets)
+	}
+	var sum uint64
+	for i := 0; i < k; i++ {
+		sum += c.buckets[(c.head-i+len(c.buckets))%len(c.buckets)]
+	}
+	return sum
+}
+
+// Rate returns the number of events per second within the last duration d, which is capped at the window.
+func (c *Counter) Rate(d time.Duration) float64 {
+	if w := c.Window(); d > w {
+		d = w
+	}
+	return float64(c.Sum(d)) / d.Seconds()
+}
+
+// Window returns the duration covered by the counter.
+func (c *Counter) Window() time.Duration {
+	return time.Duration(len(c.buckets)) * c.resolution
+}
+
+// advance moves the head to the bucket of the current time, resetting all skipped buckets.
+func (c *Counter) advance() {
+	steps := c.now().Sub(c.start) / c.resolution
+	if steps <= 0 {
+		return
+	}
+	c.start = c.start.Add(steps * c.resolution)
+	if steps > time.Duration(len(c.buckets)) {
+		steps = time.Duration(len(c.buckets))
+	}
+	for ; steps > 0; steps-- {
+		c.head = (c.head + 1) % len(c.buckets)
+		c.buckets[c.head] = 0
+	}
+}
@@ -0,0 +1,30 @@
+package slidingcounter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/wollac/pkg/container/slidingcounter"
+)
+
+func TestCounter(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := New(time.Minute, time.Second, func() time.Time { return now })
+	assert.Equal(t, time.Minute, c.Window())
+
+	for i := 0; i < 10; i++ {
+		c.Add(1)
+		now = now.Add(time.Second)
+	}
+	assert.EqualValues(t, 0, c.Sum(time.Second))
+	assert.EqualValues(t, 5, c.Sum(6*time.Second))
+	assert.EqualValues(t, 10, c.Sum(time.Hour))
+	assert.InDelta(t, 10./60, c.Rate(time.Hour), 1e-9)
+
+	// events leave the window
+	now = now.Add(54 * time.Second)
+	assert.EqualValues(t, 5, c.Sum(time.Minute))
+	now = now.Add(time.Hour)
+	assert.Zero(t, c.Sum(time.Minute))
+}
@@ -0,0 +1,173 @@
+/*
+Package statemap implements a keyed container in which every entry carries a state of a finite state machine.
+
+The state machine is defined by the allowed transitions between the states. Entries can only change their state
+using Transition, which atomically verifies the current state and the validity of the transition. The entries are
+indexed by their state, so that counting and iterating over the entries in a particular state is efficient.
+*/
+package statemap
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrExists is returned when inserting a key that is already contained.
+	ErrExists = errors.New("key already exists")
+	// ErrNotFound is returned when the key is not contained.
+	ErrNotFound = errors.New("key not found")
+	// ErrStateMismatch is returned when the entry is not in the expected state.
+	ErrStateMismatch = errors.New("state mismatch")
+	// ErrInvalidTransition is returned when the state machine does not allow a transition.
+	ErrInvalidTransition = errors.New("invalid transition")
+)
+
+// Machine defines a finite state machine by mapping every state to the states it can transition to.
+type Machine[S comparable] map[S][]S
+
+// Allows returns whether the state machine allows the transition from one state to another.
+func (m Machine[S]) Allows(from, to S) bool {
+	for _, s := range m[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Map represents a map whose entries carry a state.
+// It is safe for concurrent use by multiple goroutines.
+type Map[K comparable, S comparable, V any] struct {
+	machine Machine[S]
+
+	mu      sync.RWMutex // protects the following fields
+	entries map[K]*entry[S, V]
+	states  map[S]map[K]*entry[S, V]
+}
+
+// entry represents one entry of the Map.
+type entry[S comparable, V any] struct {
+	state S
+	value V
+}
+
+// New creates a new empty Map using the given state machine.
+func New[K comparable, S comparable, V any](machine Machine[S]) *Map[K, S, V] {
+	return &Map[K, S, V]{
+		machine: machine,
+		entries: make(map[K]*entry[S, V]),
+		states:  make(map[S]map[K]*entry[S, V]),
+	}
+}
+
+// Insert adds the key with the given initial state and value.
+// It returns ErrExists, if the key is already contained.
+func (m *Map[K, S, V]) Insert(key K, state S, value V) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.entries[key]; ok {
+		return ErrExists
+	}
+	e := &entry[S, V]{state: state, value: value}
+	m.entries[key] = e
+	m.index(key, e)
+	return nil
+}
+
+// Get returns the value and state of the given key.
+// The last return value is false, if no such key exists.
+func (m *Map[K, S, V]) Get(key K) (V, S, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.entries[key]
+	if !ok {
+		var (
+			value V
+			state S
+		)
+		return value, state, false
+	}
+	return e.value, e.state, true
+}
+
+// Transition changes the state of the given key from one state to another.
+// It returns ErrNotFound if no such key exists, ErrStateMismatch if the entry is not in the state from, or
+// ErrInvalidTransition if the state machine does not allow the transition. In all these cases, nothing is changed.
+func (m *Map[K, S, V]) Transition(key K, from, to S) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if e.state != from {
+		return ErrStateMismatch
+	}
+	if !m.machine.Allows(from, to) {
+		return ErrInvalidTransition
+	}
+	m.unindex(key, e)
+	e.state = to
+	m.index(key, e)
+	return nil
+}
+
+// Delete removes the given key.
+// It returns true, if an entry was removed or false when no such key exists.
+func (m *Map[K, S, V]) Delete(key K) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok {
+		return false
+	}
+	delete(m.entries, key)
+	m.unindex(key, e)
+	return true
+}
+
+// Len returns the number of entries.
+func (m *Map[K, S, V]) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.entries)
+}
+
+// Count returns the number of entries in the given state.
+func (m *Map[K, S, V]) Count(state S) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.states[state])
+}
+
+// Range calls f sequentially for each key and value in the given state in no particular order. If f returns false,
+// Range stops the iteration. f must not modify the Map.
+func (m *Map[K, S, V]) Range(state S, f func(key K, value V) bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for k, e := range m.states[state] {
+		if !f(k, e.value) {
+			return
+		}
+	}
+}
+
+// index adds the entry to the index of its state.
+func (m *Map[K, S, V]) index(key K, e *entry[S, V]) {
+	keys, ok := m.states[e.state]
+	if !ok {
+		keys = make(map[K]*entry[S, V])
+		m.states[e.state] = keys
+	}
+	keys[key] = e
+}
+
+// unindex removes the entry from the index of its state.
+func (m *Map[K, S, V]) unindex(key K, e *entry[S, V]) {
+	keys := m.states[e.state]
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(m.states, e.state)
+	}
+}
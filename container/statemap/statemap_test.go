@@ -0,0 +1,91 @@
+package statemap_test
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/statemap"
+)
+
+type state int
+
+const (
+	pending state = iota
+	selected
+	confirmed
+)
+
+var testMachine = Machine[state]{
+	pending:  {selected},
+	selected: {pending, confirmed},
+}
+
+func TestMap_Transition(t *testing.T) {
+	m := New[string, state, int](testMachine)
+	require.NoError(t, m.Insert("a", pending, 1))
+	assert.Equal(t, ErrExists, m.Insert("a", pending, 1))
+
+	assert.Equal(t, ErrNotFound, m.Transition("b", pending, selected))
+	assert.Equal(t, ErrStateMismatch, m.Transition("a", selected, confirmed))
+	assert.Equal(t, ErrInvalidTransition, m.Transition("a", pending, confirmed))
+	require.NoError(t, m.Transition("a", pending, selected))
+
+	value, s, ok := m.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, selected, s)
+	assert.Zero(t, m.Count(pending))
+	assert.Equal(t, 1, m.Count(selected))
+
+	assert.True(t, m.Delete("a"))
+	assert.False(t, m.Delete("a"))
+	assert.Zero(t, m.Count(selected))
+	assert.Zero(t, m.Len())
+}
+
+func TestMap_Range(t *testing.T) {
+	m := New[string, state, int](testMachine)
+	require.NoError(t, m.Insert("a", pending, 1))
+	require.NoError(t, m.Insert("b", pending, 2))
+	require.NoError(t, m.Insert("c", selected, 3))
+
+	var keys []string
+	m.Range(pending, func(key string, _ int) bool {
+		keys = append(keys, key)
+		return true
+	})
+	sort.Strings(keys)
+	assert.Equal(t, []string{"a", "b"}, keys)
+
+	var n int
+	m.Range(pending, func(string, int) bool {
+		n++
+		return false
+	})
+	assert.Equal(t, 1, n)
+}
+
+func TestMap_Concurrent(t *testing.T) {
+	m := New[string, state, int](testMachine)
+	require.NoError(t, m.Insert("a", pending, 1))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var succeeded int
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if m.Transition("a", pending, selected) == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, succeeded)
+}
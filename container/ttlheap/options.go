@@ -0,0 +1,41 @@
+package ttlheap
+
+import (
+	"time"
+)
+
+// An Option configures a Heap.
+type Option interface {
+	apply(*options)
+}
+
+// optionFunc wraps a func so it satisfies the Option interface.
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+// options holds the configuration of a Heap.
+type options struct {
+	now          func() time.Time
+	reclaimBatch int
+}
+
+// WithClock configures the function used to query the current time.
+func WithClock(now func() time.Time) Option {
+	return optionFunc(func(o *options) {
+		o.now = now
+	})
+}
+
+// WithReclaimBatch configures the maximum number of expired entries reclaimed by every operation. Smaller values
+// reduce the worst-case latency of a single operation, larger ones free memory faster. The default is 16.
+func WithReclaimBatch(n int) Option {
+	if n < 1 {
+		panic("invalid reclaim batch")
+	}
+	return optionFunc(func(o *options) {
+		o.reclaimBatch = n
+	})
+}
@@ -0,0 +1,236 @@
+/*
+Package ttlheap implements a max priority queue whose entries expire.
+
+Every entry has a priority and an expiry time. The entries are kept in two heaps, ordered by priority and by expiry
+respectively, which are always kept consistent. Expired entries are never returned, and every operation reclaims a
+bounded number of expired entries, so that memory is freed incrementally without a background goroutine.
+
+Max and PopMax panic if the heap contains no unexpired entry. For an error-returning variant use TryMax and
+TryPopMax, which return ErrEmpty instead.
+*/
+package ttlheap
+
+import (
+	"container/heap"
+	"errors"
+	"time"
+)
+
+// ErrEmpty is returned when the heap contains no unexpired entry.
+var ErrEmpty = errors.New("empty heap")
+
+// Entry represents an entry of the Heap.
+type Entry struct {
+	Key      string
+	Priority int
+	Expiry   time.Time
+}
+
+// Heap represents a max priority queue with expiring entries.
+// It is not safe for concurrent use.
+type Heap struct {
+	byPriority priorityHeap
+	byExpiry   expiryHeap
+	index      map[string]*item
+	opts       options
+}
+
+type item struct {
+	Entry
+	priorityIndex int
+	expiryIndex   int
+}
+
+// New creates a new empty Heap.
+func New(opts ...Option) *Heap {
+	h := &Heap{
+		index: make(map[string]*item),
+		opts:  options{now: time.Now, reclaimBatch: 16},
+	}
+	for _, opt := range opts {
+		opt.apply(&h.opts)
+	}
+	return h
+}
+
+// Add adds the key with the given priority and expiry time.
+// If the key is already contained, its priority and expiry are updated.
+func (h *Heap) Add(key string, priority int, expiry time.Time) {
+	now := h.opts.now()
+	h.reclaim(now, h.opts.reclaimBatch)
+	if it, ok := h.index[key]; ok {
+		it.Priority, it.Expiry = priority, expiry
+		heap.Fix(&h.byPriority, it.priorityIndex)
+		heap.Fix(&h.byExpiry, it.expiryIndex)
+		return
+	}
+	it := &item{Entry: Entry{Key: key, Priority: priority, Expiry: expiry}}
+	h.index[key] = it
+	heap.Push(&h.byPriority, it)
+	heap.Push(&h.byExpiry, it)
+}
+
+// AddWithTTL adds the key with the given priority expiring after the duration ttl.
+func (h *Heap) AddWithTTL(key string, priority int, ttl time.Duration) {
+	h.Add(key, priority, h.opts.now().Add(ttl))
+}
+
+// Remove removes the given key from the heap.
+// It returns true, if an unexpired entry was removed.
+func (h *Heap) Remove(key string) bool {
+	now := h.opts.now()
+	h.reclaim(now, h.opts.reclaimBatch)
+	it, ok := h.index[key]
+	if !ok {
+		return false
+	}
+	h.remove(it)
+	return now.Before(it.Expiry)
+}
+
+// Get returns the entry of the given key.
+// The second return value is false, if no such key exists or it has expired.
+func (h *Heap) Get(key string) (Entry, bool) {
+	it, ok := h.index[key]
+	if !ok || !h.opts.now().Before(it.Expiry) {
+		return Entry{}, false
+	}
+	return it.Entry, true
+}
+
+// Max returns the unexpired entry with the highest priority.
+// It panics with ErrEmpty if there is no such entry.
+func (h *Heap) Max() Entry {
+	e, err := h.TryMax()
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// TryMax returns the unexpired entry with the highest priority.
+// It returns ErrEmpty if there is no such entry.
+func (h *Heap) TryMax() (Entry, error) {
+	it := h.max()
+	if it == nil {
+		return Entry{}, ErrEmpty
+	}
+	return it.Entry, nil
+}
+
+// PopMax removes and returns the unexpired entry with the highest priority.
+// It panics with ErrEmpty if there is no such entry.
+func (h *Heap) PopMax() Entry {
+	e, err := h.TryPopMax()
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// TryPopMax removes and returns the unexpired entry with the highest priority.
+// It returns ErrEmpty if there is no such entry.
+func (h *Heap) TryPopMax() (Entry, error) {
+	it := h.max()
+	if it == nil {
+		return Entry{}, ErrEmpty
+	}
+	h.remove(it)
+	return it.Entry, nil
+}
+
+// Len returns the number of entries in the heap.
+// This includes expired entries that have not been reclaimed yet.
+func (h *Heap) Len() int {
+	return len(h.index)
+}
+
+// Expire reclaims all expired entries and returns their number.
+func (h *Heap) Expire() int {
+	return h.reclaim(h.opts.now(), len(h.index))
+}
+
+// max returns the unexpired item with the highest priority or nil if there is none.
+func (h *Heap) max() *item {
+	now := h.opts.now()
+	h.reclaim(now, h.opts.reclaimBatch)
+	for len(h.byPriority) > 0 {
+		it := h.byPriority[0]
+		if now.Before(it.Expiry) {
+			return it
+		}
+		h.remove(it)
+	}
+	return nil
+}
+
+// reclaim removes at most n expired items and returns their number.
+func (h *Heap) reclaim(now time.Time, n int) int {
+	var reclaimed int
+	for ; reclaimed < n && len(h.byExpiry) > 0; reclaimed++ {
+		it := h.byExpiry[0]
+		if now.Before(it.Expiry) {
+			break
+		}
+		h.remove(it)
+	}
+	return reclaimed
+}
+
+func (h *Heap) remove(it *item) {
+	heap.Remove(&h.byPriority, it.priorityIndex)
+	heap.Remove(&h.byExpiry, it.expiryIndex)
+	delete(h.index, it.Key)
+}
+
+// priorityHeap is a max heap of items ordered by priority.
+type priorityHeap []*item
+
+func (h priorityHeap) Len() int           { return len(h) }
+func (h priorityHeap) Less(i, j int) bool { return h[i].Priority > h[j].Priority }
+func (h priorityHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].priorityIndex = i
+	h[j].priorityIndex = j
+}
+
+func (h *priorityHeap) Push(x interface{}) {
+	it := x.(*item)
+	it.priorityIndex = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *priorityHeap) Pop() interface{} {
+	old := *h
+	n := len(old) - 1
+	it := old[n]
+	old[n] = nil
+	*h = old[:n]
+	return it
+}
+
+// expiryHeap is a min heap of items ordered by expiry.
+type expiryHeap []*item
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].Expiry.Before(h[j].Expiry) }
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].expiryIndex = i
+	h[j].expiryIndex = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	it := x.(*item)
+	it.expiryIndex = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old) - 1
+	it := old[n]
+	old[n] = nil
+	*h = old[:n]
+	return it
+}
@@ -0,0 +1,68 @@
+package ttlheap_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/wollac/pkg/container/ttlheap"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestHeap_Max(t *testing.T) {
+	c := &clock{time.Unix(0, 0)}
+	h := New(WithClock(c.now))
+	assert.Panics(t, func() { h.Max() })
+
+	h.AddWithTTL("short", 2, time.Second)
+	h.AddWithTTL("long", 1, time.Minute)
+	assert.Equal(t, "short", h.Max().Key)
+
+	c.t = c.t.Add(time.Second)
+	assert.Equal(t, "long", h.Max().Key)
+	_, ok := h.Get("short")
+	assert.False(t, ok)
+	assert.Equal(t, 1, h.Len())
+
+	assert.Equal(t, "long", h.PopMax().Key)
+	_, err := h.TryPopMax()
+	assert.Equal(t, ErrEmpty, err)
+}
+
+func TestHeap_Add(t *testing.T) {
+	c := &clock{time.Unix(0, 0)}
+	h := New(WithClock(c.now))
+	h.AddWithTTL("a", 1, time.Second)
+	h.AddWithTTL("b", 2, time.Second)
+
+	// updating extends the expiry and changes the priority
+	h.AddWithTTL("a", 3, time.Minute)
+	assert.Equal(t, 2, h.Len())
+	assert.Equal(t, Entry{"a", 3, c.t.Add(time.Minute)}, h.Max())
+
+	c.t = c.t.Add(time.Second)
+	assert.True(t, h.Remove("a"))
+	assert.False(t, h.Remove("b"))
+	assert.Zero(t, h.Len())
+}
+
+func TestWithReclaimBatch(t *testing.T) {
+	c := &clock{time.Unix(0, 0)}
+	h := New(WithClock(c.now), WithReclaimBatch(1))
+	for i := 0; i < 10; i++ {
+		h.AddWithTTL(fmt.Sprint(i), -i, time.Second)
+	}
+	h.AddWithTTL("remaining", -10, time.Minute)
+
+	// every operation reclaims a single expired entry
+	c.t = c.t.Add(time.Second)
+	h.AddWithTTL("new", -11, time.Minute)
+	assert.Equal(t, 11, h.Len())
+	require.Equal(t, 9, h.Expire())
+	assert.Equal(t, 2, h.Len())
+}